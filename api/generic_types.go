@@ -12,9 +12,17 @@ type Generic struct {
 }
 
 const (
-	JWTProviderKind = "JWTProvider"
+	JWTProviderKind    = "JWTProvider"
+	JWTRequirementKind = "JWTRequirement"
+	JWTExemptionKind   = "JWTExemption"
+
+	ClaimAuthorizationPolicyKind = "ClaimAuthorizationPolicy"
 )
 
 var (
-	JWTProviderV1Alpha1 = gev1a1.GroupVersion.String()
+	JWTProviderV1Alpha1    = gev1a1.GroupVersion.String()
+	JWTRequirementV1Alpha1 = gev1a1.GroupVersion.String()
+	JWTExemptionV1Alpha1   = gev1a1.GroupVersion.String()
+
+	ClaimAuthorizationPolicyV1Alpha1 = gev1a1.GroupVersion.String()
 )
@@ -0,0 +1,143 @@
+// Copyright Open KCM
+// License-Identifier:  Version 2.0, January 2004
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=claimauthorizationpolicies
+//
+// ClaimAuthorizationPolicy authorizes requests based on the claims of their verified JWT.
+//
+//nolint:godoclint
+type ClaimAuthorizationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClaimAuthorizationPolicySpec `json:"spec"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClaimAuthorizationPolicy{}, &ClaimAuthorizationPolicyList{})
+}
+
+// ClaimAuthorizationAction is the action taken on the requests matching a rule.
+//
+// +kubebuilder:validation:Enum=Allow;Deny
+type ClaimAuthorizationAction string
+
+const (
+	// ClaimAuthorizationActionAllow allows the requests matching any rule and rejects all the others.
+	ClaimAuthorizationActionAllow ClaimAuthorizationAction = "Allow"
+	// ClaimAuthorizationActionDeny rejects the requests matching any rule and allows all the others.
+	ClaimAuthorizationActionDeny ClaimAuthorizationAction = "Deny"
+)
+
+// ClaimMatchOperator is the operator used to compare a claim with the expected values.
+//
+// +kubebuilder:validation:Enum=In;NotIn;Exists
+type ClaimMatchOperator string
+
+const (
+	// ClaimMatchOperatorIn matches if the claim, or one of its items for array claims, equals one of the values.
+	ClaimMatchOperatorIn ClaimMatchOperator = "In"
+	// ClaimMatchOperatorNotIn matches if the claim, or any of its items for array claims, equals none of the values.
+	ClaimMatchOperatorNotIn ClaimMatchOperator = "NotIn"
+	// ClaimMatchOperatorExists matches if the claim is present.
+	ClaimMatchOperatorExists ClaimMatchOperator = "Exists"
+)
+
+// ClaimAuthorizationPolicySpec defines the authorization rules applied to the verified JWT claims.
+type ClaimAuthorizationPolicySpec struct {
+	// TargetRefs are the Gateways (and optionally the Gateway listeners, through SectionName) the
+	// policy is applied to. The referenced Gateways must live in the same namespace as the policy.
+	// If no target reference with a name is provided, the policy is applied to every listener it is
+	// handed over by Envoy Gateway.
+	//
+	// +optional
+	TargetRefs []gwapiv1.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs,omitempty"`
+
+	// Action is the action taken on the requests matching any of the rules. With the Allow action,
+	// requests not matching any rule are rejected with 403. Defaults to Allow.
+	//
+	// +kubebuilder:default=Allow
+	// +optional
+	Action ClaimAuthorizationAction `json:"action,omitempty"`
+
+	// Rules are the authorization rules. A request matches the policy if it matches any rule.
+	//
+	// +kubebuilder:validation:MinItems=1
+	Rules []ClaimAuthorizationRule `json:"rules"`
+}
+
+// ClaimAuthorizationRule matches the requests to the given paths and methods carrying a JWT whose
+// claims match all the claim matches.
+type ClaimAuthorizationRule struct {
+	// Name is the name of the rule, used in the Envoy RBAC policy name and statistics.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Providers are the names of the JWT providers whose verified payload is checked. If empty, the
+	// payload of any JWT provider of the listener is checked. The rule is skipped on the listeners
+	// without any of the providers, and the listeners without any Allow rule left are not restricted.
+	//
+	// +optional
+	Providers []string `json:"providers,omitempty"`
+
+	// Claims are the claim matches, all of them must match.
+	//
+	// +kubebuilder:validation:MinItems=1
+	Claims []ClaimMatch `json:"claims"`
+
+	// Paths are the request path prefixes the rule applies to. If empty, the rule applies to all paths.
+	// The prefixes match on segment boundaries: "/admin" matches "/admin" and "/admin/keys", but not
+	// "/administrator", while "/admin/" only matches the paths below "/admin/".
+	//
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+
+	// Methods are the HTTP methods the rule applies to. If empty, the rule applies to all methods.
+	//
+	// +optional
+	Methods []string `json:"methods,omitempty"`
+}
+
+// ClaimMatch compares a JWT claim with a list of values.
+//
+// +kubebuilder:validation:XValidation:rule="self.operator == 'Exists' || size(self.values) > 0",message="values are required for the In and NotIn operators"
+type ClaimMatch struct {
+	// Claim is the claim name. Nested claims are separated with a dot, e.g. "realm_access.roles".
+	//
+	// +kubebuilder:validation:MinLength=1
+	Claim string `json:"claim"`
+
+	// Operator is the comparison operator. Defaults to In.
+	//
+	// +kubebuilder:default=In
+	// +optional
+	Operator ClaimMatchOperator `json:"operator,omitempty"`
+
+	// Values are the expected values of the claim.
+	//
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+//
+// ClaimAuthorizationPolicyList contains a list of ClaimAuthorizationPolicy resources.
+//
+//nolint:godoclint
+type ClaimAuthorizationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClaimAuthorizationPolicy `json:"items"`
+}
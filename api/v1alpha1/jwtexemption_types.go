@@ -0,0 +1,91 @@
+// Copyright Open KCM
+// License-Identifier:  Version 2.0, January 2004
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=jwtexemptions
+//
+// JWTExemption exempts routes from the JWT authentication enforced by the extension. The JWTExemptions are
+// read from the cluster when Envoy Gateway translates the virtual hosts of the targeted Gateways; register
+// the kind as an extension resource of Envoy Gateway for their changes to trigger a translation.
+//
+//nolint:godoclint
+type JWTExemption struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec JWTExemptionSpec `json:"spec"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JWTExemption{}, &JWTExemptionList{})
+}
+
+// JWTExemptionSpec defines the routes exempted from JWT authentication.
+type JWTExemptionSpec struct {
+	// TargetRefs are the Gateways (and optionally the Gateway listeners, through SectionName) whose
+	// routes are exempted. The referenced Gateways must live in the same namespace as the JWTExemption.
+	//
+	// +kubebuilder:validation:MinItems=1
+	TargetRefs []gwapiv1.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs"`
+
+	// Rules are the exemption rules. A route is exempted if it matches any rule.
+	//
+	// +kubebuilder:validation:MinItems=1
+	Rules []JWTExemptionRule `json:"rules"`
+}
+
+// JWTExemptionRule matches the requests to exempt. A route only serving requests matching all the rule
+// criteria is exempted, e.g. a route matching the /healthz path prefix for a rule with the / path prefix.
+// A route serving such requests among others is preceded by an exempted copy restricted to them, e.g. a
+// route matching the / path prefix for a rule with the /.well-known/ path prefix or the OPTIONS method.
+// Routes referencing JWT providers or requirements as extensionRef filters are never exempted.
+//
+// +kubebuilder:validation:XValidation:rule="!(has(self.pathPrefix) && has(self.pathRegex))",message="only one of pathPrefix or pathRegex can be specified"
+// +kubebuilder:validation:XValidation:rule="has(self.pathPrefix) || has(self.pathRegex) || has(self.methods) || has(self.hostnames)",message="at least one of pathPrefix, pathRegex, methods or hostnames must be specified"
+type JWTExemptionRule struct {
+	// PathPrefix matches the routes whose path match starts with the prefix.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	PathPrefix string `json:"pathPrefix,omitempty"`
+
+	// PathRegex matches the routes whose exact path matches the RE2 regular expression, and the
+	// routes using the same regular expression as path match. The routes matching the / path prefix
+	// are restricted to the paths fully matching the regular expression.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	PathRegex string `json:"pathRegex,omitempty"`
+
+	// Methods matches the requests using one of the HTTP methods.
+	//
+	// +optional
+	Methods []string `json:"methods,omitempty"`
+
+	// Hostnames matches the routes whose virtual host only serves the hostnames. A hostname can be
+	// prefixed with a wildcard label, e.g. *.example.com.
+	//
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+//
+// JWTExemptionList contains a list of JWTExemption resources.
+//
+//nolint:godoclint
+type JWTExemptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []JWTExemption `json:"items"`
+}
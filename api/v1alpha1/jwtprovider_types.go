@@ -22,6 +22,77 @@ type JWTProvider struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec JWTProviderSpec `json:"spec"`
+
+	// +optional
+	Status JWTProviderStatus `json:"status,omitempty"`
+}
+
+// JWTCache defines the cache of the verified JWTs of a JWTProvider.
+type JWTCache struct {
+	// Enabled turns the cache on or off. Defaults to the extension setting.
+	//
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Size is the number of verified JWTs cached. Defaults to the extension setting.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Size *uint32 `json:"size,omitempty"`
+}
+
+// JWTProviderConditionType is a type of condition of a JWTProvider.
+type JWTProviderConditionType string
+
+// JWTProviderConditionReason is a reason of a JWTProvider condition.
+type JWTProviderConditionReason string
+
+const (
+	// JWTProviderConditionAccepted tells whether the JWTProvider is valid.
+	JWTProviderConditionAccepted JWTProviderConditionType = "Accepted"
+	// JWTProviderConditionResolvedJwks tells whether the JWKS of the JWTProvider could be resolved.
+	JWTProviderConditionResolvedJwks JWTProviderConditionType = "ResolvedJwks"
+	// JWTProviderConditionProgrammed tells whether the JWTProvider is configured on listeners.
+	JWTProviderConditionProgrammed JWTProviderConditionType = "Programmed"
+
+	// JWTProviderReasonAccepted is used with the Accepted condition when it is true.
+	JWTProviderReasonAccepted JWTProviderConditionReason = "Accepted"
+	// JWTProviderReasonInvalid is used when the JWTProvider is invalid.
+	JWTProviderReasonInvalid JWTProviderConditionReason = "Invalid"
+	// JWTProviderReasonResolved is used with the ResolvedJwks condition when it is true.
+	JWTProviderReasonResolved JWTProviderConditionReason = "Resolved"
+	// JWTProviderReasonDiscoveryFailed is used when the JWKS URI cannot be discovered from the issuer.
+	JWTProviderReasonDiscoveryFailed JWTProviderConditionReason = "DiscoveryFailed"
+	// JWTProviderReasonUnresolved is used when the JWKS, or how to fetch it, cannot be resolved.
+	JWTProviderReasonUnresolved JWTProviderConditionReason = "Unresolved"
+	// JWTProviderReasonConflicted is used when the JWTProvider writes the same metadata keys as another
+	// JWTProvider of the listener.
+	JWTProviderReasonConflicted JWTProviderConditionReason = "Conflicted"
+	// JWTProviderReasonProgrammed is used with the Programmed condition when it is true.
+	JWTProviderReasonProgrammed JWTProviderConditionReason = "Programmed"
+	// JWTProviderReasonNotAttached is used when the JWTProvider is not configured on any listener.
+	JWTProviderReasonNotAttached JWTProviderConditionReason = "NotAttached"
+)
+
+// JWTProviderStatus defines the observed state of a JWTProvider, as of the last translation of Envoy Gateway.
+type JWTProviderStatus struct {
+	// Conditions describe the current conditions of the JWTProvider.
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=8
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// JwksURI is the URI the JWKS is fetched from, as configured or discovered from the issuer.
+	//
+	// +optional
+	JwksURI string `json:"jwksUri,omitempty"`
+
+	// Listeners are the names of the Envoy listeners the JWTProvider is configured on.
+	//
+	// +optional
+	Listeners []string `json:"listeners,omitempty"`
 }
 
 func init() {
@@ -29,8 +100,19 @@ func init() {
 }
 
 // JWTProviderSpec defines how a JSON Web Token (JWT) can be verified.
+//
+// +kubebuilder:validation:XValidation:rule="!(has(self.remoteJwks) && has(self.localJwks))",message="only one of remoteJwks or localJwks can be specified"
+// +kubebuilder:validation:XValidation:rule="!has(self.maxLifetime) || (has(self.requireExpiration) && self.requireExpiration)",message="maxLifetime requires requireExpiration"
+// +kubebuilder:validation:XValidation:rule="!has(self.headerInMetadata) || self.headerInMetadata != (has(self.payloadInMetadata) ? self.payloadInMetadata : self.name)",message="headerInMetadata must differ from payloadInMetadata"
+// +kubebuilder:validation:XValidation:rule="!has(self.padForwardPayloadHeader) || !self.padForwardPayloadHeader || has(self.forwardPayloadHeader)",message="padForwardPayloadHeader requires forwardPayloadHeader"
 type JWTProviderSpec struct {
-	TargetRefs []gwapiv1.LocalObjectReference `json:"targetRefs"`
+	// TargetRefs are the Gateways (and optionally the Gateway listeners, through SectionName) this
+	// JWT provider is attached to. The referenced Gateways must live in the same namespace as the
+	// JWTProvider. If no target reference with a name is provided, the JWT provider is attached to
+	// every listener it is handed over by Envoy Gateway.
+	//
+	// +optional
+	TargetRefs []gwapiv1.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs"`
 
 	// Name defines a unique name for the JWT provider. A name can have a variety of forms,
 	// including RFC1123 subdomains, RFC 1123 labels, or RFC 1035 labels.
@@ -55,12 +137,42 @@ type JWTProviderSpec struct {
 	// +optional
 	Audiences []string `json:"audiences,omitempty"`
 
+	// PayloadInMetadata is the key of the dynamic metadata the verified JWT payload is written to, as
+	// read by the claim authorization policies and the claims copied to headers. Defaults to Name.
+	// The JWT providers of a listener must write to distinct keys.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	PayloadInMetadata string `json:"payloadInMetadata,omitempty"`
+
+	// HeaderInMetadata is the key of the dynamic metadata the verified JWT header is written to.
+	// The header is not written if empty.
+	//
+	// +optional
+	HeaderInMetadata string `json:"headerInMetadata,omitempty"`
+
+	// SpaceDelimitedClaims are the claims holding space delimited strings, e.g. scope, written to the
+	// dynamic metadata as lists of strings to ease their matching. Defaults to scope.
+	//
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	SpaceDelimitedClaims []string `json:"spaceDelimitedClaims,omitempty"`
+
 	// JWKS can be fetched from remote server via HTTP/HTTPS. This field specifies the remote HTTP
 	// URI and how the fetched JWKS should be cached.
 	//
 	// +optional
 	RemoteJwks *RemoteJWKS `json:"remoteJwks"`
 
+	// LocalJwks defines the JWKS document used to verify the JWT without reaching the issuer. The
+	// document can be provided inline or through a ConfigMap or Secret key. A referenced document is
+	// read when Envoy Gateway translates the Gateway resources, changes to the ConfigMap or Secret
+	// alone do not trigger a translation. Rotated keys are only served once the JWTProvider spec or
+	// another Gateway resource changes; use RemoteJwks for keys rotating without such a change.
+	//
+	// +optional
+	LocalJwks *LocalJWKS `json:"localJwks,omitempty"`
+
 	// Requires that the credential contains an `expiration <https://tools.ietf.org/html/rfc7519#section-4.1.4>`_.
 	// For instance, this could implement JWT-SVID
 	// `expiration restrictions <https://github.com/spiffe/spiffe/blob/main/standards/JWT-SVID.md#33-expiration-time>`_.
@@ -69,6 +181,45 @@ type JWTProviderSpec struct {
 	// +optional
 	RequireExpiration bool `json:"requireExpiration,omitempty"`
 
+	// MaxLifetime is the maximum lifetime of the JWT, the duration between its issued at time and its
+	// expiration. JWTs with a longer lifetime are rejected. It requires RequireExpiration.
+	//
+	// +optional
+	MaxLifetime *metav1.Duration `json:"maxLifetime,omitempty"`
+
+	// ClockSkewSeconds is the clock skew tolerated when verifying the expiration and not before times
+	// of the JWT. Defaults to 60 seconds.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ClockSkewSeconds *uint32 `json:"clockSkewSeconds,omitempty"`
+
+	// Cache configures the cache of the verified JWTs, sparing the verification of their signature
+	// on each request. Defaults to the cache configured for the extension.
+	//
+	// +optional
+	Cache *JWTCache `json:"cache,omitempty"`
+
+	// Forward keeps the JWT in the request forwarded to the upstream. If false, the JWT is removed from
+	// the request once verified. Defaults to true.
+	//
+	// +kubebuilder:default=true
+	// +optional
+	Forward *bool `json:"forward,omitempty"`
+
+	// ForwardPayloadHeader is the name of the header the base64url encoded payload of the verified JWT
+	// is forwarded in. If not provided, the payload is not forwarded.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	ForwardPayloadHeader string `json:"forwardPayloadHeader,omitempty"`
+
+	// PadForwardPayloadHeader pads the payload forwarded in ForwardPayloadHeader, as expected by
+	// base64url decoders requiring padding.
+	//
+	// +optional
+	PadForwardPayloadHeader bool `json:"padForwardPayloadHeader,omitempty"`
+
 	// RecomputeRoute clears the route cache and recalculates the routing decision.
 	// This field must be enabled if the headers generated from the claim are used for
 	// route matching decisions. If the recomputation selects a new route, features targeting
@@ -96,7 +247,8 @@ type JWTProviderSpec struct {
 
 	// Add JWT claim to HTTP JWTHeader
 	// Specify the claim name you want to copy in which HTTP header. For examples, following config:
-	// The claim must be of type; string, int, double, bool. Array type claims are not supported
+	// With the default Native encoding, the claim must be of type; string, int, double, bool. Array and
+	// object claims can be copied with the Comma, JSON or Base64JSON encodings.
 	//
 	// +optional
 	ClaimToHeaders []*JWTClaimToHeader `json:"claimToHeaders,omitempty"`
@@ -107,6 +259,16 @@ type JWTProviderSpec struct {
 	//
 	// +optional
 	ExtractFrom *JWTExtractor `json:"extractFrom,omitempty"`
+
+	// ErrorResponses customize the responses sent by Envoy when the JWT authentication rejects a request
+	// with the given status code on the listeners the JWT provider is attached to. If several JWT providers of a
+	// listener customize the same status code, the JWT provider with the lowest name wins.
+	//
+	// +listType=map
+	// +listMapKey=statusCode
+	// +kubebuilder:validation:MaxItems=2
+	// +optional
+	ErrorResponses []JWTErrorResponse `json:"errorResponses,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -148,11 +310,34 @@ type JWTClaimToHeader struct {
 	//
 	// +kubebuilder:validation:MinLength=1
 	ClaimName string `json:"claimName"`
+	// Encoding defines how the claim value is written in the header. Defaults to Native, letting the
+	// JWT authentication filter copy the claim.
+	//
+	// +kubebuilder:default=Native
+	// +optional
+	Encoding ClaimEncoding `json:"encoding,omitempty"`
 }
 
+// ClaimEncoding defines how a claim value is written in an HTTP header.
+//
+// +kubebuilder:validation:Enum=Native;Comma;JSON;Base64JSON
+type ClaimEncoding string
+
+const (
+	// ClaimEncodingNative copies string, int, double and bool claims as is.
+	ClaimEncodingNative ClaimEncoding = "Native"
+	// ClaimEncodingComma joins the items of an array claim with commas. Scalar claims are copied as is,
+	// object claims are JSON encoded.
+	ClaimEncodingComma ClaimEncoding = "Comma"
+	// ClaimEncodingJSON writes the claim JSON encoded.
+	ClaimEncodingJSON ClaimEncoding = "JSON"
+	// ClaimEncodingBase64JSON writes the claim JSON encoded, then base64 encoded.
+	ClaimEncodingBase64JSON ClaimEncoding = "Base64JSON"
+)
+
 type RemoteJWKS struct {
-	// URI is the HTTPS URI to fetch the JWKS. Envoy's system trust bundle is used to validate the server certificate.
-	// If a custom trust bundle is needed, it can be specified in a BackendTLSConfig resource and target the BackendRefs.
+	// URI is the HTTPS URI to fetch the JWKS. Envoy's system trust bundle is used to validate the server certificate,
+	// unless a custom trust bundle is specified in TLS.
 	//
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=2048
@@ -173,6 +358,187 @@ type RemoteJWKS struct {
 	//
 	// +optional
 	Retry *Retry `json:"retry,omitempty"`
+
+	// TLS configures the validation of the certificate of the JWKS server.
+	//
+	// +optional
+	TLS *RemoteJWKSTLS `json:"tls,omitempty"`
+
+	// DNSLookupFamily is the IP address family used to resolve the hostname of the JWKS server.
+	// Defaults to the lookup family configured for the extension.
+	//
+	// +optional
+	DNSLookupFamily *DNSLookupFamily `json:"dnsLookupFamily,omitempty"`
+
+	// Proxy is the HTTP proxy the JWKS is fetched through. Defaults to the proxy configured for the
+	// extension, if any.
+	//
+	// +optional
+	Proxy *JWKSProxy `json:"proxy,omitempty"`
+}
+
+// JWKSProxy defines the HTTP proxy the JWKS is fetched through, tunnelling the connections to the JWKS
+// server with HTTP/1.1 CONNECT requests. The tunnels are internal listeners, which requires the
+// internal listener bootstrap extension of Envoy and listeners in the Envoy Gateway translation hook.
+type JWKSProxy struct {
+	// URI is the http or https URI of the proxy, e.g. http://proxy.example.com:3128. Only its scheme,
+	// host and port are used. If empty, the JWKS is fetched directly, even if a proxy is configured
+	// for the extension.
+	//
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	URI string `json:"uri,omitempty"`
+}
+
+// DNSLookupFamily is the IP address family used to resolve a hostname.
+//
+// +kubebuilder:validation:Enum=V4_ONLY;V4_PREFERRED;V6_ONLY;ALL
+type DNSLookupFamily string
+
+const (
+	// DNSLookupFamilyV4Only only resolves IPv4 addresses.
+	DNSLookupFamilyV4Only DNSLookupFamily = "V4_ONLY"
+	// DNSLookupFamilyV4Preferred resolves IPv4 addresses, falling back to IPv6 addresses.
+	DNSLookupFamilyV4Preferred DNSLookupFamily = "V4_PREFERRED"
+	// DNSLookupFamilyV6Only only resolves IPv6 addresses.
+	DNSLookupFamilyV6Only DNSLookupFamily = "V6_ONLY"
+	// DNSLookupFamilyAll resolves both IPv4 and IPv6 addresses.
+	DNSLookupFamilyAll DNSLookupFamily = "ALL"
+)
+
+// RemoteJWKSTLS defines how the certificate of the JWKS server is validated, and the client certificate
+// presented to it. The server certificate must match the JWKS hostname, or the subject alternative names
+// of the referenced BackendTLSPolicy.
+//
+// +kubebuilder:validation:XValidation:rule="!(has(self.caCertificateRef) && has(self.backendTLSPolicyRef))",message="only one of caCertificateRef or backendTLSPolicyRef can be specified"
+type RemoteJWKSTLS struct {
+	// CACertificateRef references a ConfigMap or Secret key, in the namespace of the JWTProvider, holding
+	// the PEM encoded CA bundle trusted instead of Envoy's system trust bundle.
+	//
+	// +optional
+	CACertificateRef *CACertificateRef `json:"caCertificateRef,omitempty"`
+
+	// BackendTLSPolicyRef references a BackendTLSPolicy, in the namespace of the JWTProvider, whose
+	// validation defines the trusted CA certificates and the subject alternative names.
+	//
+	// +optional
+	BackendTLSPolicyRef *BackendTLSPolicyRef `json:"backendTLSPolicyRef,omitempty"`
+
+	// ClientCertificateRef references a Secret, in the namespace of the JWTProvider, holding the client
+	// certificate presented to the JWKS server.
+	//
+	// +optional
+	ClientCertificateRef *ClientCertificateRef `json:"clientCertificateRef,omitempty"`
+}
+
+// CACertificateRef references a key of a ConfigMap or Secret holding a PEM encoded CA bundle.
+type CACertificateRef struct {
+	// Kind is the kind of the referenced resource.
+	//
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name is the name of the referenced resource.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key holding the CA bundle. Defaults to ca.crt.
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// BackendTLSPolicyRef references a Gateway API BackendTLSPolicy.
+type BackendTLSPolicyRef struct {
+	// Name is the name of the referenced BackendTLSPolicy.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ClientCertificateRef references a Secret of type kubernetes.io/tls, holding the PEM encoded certificate
+// chain in tls.crt and its private key in tls.key.
+type ClientCertificateRef struct {
+	// Name is the name of the referenced Secret.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// LocalJWKS defines a JWKS document available to the extension.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.inline) != has(self.valueRef)",message="exactly one of inline or valueRef must be specified"
+type LocalJWKS struct {
+	// Inline is the JWKS document as a JSON string.
+	//
+	// +optional
+	Inline *string `json:"inline,omitempty"`
+
+	// ValueRef references a ConfigMap or Secret key, in the namespace of the JWTProvider, holding the
+	// JWKS document.
+	//
+	// +optional
+	ValueRef *LocalJWKSValueRef `json:"valueRef,omitempty"`
+}
+
+// LocalJWKSValueRef references a key of a ConfigMap or Secret.
+type LocalJWKSValueRef struct {
+	// Kind is the kind of the referenced resource.
+	//
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name is the name of the referenced resource.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key holding the JWKS document. Defaults to jwks.
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// JWTErrorResponse customizes the responses sent when the JWT authentication rejects a request.
+type JWTErrorResponse struct {
+	// StatusCode is the status code of the rejected requests, 401 when the JWT is missing or invalid,
+	// 403 when it is not authorized.
+	//
+	// +kubebuilder:validation:Enum=401;403
+	StatusCode int32 `json:"statusCode"`
+
+	// Body is the body template of the response. Envoy command operators can be used to report the
+	// failure reason, e.g. %LOCAL_REPLY_BODY% for the message and %RESPONSE_CODE_DETAILS% for the
+	// reason code. Literal percent signs must be escaped as %%. Defaults to a JSON object with the
+	// error and the failure reason.
+	//
+	// +optional
+	Body string `json:"body,omitempty"`
+
+	// ContentType is the content type of the response. Defaults to application/json.
+	//
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+
+	// WWWAuthenticate sets the WWW-Authenticate header of the response.
+	//
+	// +optional
+	WWWAuthenticate *WWWAuthenticate `json:"wwwAuthenticate,omitempty"`
+}
+
+// WWWAuthenticate defines a Bearer WWW-Authenticate header, see https://rfc-editor.org/rfc/rfc6750#section-3.
+type WWWAuthenticate struct {
+	// Realm is the protection space of the resource.
+	//
+	// +optional
+	Realm string `json:"realm,omitempty"`
+
+	// Error is the error code. Defaults to invalid_token for 401 and insufficient_scope for 403.
+	//
+	// +kubebuilder:validation:Enum=invalid_request;invalid_token;insufficient_scope
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
 // JWTExtractor defines a custom JWT token extraction from HTTP request.
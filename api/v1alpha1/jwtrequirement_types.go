@@ -0,0 +1,127 @@
+// Copyright Open KCM
+// License-Identifier:  Version 2.0, January 2004
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=jwtrequirements
+//
+// JWTRequirement composes JWT providers into a named requirement of the JWT authentication filter.
+//
+//nolint:godoclint
+type JWTRequirement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec JWTRequirementSpec `json:"spec"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JWTRequirement{}, &JWTRequirementList{})
+}
+
+// JWTRequirementSpec defines how the JWT providers have to be combined to verify a request.
+//
+// +kubebuilder:validation:XValidation:rule="[has(self.providerName), has(self.requiresAny), has(self.requiresAll), has(self.allowMissing), has(self.allowMissingOrFailed)].filter(x, x).size() == 1",message="exactly one of providerName, requiresAny, requiresAll, allowMissing or allowMissingOrFailed must be specified"
+type JWTRequirementSpec struct {
+	// TargetRefs are the Gateways (and optionally the Gateway listeners, through SectionName) the
+	// requirement is registered on. The referenced Gateways must live in the same namespace as the
+	// JWTRequirement. If no target reference with a name is provided, the requirement is registered
+	// on every listener it is handed over by Envoy Gateway.
+	//
+	// +optional
+	TargetRefs []gwapiv1.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs,omitempty"`
+
+	// Name is the name of the requirement in the requirement map of the JWT authentication filter.
+	// Routes select the requirement by referencing the JWTRequirement as an extensionRef filter.
+	// A requirement named jwt_auth_secure_openkcm replaces the default requirement built from all
+	// the JWT providers of the listener.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=1024
+	Name string `json:"name"`
+
+	JWTRequirementRule `json:",inline"`
+}
+
+// JWTRequirementRule is a requirement over JWT providers. Exactly one of its fields must be set.
+type JWTRequirementRule struct {
+	// ProviderName requires a JWT verified by the named provider.
+	//
+	// +optional
+	ProviderName string `json:"providerName,omitempty"`
+
+	// RequiresAny is satisfied if any of the nested requirements is satisfied.
+	//
+	// +kubebuilder:validation:MinItems=2
+	// +optional
+	RequiresAny []JWTRequirementNode `json:"requiresAny,omitempty"`
+
+	// RequiresAll is satisfied if all the nested requirements are satisfied.
+	//
+	// +kubebuilder:validation:MinItems=2
+	// +optional
+	RequiresAll []JWTRequirementNode `json:"requiresAll,omitempty"`
+
+	// AllowMissing lets requests without JWT through, while requests with an invalid JWT are rejected.
+	//
+	// +optional
+	AllowMissing *bool `json:"allowMissing,omitempty"`
+
+	// AllowMissingOrFailed lets requests with a missing or invalid JWT through.
+	//
+	// +optional
+	AllowMissingOrFailed *bool `json:"allowMissingOrFailed,omitempty"`
+}
+
+// JWTRequirementNode is a requirement nested in RequiresAny or RequiresAll. To keep the schema
+// non-recursive, its own nested requirements are limited to provider names.
+//
+// +kubebuilder:validation:XValidation:rule="[has(self.providerName), has(self.requiresAny), has(self.requiresAll), has(self.allowMissing), has(self.allowMissingOrFailed)].filter(x, x).size() == 1",message="exactly one of providerName, requiresAny, requiresAll, allowMissing or allowMissingOrFailed must be specified"
+type JWTRequirementNode struct {
+	// ProviderName requires a JWT verified by the named provider.
+	//
+	// +optional
+	ProviderName string `json:"providerName,omitempty"`
+
+	// RequiresAny is satisfied if a JWT is verified by any of the named providers.
+	//
+	// +kubebuilder:validation:MinItems=2
+	// +optional
+	RequiresAny []string `json:"requiresAny,omitempty"`
+
+	// RequiresAll is satisfied if a JWT is verified by each of the named providers.
+	//
+	// +kubebuilder:validation:MinItems=2
+	// +optional
+	RequiresAll []string `json:"requiresAll,omitempty"`
+
+	// AllowMissing lets requests without JWT through, while requests with an invalid JWT are rejected.
+	//
+	// +optional
+	AllowMissing *bool `json:"allowMissing,omitempty"`
+
+	// AllowMissingOrFailed lets requests with a missing or invalid JWT through.
+	//
+	// +optional
+	AllowMissingOrFailed *bool `json:"allowMissingOrFailed,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+//
+// JWTRequirementList contains a list of JWTRequirement resources.
+//
+//nolint:godoclint
+type JWTRequirementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []JWTRequirement `json:"items"`
+}
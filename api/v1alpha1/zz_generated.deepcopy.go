@@ -10,6 +10,7 @@
 package v1alpha1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	v1 "sigs.k8s.io/gateway-api/apis/v1"
 )
@@ -29,6 +30,235 @@ func (in *BackOffPolicy) DeepCopy() *BackOffPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackendTLSPolicyRef) DeepCopyInto(out *BackendTLSPolicyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendTLSPolicyRef.
+func (in *BackendTLSPolicyRef) DeepCopy() *BackendTLSPolicyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(BackendTLSPolicyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CACertificateRef) DeepCopyInto(out *CACertificateRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CACertificateRef.
+func (in *CACertificateRef) DeepCopy() *CACertificateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(CACertificateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimAuthorizationPolicy) DeepCopyInto(out *ClaimAuthorizationPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimAuthorizationPolicy.
+func (in *ClaimAuthorizationPolicy) DeepCopy() *ClaimAuthorizationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimAuthorizationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClaimAuthorizationPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimAuthorizationPolicyList) DeepCopyInto(out *ClaimAuthorizationPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClaimAuthorizationPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimAuthorizationPolicyList.
+func (in *ClaimAuthorizationPolicyList) DeepCopy() *ClaimAuthorizationPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimAuthorizationPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClaimAuthorizationPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimAuthorizationPolicySpec) DeepCopyInto(out *ClaimAuthorizationPolicySpec) {
+	*out = *in
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]ClaimAuthorizationRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimAuthorizationPolicySpec.
+func (in *ClaimAuthorizationPolicySpec) DeepCopy() *ClaimAuthorizationPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimAuthorizationPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimAuthorizationRule) DeepCopyInto(out *ClaimAuthorizationRule) {
+	*out = *in
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Claims != nil {
+		in, out := &in.Claims, &out.Claims
+		*out = make([]ClaimMatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimAuthorizationRule.
+func (in *ClaimAuthorizationRule) DeepCopy() *ClaimAuthorizationRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimAuthorizationRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimMatch) DeepCopyInto(out *ClaimMatch) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimMatch.
+func (in *ClaimMatch) DeepCopy() *ClaimMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientCertificateRef) DeepCopyInto(out *ClientCertificateRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientCertificateRef.
+func (in *ClientCertificateRef) DeepCopy() *ClientCertificateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientCertificateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWKSProxy) DeepCopyInto(out *JWKSProxy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWKSProxy.
+func (in *JWKSProxy) DeepCopy() *JWKSProxy {
+	if in == nil {
+		return nil
+	}
+	out := new(JWKSProxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTCache) DeepCopyInto(out *JWTCache) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTCache.
+func (in *JWTCache) DeepCopy() *JWTCache {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JWTClaimToHeader) DeepCopyInto(out *JWTClaimToHeader) {
 	*out = *in
@@ -44,6 +274,138 @@ func (in *JWTClaimToHeader) DeepCopy() *JWTClaimToHeader {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTErrorResponse) DeepCopyInto(out *JWTErrorResponse) {
+	*out = *in
+	if in.WWWAuthenticate != nil {
+		in, out := &in.WWWAuthenticate, &out.WWWAuthenticate
+		*out = new(WWWAuthenticate)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTErrorResponse.
+func (in *JWTErrorResponse) DeepCopy() *JWTErrorResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTErrorResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTExemption) DeepCopyInto(out *JWTExemption) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTExemption.
+func (in *JWTExemption) DeepCopy() *JWTExemption {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTExemption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JWTExemption) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTExemptionList) DeepCopyInto(out *JWTExemptionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]JWTExemption, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTExemptionList.
+func (in *JWTExemptionList) DeepCopy() *JWTExemptionList {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTExemptionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JWTExemptionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTExemptionRule) DeepCopyInto(out *JWTExemptionRule) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTExemptionRule.
+func (in *JWTExemptionRule) DeepCopy() *JWTExemptionRule {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTExemptionRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTExemptionSpec) DeepCopyInto(out *JWTExemptionSpec) {
+	*out = *in
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]JWTExemptionRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTExemptionSpec.
+func (in *JWTExemptionSpec) DeepCopy() *JWTExemptionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTExemptionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JWTExtractor) DeepCopyInto(out *JWTExtractor) {
 	*out = *in
@@ -106,6 +468,7 @@ func (in *JWTProvider) DeepCopyInto(out *JWTProvider) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProvider.
@@ -163,19 +526,51 @@ func (in *JWTProviderSpec) DeepCopyInto(out *JWTProviderSpec) {
 	*out = *in
 	if in.TargetRefs != nil {
 		in, out := &in.TargetRefs, &out.TargetRefs
-		*out = make([]v1.LocalObjectReference, len(*in))
-		copy(*out, *in)
+		*out = make([]v1.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Audiences != nil {
 		in, out := &in.Audiences, &out.Audiences
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.SpaceDelimitedClaims != nil {
+		in, out := &in.SpaceDelimitedClaims, &out.SpaceDelimitedClaims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.RemoteJwks != nil {
 		in, out := &in.RemoteJwks, &out.RemoteJwks
 		*out = new(RemoteJWKS)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.LocalJwks != nil {
+		in, out := &in.LocalJwks, &out.LocalJwks
+		*out = new(LocalJWKS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxLifetime != nil {
+		in, out := &in.MaxLifetime, &out.MaxLifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ClockSkewSeconds != nil {
+		in, out := &in.ClockSkewSeconds, &out.ClockSkewSeconds
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(JWTCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Forward != nil {
+		in, out := &in.Forward, &out.Forward
+		*out = new(bool)
+		**out = **in
+	}
 	if in.RecomputeRoute != nil {
 		in, out := &in.RecomputeRoute, &out.RecomputeRoute
 		*out = new(bool)
@@ -208,6 +603,13 @@ func (in *JWTProviderSpec) DeepCopyInto(out *JWTProviderSpec) {
 		*out = new(JWTExtractor)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ErrorResponses != nil {
+		in, out := &in.ErrorResponses, &out.ErrorResponses
+		*out = make([]JWTErrorResponse, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProviderSpec.
@@ -220,6 +622,228 @@ func (in *JWTProviderSpec) DeepCopy() *JWTProviderSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTProviderStatus) DeepCopyInto(out *JWTProviderStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProviderStatus.
+func (in *JWTProviderStatus) DeepCopy() *JWTProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTRequirement) DeepCopyInto(out *JWTRequirement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTRequirement.
+func (in *JWTRequirement) DeepCopy() *JWTRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JWTRequirement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTRequirementList) DeepCopyInto(out *JWTRequirementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]JWTRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTRequirementList.
+func (in *JWTRequirementList) DeepCopy() *JWTRequirementList {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTRequirementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JWTRequirementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTRequirementNode) DeepCopyInto(out *JWTRequirementNode) {
+	*out = *in
+	if in.RequiresAny != nil {
+		in, out := &in.RequiresAny, &out.RequiresAny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiresAll != nil {
+		in, out := &in.RequiresAll, &out.RequiresAll
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowMissing != nil {
+		in, out := &in.AllowMissing, &out.AllowMissing
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowMissingOrFailed != nil {
+		in, out := &in.AllowMissingOrFailed, &out.AllowMissingOrFailed
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTRequirementNode.
+func (in *JWTRequirementNode) DeepCopy() *JWTRequirementNode {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTRequirementNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTRequirementRule) DeepCopyInto(out *JWTRequirementRule) {
+	*out = *in
+	if in.RequiresAny != nil {
+		in, out := &in.RequiresAny, &out.RequiresAny
+		*out = make([]JWTRequirementNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RequiresAll != nil {
+		in, out := &in.RequiresAll, &out.RequiresAll
+		*out = make([]JWTRequirementNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowMissing != nil {
+		in, out := &in.AllowMissing, &out.AllowMissing
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowMissingOrFailed != nil {
+		in, out := &in.AllowMissingOrFailed, &out.AllowMissingOrFailed
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTRequirementRule.
+func (in *JWTRequirementRule) DeepCopy() *JWTRequirementRule {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTRequirementRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTRequirementSpec) DeepCopyInto(out *JWTRequirementSpec) {
+	*out = *in
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.JWTRequirementRule.DeepCopyInto(&out.JWTRequirementRule)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTRequirementSpec.
+func (in *JWTRequirementSpec) DeepCopy() *JWTRequirementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTRequirementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalJWKS) DeepCopyInto(out *LocalJWKS) {
+	*out = *in
+	if in.Inline != nil {
+		in, out := &in.Inline, &out.Inline
+		*out = new(string)
+		**out = **in
+	}
+	if in.ValueRef != nil {
+		in, out := &in.ValueRef, &out.ValueRef
+		*out = new(LocalJWKSValueRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalJWKS.
+func (in *LocalJWKS) DeepCopy() *LocalJWKS {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalJWKS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalJWKSValueRef) DeepCopyInto(out *LocalJWKSValueRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalJWKSValueRef.
+func (in *LocalJWKSValueRef) DeepCopy() *LocalJWKSValueRef {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalJWKSValueRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RemoteJWKS) DeepCopyInto(out *RemoteJWKS) {
 	*out = *in
@@ -228,6 +852,21 @@ func (in *RemoteJWKS) DeepCopyInto(out *RemoteJWKS) {
 		*out = new(Retry)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(RemoteJWKSTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DNSLookupFamily != nil {
+		in, out := &in.DNSLookupFamily, &out.DNSLookupFamily
+		*out = new(DNSLookupFamily)
+		**out = **in
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(JWKSProxy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteJWKS.
@@ -240,6 +879,36 @@ func (in *RemoteJWKS) DeepCopy() *RemoteJWKS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteJWKSTLS) DeepCopyInto(out *RemoteJWKSTLS) {
+	*out = *in
+	if in.CACertificateRef != nil {
+		in, out := &in.CACertificateRef, &out.CACertificateRef
+		*out = new(CACertificateRef)
+		**out = **in
+	}
+	if in.BackendTLSPolicyRef != nil {
+		in, out := &in.BackendTLSPolicyRef, &out.BackendTLSPolicyRef
+		*out = new(BackendTLSPolicyRef)
+		**out = **in
+	}
+	if in.ClientCertificateRef != nil {
+		in, out := &in.ClientCertificateRef, &out.ClientCertificateRef
+		*out = new(ClientCertificateRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteJWKSTLS.
+func (in *RemoteJWKSTLS) DeepCopy() *RemoteJWKSTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteJWKSTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Retry) DeepCopyInto(out *Retry) {
 	*out = *in
@@ -264,3 +933,18 @@ func (in *Retry) DeepCopy() *Retry {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WWWAuthenticate) DeepCopyInto(out *WWWAuthenticate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WWWAuthenticate.
+func (in *WWWAuthenticate) DeepCopy() *WWWAuthenticate {
+	if in == nil {
+		return nil
+	}
+	out := new(WWWAuthenticate)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -35,6 +35,15 @@ https://github.com/helm/helm/issues/5358
     {{ .Values.namespace | default .Release.Namespace }}
 {{- end -}}
 
+{{/*
+Namespaces the ConfigMaps, Secrets and BackendTLSPolicies referenced by the JWT providers are read from
+If not defined in values file then the namespace of the resources is used
+*/}}
+{{- define "gateway-extension.referenceNamespaces" -}}
+{{- $kubernetes := .Values.config.kubernetes | default dict -}}
+{{- toYaml ($kubernetes.namespaces | default (list (include "gateway-extension.namespace" .))) -}}
+{{- end -}}
+
 {{/*
 Create chart name and version as used by the chart label.
 */}}
@@ -12,6 +12,7 @@ import (
 	"github.com/openkcm/common-sdk/pkg/commoncfg"
 	"github.com/openkcm/common-sdk/pkg/health"
 	"github.com/openkcm/common-sdk/pkg/logger"
+	"github.com/openkcm/common-sdk/pkg/otlp"
 	"github.com/openkcm/common-sdk/pkg/status"
 	"github.com/openkcm/common-sdk/pkg/utils"
 	"github.com/samber/oops"
@@ -64,12 +65,12 @@ func run(ctx context.Context) error {
 			Wrapf(err, "Failed to initialise the logger")
 	}
 
-	// // OpenTelemetry initialisation
-	// err = otlp.Init(ctx, &cfg.Application, &cfg.Telemetry, &cfg.Logger)
-	// if err != nil {
-	// 	return oops.In("main").
-	// 		Wrapf(err, "Failed to load the telemetry")
-	// }
+	// OpenTelemetry initialisation
+	err = otlp.Init(ctx, &cfg.Application, &cfg.Telemetry, &cfg.Logger)
+	if err != nil {
+		return oops.In("main").
+			Wrapf(err, "Failed to load the telemetry")
+	}
 
 	// Status Server Initialisation
 	go func() {
@@ -6,7 +6,9 @@ import (
 	"os"
 
 	"github.com/openkcm/common-sdk/pkg/commongrpc"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/oops"
+	"google.golang.org/grpc"
 
 	pb "github.com/envoyproxy/gateway/proto/extension"
 	slogctx "github.com/veqryn/slog-context"
@@ -17,12 +19,11 @@ import (
 
 // StartGRPCServer starts the gRPC server using the given config.
 func StartGRPCServer(ctx context.Context, cfg *config.Config) error {
-	// Create the gRPC server
-	grpcServer := commongrpc.NewServer(ctx, &cfg.Listener.TCP)
-
-	// Register the servers with the gRPC server
-
-	pb.RegisterEnvoyGatewayExtensionServer(grpcServer, extensions.NewGatewayExtension(&cfg.FeatureGates))
+	// The metrics are served by the status server from the default registry
+	grpcServer, err := newGRPCServer(ctx, cfg, prometheus.DefaultRegisterer)
+	if err != nil {
+		return err
+	}
 
 	// Create the listener
 	listener, err := createListener(ctx, cfg)
@@ -55,6 +56,65 @@ func StartGRPCServer(ctx context.Context, cfg *config.Config) error {
 	return nil
 }
 
+// newGRPCServer returns the gRPC server serving the GatewayExtension configured from the given config. The
+// metrics of the hooks are registered with the registerer.
+func newGRPCServer(ctx context.Context, cfg *config.Config, reg prometheus.Registerer) (*grpc.Server, error) {
+	// Create the gRPC server
+	grpcServer := commongrpc.NewServer(ctx, &cfg.Listener.TCP)
+
+	dnsLookupFamily, err := extensions.ParseDNSLookupFamily(cfg.JWKS.DNSLookupFamily)
+	if err != nil {
+		return nil, oops.In("TCP GatewayExtension").
+			WithContext(ctx).
+			Wrapf(err, "Failed to configure the JWKS clusters")
+	}
+
+	opts := []extensions.Option{
+		extensions.WithOIDCDiscovery(extensions.NewOIDCDiscovery(
+			cfg.Discovery.Timeout,
+			cfg.Discovery.TTL,
+			cfg.Discovery.FailureTTL,
+		)),
+		extensions.WithDNSLookupFamily(dnsLookupFamily),
+		extensions.WithJWTCache(!cfg.JWTCache.Disabled, cfg.JWTCache.Size),
+	}
+
+	if cfg.JWKS.Proxy != "" {
+		proxy, err := extensions.ParseProxyURI(cfg.JWKS.Proxy)
+		if err != nil {
+			return nil, oops.In("TCP GatewayExtension").
+				WithContext(ctx).
+				Wrapf(err, "Failed to configure the JWKS clusters")
+		}
+
+		opts = append(opts, extensions.WithJWKSProxy(proxy))
+	}
+
+	if cfg.Telemetry.Metrics.Prometheus.Enabled {
+		metrics, err := extensions.NewMetrics(reg)
+		if err != nil {
+			return nil, oops.In("TCP GatewayExtension").
+				WithContext(ctx).
+				Wrapf(err, "Failed to register the metrics")
+		}
+
+		opts = append(opts, extensions.WithMetrics(metrics))
+	}
+
+	kubeClient, err := newKubernetesClient(ctx, cfg.Kubernetes.Namespaces)
+	if err != nil {
+		slogctx.Warn(ctx, "Kubernetes client not available; ConfigMap and Secret references cannot be resolved", "error", err)
+	} else {
+		opts = append(opts, extensions.WithKubernetesClient(kubeClient))
+	}
+
+	// Register the servers with the gRPC server
+
+	pb.RegisterEnvoyGatewayExtensionServer(grpcServer, extensions.NewGatewayExtension(&cfg.FeatureGates, opts...))
+
+	return grpcServer, nil
+}
+
 func createListener(ctx context.Context, cfg *config.Config) (net.Listener, error) {
 	if cfg.Listener.Type == "" {
 		cfg.Listener.Type = config.TCPListener
@@ -3,11 +3,28 @@ package business
 import (
 	"context"
 	"fmt"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/envoyproxy/gateway/proto/extension"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/openkcm/gateway-extension/internal/config"
 )
@@ -80,3 +97,165 @@ func TestStartGRPCServer(t *testing.T) {
 		})
 	}
 }
+
+// newTestClient serves the gRPC server in memory until the end of the test, and returns a client of it.
+func newTestClient(t *testing.T, server *grpc.Server) pb.EnvoyGatewayExtensionClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+	})
+
+	return pb.NewEnvoyGatewayExtensionClient(conn)
+}
+
+// unknownResource is an extension resource of a kind not served by the extension.
+var unknownResource = &pb.ExtensionResource{
+	UnstructuredBytes: []byte(`{"apiVersion":"test.openkcm.io/v1","kind":"Unknown"}`),
+}
+
+func TestNewGRPCServer_Metrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{
+			name:    "Prometheus enabled",
+			enabled: true,
+			want: `
+# HELP gateway_extension_hook_calls_total Number of calls of the extension hooks.
+# TYPE gateway_extension_hook_calls_total counter
+gateway_extension_hook_calls_total{hook="PostHTTPListenerModify"} 2
+gateway_extension_hook_calls_total{hook="PostTranslateModify"} 1
+# HELP gateway_extension_errors_total Number of errors of the extension hooks, including the skipped resources, by reason.
+# TYPE gateway_extension_errors_total counter
+gateway_extension_errors_total{hook="PostHTTPListenerModify",reason="UnknownResourceKind"} 2
+`,
+		},
+		{
+			name:    "Prometheus disabled",
+			enabled: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Listener: config.Listener{TCP: commoncfg.GRPCServer{MaxRecvMsgSize: 1 << 20}}}
+			cfg.Telemetry.Metrics.Prometheus.Enabled = tt.enabled
+
+			reg := prometheus.NewRegistry()
+
+			server, err := newGRPCServer(t.Context(), cfg, reg)
+			require.NoError(t, err)
+
+			client := newTestClient(t, server)
+
+			for _, name := range []string{"kms/gateway/http", "kms/gateway/https"} {
+				_, err = client.PostHTTPListenerModify(t.Context(), &pb.PostHTTPListenerModifyRequest{
+					Listener: &listenerv3.Listener{Name: name},
+					PostListenerContext: &pb.PostHTTPListenerExtensionContext{
+						ExtensionResources: []*pb.ExtensionResource{unknownResource},
+					},
+				})
+				require.NoError(t, err)
+			}
+
+			_, err = client.PostTranslateModify(t.Context(), &pb.PostTranslateModifyRequest{})
+			require.NoError(t, err)
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(tt.want),
+				"gateway_extension_hook_calls_total", "gateway_extension_errors_total"))
+
+			// The latency of each call is observed under the label of its hook
+			durations := make(map[string]uint64)
+
+			families, err := reg.Gather()
+			require.NoError(t, err)
+
+			for _, family := range families {
+				if family.GetName() != "gateway_extension_hook_duration_seconds" {
+					continue
+				}
+
+				for _, m := range family.GetMetric() {
+					for _, label := range m.GetLabel() {
+						if label.GetName() == "hook" {
+							durations[label.GetValue()] = m.GetHistogram().GetSampleCount()
+						}
+					}
+				}
+			}
+
+			if !tt.enabled {
+				assert.Empty(t, families)
+				return
+			}
+
+			assert.Equal(t, map[string]uint64{"PostHTTPListenerModify": 2, "PostTranslateModify": 1}, durations)
+		})
+	}
+}
+
+func TestNewGRPCServer_Tracing(t *testing.T) {
+	// The server options read the global provider when the server is created
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		_ = tp.Shutdown(context.Background())
+	})
+
+	cfg := &config.Config{Listener: config.Listener{TCP: commoncfg.GRPCServer{MaxRecvMsgSize: 1 << 20}}}
+
+	server, err := newGRPCServer(t.Context(), cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	_, err = newTestClient(t, server).PostHTTPListenerModify(t.Context(), &pb.PostHTTPListenerModifyRequest{
+		Listener: &listenerv3.Listener{Name: "kms/gateway/https"},
+		PostListenerContext: &pb.PostHTTPListenerExtensionContext{
+			ExtensionResources: []*pb.ExtensionResource{unknownResource},
+		},
+	})
+	require.NoError(t, err)
+
+	// The hook adds the xDS resource of the request to the server span of the call, ended once the
+	// response is sent.
+	var spans []tracetest.SpanStub
+
+	assert.Eventually(t, func() bool {
+		spans = nil
+
+		for _, span := range exporter.GetSpans() {
+			if span.Name == "envoygateway.extension.EnvoyGatewayExtension/PostHTTPListenerModify" {
+				spans = append(spans, span)
+			}
+		}
+
+		return len(spans) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+		assert.Subset(t, spans[0].Attributes, []attribute.KeyValue{
+			attribute.String("xds.listener", "kms/gateway/https"),
+			attribute.Int("extension.resources", 1),
+		})
+	}
+}
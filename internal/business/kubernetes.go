@@ -0,0 +1,79 @@
+package business
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	slogctx "github.com/veqryn/slog-context"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+// newKubernetesClient creates a client for the cluster the application runs in or the one selected by
+// the kubeconfig. The ConfigMaps, Secrets and BackendTLSPolicies are read from an informer cache limited
+// to the given namespaces, or spanning all of them if empty. The JWTExemptions are cached from all the
+// namespaces, as they live along with the Gateways. The JWTProviders are read from the API server, as
+// their status is updated.
+func newKubernetesClient(ctx context.Context, namespaces []string) (client.Client, error) {
+	restCfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := runtime.NewScheme()
+
+	err = clientgoscheme.AddToScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v1alpha1.AddToScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	// The BackendTLSPolicies can hold the CA certificates of the JWKS servers
+	err = gwapiv1.AddToScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheOpts := cache.Options{Scheme: scheme}
+	if len(namespaces) > 0 {
+		cacheOpts.DefaultNamespaces = make(map[string]cache.Config, len(namespaces))
+		for _, namespace := range namespaces {
+			cacheOpts.DefaultNamespaces[namespace] = cache.Config{}
+		}
+
+		cacheOpts.ByObject = map[client.Object]cache.ByObject{
+			&v1alpha1.JWTExemption{}: {Namespaces: map[string]cache.Config{cache.AllNamespaces: {}}},
+		}
+	}
+
+	// The informers are started on the first read of each kind
+	informers, err := cache.New(restCfg, cacheOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := informers.Start(ctx)
+		if err != nil {
+			slogctx.Error(ctx, "Failed to start the kubernetes cache", "error", err)
+		}
+	}()
+
+	return client.New(restCfg, client.Options{
+		Scheme: scheme,
+		Cache: &client.CacheOptions{
+			Reader:     informers,
+			DisableFor: []client.Object{&v1alpha1.JWTProvider{}},
+		},
+	})
+}
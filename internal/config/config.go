@@ -18,7 +18,11 @@ const (
 type Config struct {
 	commoncfg.BaseConfig `mapstructure:",squash"`
 
-	Listener Listener `yaml:"listener"`
+	Listener   Listener   `yaml:"listener"`
+	Discovery  Discovery  `yaml:"discovery"`
+	JWKS       JWKS       `yaml:"jwks"`
+	JWTCache   JWTCache   `yaml:"jwtCache"`
+	Kubernetes Kubernetes `yaml:"kubernetes"`
 }
 
 type Listener struct {
@@ -33,3 +37,41 @@ type UNIX struct {
 	// SocketPath is the Unix Path to listen on for gRPC requests
 	SocketPath string `yaml:"socketPath" default:"/etc/envoy/gateway/extension.sock"`
 }
+
+// Discovery configures the OpenID configuration discovery of the JWKS URI of the
+// JWT providers only defining an issuer.
+type Discovery struct {
+	// Timeout bounds each discovery request
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+	// TTL is the duration a discovered JWKS URI is cached before being refreshed in the background. The issuers
+	// not read within a TTL are evicted
+	TTL time.Duration `yaml:"ttl" default:"10m"`
+	// FailureTTL is the duration a discovery failure is cached before the issuer is queried again
+	FailureTTL time.Duration `yaml:"failureTTL" default:"30s"`
+}
+
+// JWKS configures the clusters fetching the JWKS of the JWT providers.
+type JWKS struct {
+	// DNSLookupFamily is the IP address family used to resolve the JWKS hostnames, unless defined by
+	// the JWT provider; one of V4_ONLY, V4_PREFERRED, V6_ONLY or ALL
+	DNSLookupFamily string `yaml:"dnsLookupFamily" default:"V4_ONLY"`
+	// Proxy is the http or https URI of the HTTP proxy the JWKS are fetched through, unless defined by
+	// the JWT provider. The JWKS are fetched directly if empty.
+	Proxy string `yaml:"proxy"`
+}
+
+// JWTCache configures the cache of the verified JWTs of the JWT providers, unless defined by the
+// JWT provider.
+type JWTCache struct {
+	// Disabled turns the cache off
+	Disabled bool `yaml:"disabled"`
+	// Size is the number of verified JWTs cached per JWT provider
+	Size uint32 `yaml:"size" default:"100"`
+}
+
+// Kubernetes configures the access to the resources referenced by the JWT providers.
+type Kubernetes struct {
+	// Namespaces are the namespaces the referenced ConfigMaps, Secrets and BackendTLSPolicies are cached
+	// from. All the namespaces are cached if empty.
+	Namespaces []string `yaml:"namespaces"`
+}
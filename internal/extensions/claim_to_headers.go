@@ -0,0 +1,225 @@
+package extensions
+
+import (
+	"fmt"
+	"strings"
+
+	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	luav3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const (
+	// ClaimToHeadersFilterName is the name of the Lua filter copying the encoded claims to headers.
+	ClaimToHeadersFilterName = "envoy.filters.http.lua/openkcm_claim_to_headers"
+)
+
+// claimHeader copies a claim of the payload stored under a metadata key into a header.
+type claimHeader struct {
+	payloadKey string
+	claimPath  []string
+	headerName string
+	encoding   v1alpha1.ClaimEncoding
+}
+
+// isNativeClaimEncoding reports if the claim is copied by the JWT authentication filter itself.
+func isNativeClaimEncoding(encoding v1alpha1.ClaimEncoding) bool {
+	return encoding == "" || encoding == v1alpha1.ClaimEncodingNative
+}
+
+// buildClaimHeaders returns the claims of a JWT provider that have to be encoded by the Lua filter.
+func buildClaimHeaders(payloadKey string, headers []*v1alpha1.JWTClaimToHeader) []claimHeader {
+	claimHeaders := make([]claimHeader, 0, len(headers))
+
+	for _, header := range headers {
+		if isNativeClaimEncoding(header.Encoding) {
+			continue
+		}
+
+		claimHeaders = append(claimHeaders, claimHeader{
+			payloadKey: payloadKey,
+			claimPath:  strings.Split(header.ClaimName, claimPathSeparator),
+			headerName: strings.ToLower(header.HeaderName),
+			encoding:   header.Encoding,
+		})
+	}
+
+	return claimHeaders
+}
+
+// buildClaimToHeadersFilter returns the Lua filter writing the claims into the headers. The filter
+// reads the verified payloads from the dynamic metadata of the JWT authentication filter, and always
+// removes the headers sent by the client. No filter is returned if there is no claim to encode.
+func buildClaimToHeadersFilter(claimHeaders []claimHeader) ([]*hcm.HttpFilter, error) {
+	if len(claimHeaders) == 0 {
+		return nil, nil
+	}
+
+	anyFilterConfig, err := newAny(&luav3.Lua{
+		DefaultSourceCode: &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineString{InlineString: buildClaimToHeadersScript(claimHeaders)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []*hcm.HttpFilter{{
+		Name: ClaimToHeadersFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{
+			TypedConfig: anyFilterConfig,
+		},
+	}}, nil
+}
+
+// buildClaimToHeadersScript returns the Lua script of the claim headers.
+func buildClaimToHeadersScript(claimHeaders []claimHeader) string {
+	var sb strings.Builder
+
+	sb.WriteString("local claim_headers = {\n")
+
+	for _, h := range claimHeaders {
+		path := make([]string, 0, len(h.claimPath))
+		for _, segment := range h.claimPath {
+			path = append(path, luaQuote(segment))
+		}
+
+		fmt.Fprintf(&sb, "  {payload = %s, path = {%s}, header = %s, encoding = %s},\n",
+			luaQuote(h.payloadKey), strings.Join(path, ", "), luaQuote(h.headerName), luaQuote(string(h.encoding)))
+	}
+
+	sb.WriteString("}\n")
+	sb.WriteString(fmt.Sprintf("local metadata_namespace = %s\n", luaQuote(egv1a1.EnvoyFilterJWTAuthn.String())))
+	sb.WriteString(claimToHeadersScript)
+
+	return sb.String()
+}
+
+// luaQuote returns the value as a Lua string literal.
+func luaQuote(value string) string {
+	var sb strings.Builder
+
+	sb.WriteByte('"')
+
+	for i := range len(value) {
+		c := value[i]
+
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+const claimToHeadersScript = `
+local json_escapes = {['"'] = '\\"', ['\\'] = '\\\\', ['\b'] = '\\b', ['\f'] = '\\f', ['\n'] = '\\n', ['\r'] = '\\r', ['\t'] = '\\t'}
+
+local function is_array(value)
+  local count = 0
+  for _ in pairs(value) do
+    count = count + 1
+  end
+  for i = 1, count do
+    if value[i] == nil then
+      return false
+    end
+  end
+  return true
+end
+
+local function encode_json(value)
+  local kind = type(value)
+  if kind == "string" then
+    return '"' .. value:gsub('[%c"\\]', function(c)
+      return json_escapes[c] or string.format("\\u%04x", c:byte())
+    end) .. '"'
+  elseif kind == "number" or kind == "boolean" then
+    return tostring(value)
+  elseif kind == "table" then
+    local items = {}
+    if is_array(value) then
+      for _, item in ipairs(value) do
+        table.insert(items, encode_json(item))
+      end
+      return "[" .. table.concat(items, ",") .. "]"
+    end
+    local keys = {}
+    for key in pairs(value) do
+      table.insert(keys, tostring(key))
+    end
+    table.sort(keys)
+    for _, key in ipairs(keys) do
+      table.insert(items, encode_json(key) .. ":" .. encode_json(value[key]))
+    end
+    return "{" .. table.concat(items, ",") .. "}"
+  end
+  return "null"
+end
+
+local function encode(handle, value, encoding)
+  if encoding == "Comma" then
+    if type(value) ~= "table" then
+      return tostring(value)
+    end
+    if not is_array(value) then
+      return encode_json(value)
+    end
+    local items = {}
+    for _, item in ipairs(value) do
+      if type(item) == "table" then
+        table.insert(items, encode_json(item))
+      else
+        table.insert(items, tostring(item))
+      end
+    end
+    return table.concat(items, ",")
+  elseif encoding == "JSON" then
+    return encode_json(value)
+  elseif encoding == "Base64JSON" then
+    return handle:base64Escape(encode_json(value))
+  end
+  return nil
+end
+
+function envoy_on_request(request_handle)
+  local headers = request_handle:headers()
+  for _, claim_header in ipairs(claim_headers) do
+    headers:remove(claim_header.header)
+  end
+
+  local metadata = request_handle:streamInfo():dynamicMetadata():get(metadata_namespace)
+  if metadata == nil then
+    return
+  end
+
+  for _, claim_header in ipairs(claim_headers) do
+    local value = metadata[claim_header.payload]
+    for _, segment in ipairs(claim_header.path) do
+      if type(value) ~= "table" then
+        value = nil
+        break
+      end
+      value = value[segment]
+    end
+
+    if value ~= nil then
+      local encoded = encode(request_handle, value, claim_header.encoding)
+      if encoded ~= nil then
+        headers:replace(claim_header.header, encoded)
+      end
+    end
+  end
+end
+`
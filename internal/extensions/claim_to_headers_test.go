@@ -0,0 +1,126 @@
+package extensions
+
+import (
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	luav3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openkcm/gateway-extension/api"
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func TestBuildClaimHeaders(t *testing.T) {
+	headers := []*v1alpha1.JWTClaimToHeader{
+		{HeaderName: "X-Subject", ClaimName: "sub"},
+		{HeaderName: "X-Tenant", ClaimName: "tenant", Encoding: v1alpha1.ClaimEncodingNative},
+		{HeaderName: "X-Groups", ClaimName: "groups", Encoding: v1alpha1.ClaimEncodingComma},
+		{HeaderName: "X-Roles", ClaimName: "realm_access.roles", Encoding: v1alpha1.ClaimEncodingBase64JSON},
+	}
+
+	want := []claimHeader{
+		{payloadKey: "Provider", claimPath: []string{"groups"}, headerName: "x-groups", encoding: v1alpha1.ClaimEncodingComma},
+		{payloadKey: "Provider", claimPath: []string{"realm_access", "roles"}, headerName: "x-roles", encoding: v1alpha1.ClaimEncodingBase64JSON},
+	}
+
+	assert.Equal(t, want, buildClaimHeaders("Provider", headers))
+	assert.Len(t, buildJwtClaimToHeader(headers), 2)
+}
+
+func TestLuaQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "Plain", value: "x-groups", want: `"x-groups"`},
+		{name: "Quote and backslash", value: `a"b\c`, want: `"a\"b\\c"`},
+		{name: "Control and non ASCII", value: "a\nbé", want: `"a\010b\195\169"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, luaQuote(tt.value))
+		})
+	}
+}
+
+func TestBuildClaimToHeadersScript(t *testing.T) {
+	script := buildClaimToHeadersScript([]claimHeader{
+		{payloadKey: "Provider", claimPath: []string{"realm_access", "roles"}, headerName: "x-roles", encoding: v1alpha1.ClaimEncodingJSON},
+	})
+
+	assert.Contains(t, script, `{payload = "Provider", path = {"realm_access", "roles"}, header = "x-roles", encoding = "JSON"},`)
+	assert.Contains(t, script, `local metadata_namespace = "envoy.filters.http.jwt_authn"`)
+	assert.Contains(t, script, "function envoy_on_request(request_handle)")
+}
+
+func TestGatewayExtension_ProcessJWTProviders_ClaimToHeaders(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	jwtp := &v1alpha1.JWTProvider{
+		TypeMeta: metav1.TypeMeta{Kind: api.JWTProviderKind, APIVersion: api.JWTProviderV1Alpha1},
+		Spec: v1alpha1.JWTProviderSpec{
+			Name:   "Provider",
+			Issuer: "https://example.com",
+			LocalJwks: &v1alpha1.LocalJWKS{
+				Inline: ptr.To(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`),
+			},
+			ClaimToHeaders: []*v1alpha1.JWTClaimToHeader{
+				{HeaderName: "X-Subject", ClaimName: "sub"},
+				{HeaderName: "X-Groups", ClaimName: "groups", Encoding: v1alpha1.ClaimEncodingComma},
+			},
+		},
+	}
+
+	listener := &listenerv3.Listener{
+		DefaultFilterChain: &listenerv3.FilterChain{
+			Filters: []*listenerv3.Filter{{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &listenerv3.Filter_TypedConfig{
+					TypedConfig: mustNewAny(&hcm.HttpConnectionManager{
+						HttpFilters: []*hcm.HttpFilter{{Name: wellknown.Router}},
+					}),
+				},
+			}},
+		},
+	}
+
+	resp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener: listener,
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{{UnstructuredBytes: mustMarshalResource(jwtp)}},
+		},
+	})
+	assert.NoError(t, err)
+
+	httpConManager, _, err := findHCM(resp.GetListener().GetDefaultFilterChain())
+	if !assert.NoError(t, err) || !assert.Len(t, httpConManager.GetHttpFilters(), 3) {
+		return
+	}
+
+	filters := httpConManager.GetHttpFilters()
+	assert.Equal(t, "envoy.filters.http.jwt_authn", filters[0].GetName())
+	assert.Equal(t, ClaimToHeadersFilterName, filters[1].GetName())
+	assert.Equal(t, wellknown.Router, filters[2].GetName())
+
+	jwtAuthn, _, err := findJwtAuthenticationFilter(filters)
+	if assert.NoError(t, err) {
+		claimToHeaders := jwtAuthn.GetProviders()["Provider"].GetClaimToHeaders()
+		if assert.Len(t, claimToHeaders, 1) {
+			assert.Equal(t, "X-Subject", claimToHeaders[0].GetHeaderName())
+		}
+	}
+
+	lua := &luav3.Lua{}
+	if assert.NoError(t, filters[1].GetTypedConfig().UnmarshalTo(lua)) {
+		assert.Contains(t, lua.GetDefaultSourceCode().GetInlineString(), `header = "x-groups", encoding = "Comma"`)
+	}
+}
@@ -2,15 +2,17 @@ package extensions
 
 import (
 	"context"
-	"encoding/json"
+	"net/url"
 	"sync"
 
 	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	pb "github.com/envoyproxy/gateway/proto/extension"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	slogctx "github.com/veqryn/slog-context"
 
-	"github.com/openkcm/gateway-extension/api"
 	gev1a1 "github.com/openkcm/gateway-extension/api/v1alpha1"
 )
 
@@ -23,16 +25,57 @@ type GatewayExtension struct {
 
 	features *commoncfg.FeatureGates
 
+	// jwtAuthClusters are the JWKS clusters of the listeners, by listener name.
 	jwtAuthClustersMu sync.RWMutex
-	jwtAuthClusters   map[string]*urlCluster
+	jwtAuthClusters   map[string]*listenerJWTAuthClusters
+	translation       uint64
+
+	// routeJWTRequirements are the providers of the route requirements, by requirement name, and
+	// gatewayRouteJWTRequirements the names of the ones selected by the routes of each Gateway.
+	routeJWTAuthnMu             sync.RWMutex
+	routeJWTRequirements        map[string][]*gev1a1.JWTProvider
+	gatewayRouteJWTRequirements map[types.NamespacedName][]string
+
+	// listenerOutputs are the listeners last returned by PostHTTPListenerModify, by listener name.
+	listenerOutputsMu sync.Mutex
+	listenerOutputs   map[string]*listenerOutput
+
+	jwtProviderStatusesMu sync.Mutex
+	jwtProviderStatuses   map[types.NamespacedName]*jwtProviderStatus
+
+	// resources are the handlers of the extension resources.
+	resources *ResourceRegistry
+	metrics   *Metrics
+
+	kubeClient      client.Client
+	discovery       *OIDCDiscovery
+	dnsLookupFamily clusterv3.Cluster_DnsLookupFamily
+	jwksProxy       *url.URL
+	jwtCacheEnabled bool
+	jwtCacheSize    uint32
 }
 
-func NewGatewayExtension(features *commoncfg.FeatureGates) *GatewayExtension {
-	return &GatewayExtension{
+func NewGatewayExtension(features *commoncfg.FeatureGates, opts ...Option) *GatewayExtension {
+	s := &GatewayExtension{
 		features:          features,
 		jwtAuthClustersMu: sync.RWMutex{},
-		jwtAuthClusters:   make(map[string]*urlCluster),
+		jwtAuthClusters:   make(map[string]*listenerJWTAuthClusters),
+
+		routeJWTAuthnMu:             sync.RWMutex{},
+		routeJWTRequirements:        make(map[string][]*gev1a1.JWTProvider),
+		gatewayRouteJWTRequirements: make(map[types.NamespacedName][]string),
+
+		discovery:       NewOIDCDiscovery(DefaultDiscoveryTimeout, DefaultDiscoveryTTL, DefaultDiscoveryFailureTTL),
+		dnsLookupFamily: clusterv3.Cluster_V4_ONLY,
+	}
+
+	s.resources = NewResourceRegistry(defaultResourceHandlers(s)...)
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // PostRouteModify provides a way for extensions to modify a route generated by Envoy Gateway before it is finalized.
@@ -44,13 +87,34 @@ func NewGatewayExtension(features *commoncfg.FeatureGates) *GatewayExtension {
 // PostRouteModify will only be executed if an extension is loaded and only on Routes which were generated from an HTTPRoute
 // that uses extension resources as externalRef filters.
 func (s *GatewayExtension) PostRouteModify(ctx context.Context, req *pb.PostRouteModifyRequest) (*pb.PostRouteModifyResponse, error) {
+	return observeHook(ctx, s.metrics, hookPostRouteModify, req, s.postRouteModify)
+}
+
+// postRouteModify implements PostRouteModify.
+func (s *GatewayExtension) postRouteModify(ctx context.Context, req *pb.PostRouteModifyRequest) (*pb.PostRouteModifyResponse, error) {
 	ctx = slogctx.With(ctx, logXdsGroup, "PostRouteModify")
 
-	slogctx.Info(ctx, "Do nothing ...")
+	slogctx.Info(ctx, "Calling ...")
 
-	return &pb.PostRouteModifyResponse{
+	resp := &pb.PostRouteModifyResponse{
 		Route: req.GetRoute(),
-	}, nil
+	}
+
+	if req.GetRoute() == nil {
+		slogctx.Warn(ctx, "Nil Route")
+		return resp, nil
+	}
+
+	resources := s.resources.Decode(ctx, req.GetPostRouteContext().GetExtensionResources())
+
+	err := s.resources.ModifyRoute(ctx, req.GetRoute(), resources)
+	if err != nil {
+		return nil, err
+	}
+
+	slogctx.Info(ctx, "Called successfully.")
+
+	return resp, nil
 }
 
 // PostClusterModify provides a way for extensions to modify clusters generated by Envoy Gateway for custom backends.
@@ -58,19 +122,45 @@ func (s *GatewayExtension) PostRouteModify(ctx context.Context, req *pb.PostRout
 // control cluster naming and basic configuration. This hook is called when custom backend resources are used
 // in HTTPRoute or GRPCRoute backendRefs.
 func (s *GatewayExtension) PostClusterModify(ctx context.Context, req *pb.PostClusterModifyRequest) (*pb.PostClusterModifyResponse, error) {
+	return observeHook(ctx, s.metrics, hookPostClusterModify, req, s.postClusterModify)
+}
+
+// postClusterModify implements PostClusterModify.
+func (s *GatewayExtension) postClusterModify(ctx context.Context, req *pb.PostClusterModifyRequest) (*pb.PostClusterModifyResponse, error) {
 	ctx = slogctx.With(ctx, logXdsGroup, "PostClusterModify")
 
-	slogctx.Info(ctx, "Do nothing ...")
+	slogctx.Info(ctx, "Calling ...")
 
-	return &pb.PostClusterModifyResponse{
+	resp := &pb.PostClusterModifyResponse{
 		Cluster: req.GetCluster(),
-	}, nil
+	}
+
+	if req.GetCluster() == nil {
+		slogctx.Warn(ctx, "Nil Cluster")
+		return resp, nil
+	}
+
+	resources := s.resources.Decode(ctx, req.GetPostClusterContext().GetBackendExtensionResources())
+
+	err := s.resources.ModifyCluster(ctx, req.GetCluster(), resources)
+	if err != nil {
+		return nil, err
+	}
+
+	slogctx.Info(ctx, "Called successfully.")
+
+	return resp, nil
 }
 
 // PostHTTPListenerModify allows an extension to make changes to a Listener generated by Envoy Gateway before it is finalized.
 // PostHTTPListenerModify is always executed when an extension is loaded. An extension may return nil
 // in order to not make any changes to it.
 func (s *GatewayExtension) PostHTTPListenerModify(ctx context.Context, req *pb.PostHTTPListenerModifyRequest) (*pb.PostHTTPListenerModifyResponse, error) {
+	return observeHook(ctx, s.metrics, hookPostHTTPListenerModify, req, s.postHTTPListenerModify)
+}
+
+// postHTTPListenerModify implements PostHTTPListenerModify.
+func (s *GatewayExtension) postHTTPListenerModify(ctx context.Context, req *pb.PostHTTPListenerModifyRequest) (*pb.PostHTTPListenerModifyResponse, error) {
 	ctx = slogctx.With(ctx, logXdsGroup, "PostHTTPListenerModify")
 
 	slogctx.Info(ctx, "Calling ...")
@@ -79,61 +169,28 @@ func (s *GatewayExtension) PostHTTPListenerModify(ctx context.Context, req *pb.P
 		Listener: req.GetListener(),
 	}
 
-	if req.GetPostListenerContext() == nil {
+	hasRouteJWTRequirements := s.hasRouteJWTRequirements(req.GetListener())
+
+	if req.GetPostListenerContext() == nil && !hasRouteJWTRequirements {
 		slogctx.Warn(ctx, "Nil PostListenerContext")
 		return resp, nil
 	}
 
-	if len(req.GetPostListenerContext().GetExtensionResources()) == 0 {
+	if len(req.GetPostListenerContext().GetExtensionResources()) == 0 && !hasRouteJWTRequirements {
 		slogctx.Info(ctx, "Empty list of extension resources")
 		return resp, nil
 	}
 
-	resources := make(map[string][]any)
-
-	for _, ext := range req.GetPostListenerContext().GetExtensionResources() {
-		var generic api.Generic
-
-		err := json.Unmarshal(ext.GetUnstructuredBytes(), &generic)
-		if err != nil {
-			slogctx.Error(ctx, "Failed to unmarshal the extension", "error", err)
-			continue
-		}
-
-		switch generic.Kind {
-		case api.JWTProviderKind:
-			switch generic.APIVersion {
-			case api.JWTProviderV1Alpha1:
-				{
-					slogctx.Info(ctx, "Found a resource", "yaml", generic)
-
-					jwtProvider := &gev1a1.JWTProvider{}
-
-					err := json.Unmarshal(ext.GetUnstructuredBytes(), jwtProvider)
-					if err != nil {
-						slogctx.Error(ctx, "Failed to unmarshal the v1alpha1.JWTProvider CRD", "error", err)
-						continue
-					}
-
-					_, ok := resources[api.JWTProviderKind]
-					if !ok {
-						resources[api.JWTProviderKind] = make([]any, 0)
-					}
-
-					resources[api.JWTProviderKind] = append(resources[api.JWTProviderKind], jwtProvider)
-				}
-			}
-		}
+	listener, unchanged, err := s.modifyListener(ctx, req.GetListener(), req.GetPostListenerContext().GetExtensionResources())
+	if err != nil {
+		return nil, err
 	}
 
-	for key, ext := range resources {
-		switch key {
-		case api.JWTProviderKind:
-			err := s.ProcessJWTProviders(ctx, req.GetListener(), ext)
-			if err != nil {
-				return nil, err
-			}
-		}
+	resp.Listener = listener
+
+	if unchanged {
+		slogctx.Info(ctx, "Called successfully; The listener is unchanged.")
+		return resp, nil
 	}
 
 	slogctx.Info(ctx, "Called successfully.")
@@ -148,23 +205,51 @@ func (s *GatewayExtension) PostHTTPListenerModify(ctx context.Context, req *pb.P
 // The list of clusters and secrets returned by the extension are used as the final list of all clusters and secrets
 // PostTranslateModify is always executed when an extension is loaded
 func (s *GatewayExtension) PostTranslateModify(ctx context.Context, req *pb.PostTranslateModifyRequest) (*pb.PostTranslateModifyResponse, error) {
+	return observeHook(ctx, s.metrics, hookPostTranslateModify, req, s.postTranslateModify)
+}
+
+// postTranslateModify implements PostTranslateModify.
+func (s *GatewayExtension) postTranslateModify(ctx context.Context, req *pb.PostTranslateModifyRequest) (*pb.PostTranslateModifyResponse, error) {
 	ctx = slogctx.With(ctx, logXdsGroup, "PostTranslateModify")
 
 	resp := &pb.PostTranslateModifyResponse{
-		Clusters: req.GetClusters(),
-		Secrets:  req.GetSecrets(),
+		Clusters:  req.GetClusters(),
+		Secrets:   req.GetSecrets(),
+		Listeners: req.GetListeners(),
+		Routes:    req.GetRoutes(),
 	}
 
 	slogctx.Info(ctx, "Calling ...")
 
+	// The clusters and the outputs of the listeners that vanished are no longer referenced.
+	s.pruneListenerOutputs()
+	s.pruneJWTAuthClusters(ctx)
+
 	clusters, err := s.TranslateModifyClusters(ctx, req.GetClusters())
 	if err != nil {
 		return nil, err
 	}
 
+	listeners, err := s.TranslateModifyListeners(ctx, req.GetListeners())
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := s.TranslateModifySecrets(ctx, req.GetSecrets())
+
+	if s.metrics != nil {
+		s.metrics.setTranslation(s.configuredJWTProviders(), countCustomClusters(clusters), s.discovery.Len())
+	}
+
+	// The route requirements are collected again on the next translation.
+	s.resetRouteJWTRequirements()
+	s.writeJWTProviderStatuses(ctx)
+
 	slogctx.Info(ctx, "Called successfully.")
 
 	resp.Clusters = clusters
+	resp.Listeners = listeners
+	resp.Secrets = secrets
 
 	return resp, nil
 }
@@ -174,6 +259,11 @@ func (s *GatewayExtension) PostTranslateModify(ctx context.Context, req *pb.Post
 // PostVirtualHostModify is always executed when an extension is loaded. An extension may return nil to not make any changes
 // to it.
 func (s *GatewayExtension) PostVirtualHostModify(ctx context.Context, req *pb.PostVirtualHostModifyRequest) (*pb.PostVirtualHostModifyResponse, error) {
+	return observeHook(ctx, s.metrics, hookPostVirtualHostModify, req, s.postVirtualHostModify)
+}
+
+// postVirtualHostModify implements PostVirtualHostModify.
+func (s *GatewayExtension) postVirtualHostModify(ctx context.Context, req *pb.PostVirtualHostModifyRequest) (*pb.PostVirtualHostModifyResponse, error) {
 	ctx = slogctx.With(ctx, logXdsGroup, "PostVirtualHostModify")
 
 	slogctx.Info(ctx, "Calling ...")
@@ -192,6 +282,13 @@ func (s *GatewayExtension) PostVirtualHostModify(ctx context.Context, req *pb.Po
 		return nil, err
 	}
 
+	err = s.ApplyJWTExemptions(ctx, req.GetVirtualHost())
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordRouteJWTRequirements(ctx, req.GetVirtualHost())
+
 	slogctx.Info(ctx, "Called successfully.")
 
 	return resp, nil
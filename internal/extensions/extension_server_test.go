@@ -1,9 +1,12 @@
 package extensions
 
 import (
+	"encoding/json"
 	"fmt"
 	"maps"
+	"net"
 	"net/http"
+	"slices"
 	"testing"
 	"time"
 
@@ -16,7 +19,9 @@ import (
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/apimachinery/pkg/types"
 
 	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
@@ -27,8 +32,10 @@ import (
 	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 
 	"github.com/openkcm/gateway-extension/internal/extensions/testdata"
+	"github.com/openkcm/gateway-extension/internal/flags"
 )
 
 func mustNewAny(src proto.Message) *anypb.Any {
@@ -40,6 +47,15 @@ func mustNewAny(src proto.Message) *anypb.Any {
 	return v
 }
 
+func mustMarshalResource(resource any) []byte {
+	v, err := json.Marshal(resource)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
 func TestGatewayExtension_PostHTTPListenerModify(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -216,29 +232,45 @@ func TestGatewayExtension_PostHTTPListenerModify(t *testing.T) {
 					"actual  : %s%s", tt.want, got, diff), "PostHTTPListenerModify(%v)", req)
 			}
 
-			if len(s.jwtAuthClusters) == 0 {
+			if len(s.referencedJWTAuthClusters()) == 0 {
 				assert.Fail(t, "No jwt auth clusters processed")
 			}
 		})
 	}
 }
 
-func startWellKnownServer() {
-	err := http.ListenAndServe(":4543", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Add("Content-Type", "application/json")
+func startWellKnownServer(t *testing.T) {
+	t.Helper()
 
-		_, err := w.Write(testdata.OpenIDConfigurationJSON)
-		if err != nil {
-			panic(err)
-		}
-	}))
+	// Bind before returning so the first discovery request cannot race the server start.
+	listener, err := net.Listen("tcp", ":4543")
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+
+	server := &http.Server{
+		ReadHeaderTimeout: time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("Content-Type", "application/json")
+
+			_, err := w.Write(testdata.OpenIDConfigurationJSON)
+			if err != nil {
+				panic(err)
+			}
+		}),
 	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
 }
 
 func TestGatewayExtension_PostHTTPListenerModify_WellKnown(t *testing.T) {
-	go startWellKnownServer()
+	startWellKnownServer(t)
 
 	tests := []struct {
 		name     string
@@ -347,8 +379,8 @@ func TestGatewayExtension_PostHTTPListenerModify_WellKnown(t *testing.T) {
 																JwksSourceSpecifier: &jwtauth3.JwtProvider_RemoteJwks{
 																	RemoteJwks: &jwtauth3.RemoteJwks{
 																		HttpUri: &corev3.HttpUri{
-																			Uri:              "http://www.localhost/oauth2/v3/certs",
-																			HttpUpstreamType: &corev3.HttpUri_Cluster{Cluster: "www_localhost_80|openkcm"},
+																			Uri:              "https://www.localhost/oauth2/v3/certs",
+																			HttpUpstreamType: &corev3.HttpUri_Cluster{Cluster: "www_localhost_443|openkcm"},
 																			Timeout:          durationpb.New(2 * time.Second),
 																		},
 																		AsyncFetch: &jwtauth3.JwksAsyncFetch{
@@ -404,7 +436,7 @@ func TestGatewayExtension_PostHTTPListenerModify_WellKnown(t *testing.T) {
 					"actual  : %s%s", tt.want, got, diff), "PostHTTPListenerModify(%v)", req)
 			}
 
-			if len(s.jwtAuthClusters) == 0 {
+			if len(s.referencedJWTAuthClusters()) == 0 {
 				assert.Fail(t, "No jwt auth clusters processed")
 			}
 		})
@@ -498,6 +530,12 @@ func TestGatewayExtension_PostTranslateModify(t *testing.T) {
 													Filename: envoyTrustBundle,
 												},
 											},
+											MatchTypedSubjectAltNames: []*tlsv3.SubjectAltNameMatcher{{
+												SanType: tlsv3.SubjectAltNameMatcher_DNS,
+												Matcher: &matcherv3.StringMatcher{
+													MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "example.com"},
+												},
+											}},
 										},
 									},
 								},
@@ -528,8 +566,9 @@ func TestGatewayExtension_PostTranslateModify(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &GatewayExtension{
 				features:        tt.features,
-				jwtAuthClusters: maps.Clone(tt.jwtAuthClusters),
+				dnsLookupFamily: clusterv3.Cluster_V4_ONLY,
 			}
+			s.setListenerJWTAuthClusters("kms/gateway/https", maps.Clone(tt.jwtAuthClusters))
 
 			got, err := s.PostTranslateModify(t.Context(), tt.req)
 			if !tt.wantErr(t, err, fmt.Sprintf("PostTranslateModify(%v)", tt.req)) {
@@ -543,7 +582,7 @@ func TestGatewayExtension_PostTranslateModify(t *testing.T) {
 					"actual  : %s%s", tt.want, got, diff), "PostTranslateModify(%v)", tt.req)
 			}
 
-			if len(s.jwtAuthClusters) != len(tt.jwtAuthClusters) {
+			if len(s.referencedJWTAuthClusters()) != len(tt.jwtAuthClusters) {
 				assert.Fail(t, "Expected read jwtAuthClusters")
 			}
 		})
@@ -628,3 +667,264 @@ func TestGatewayExtension_PostVirtualHostModify(t *testing.T) {
 		})
 	}
 }
+
+func TestGatewayExtension_PostRouteModify(t *testing.T) {
+	routeRequirement := routeRequirementName([]types.NamespacedName{{}})
+
+	tests := []struct {
+		name             string
+		features         *commoncfg.FeatureGates
+		req              *extension.PostRouteModifyRequest
+		want             *extension.PostRouteModifyResponse
+		wantRequirements []string
+		wantErr          assert.ErrorAssertionFunc
+	}{
+		{
+			name:     "Route referencing a JWTProvider",
+			features: &commoncfg.FeatureGates{},
+			req: &extension.PostRouteModifyRequest{
+				Route: &routev3.Route{Name: "admin"},
+				PostRouteContext: &extension.PostRouteExtensionContext{
+					ExtensionResources: []*extension.ExtensionResource{
+						{
+							UnstructuredBytes: testdata.ExtensionJSON,
+						},
+					},
+				},
+			},
+			want: &extension.PostRouteModifyResponse{
+				Route: &routev3.Route{
+					Name: "admin",
+					TypedPerFilterConfig: map[string]*anypb.Any{
+						egv1a1.EnvoyFilterJWTAuthn.String(): mustNewAny(&jwtauth3.PerRouteConfig{
+							RequirementSpecifier: &jwtauth3.PerRouteConfig_RequirementName{RequirementName: routeRequirement},
+						}),
+					},
+				},
+			},
+			wantRequirements: []string{routeRequirement},
+			wantErr:          assert.NoError,
+		},
+		{
+			name:     "Route without extension resources",
+			features: &commoncfg.FeatureGates{},
+			req: &extension.PostRouteModifyRequest{
+				Route: &routev3.Route{Name: "public"},
+			},
+			want: &extension.PostRouteModifyResponse{
+				Route: &routev3.Route{Name: "public"},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:     "Disabled through flags",
+			features: &commoncfg.FeatureGates{flags.DisableJWTProviderComputation: true},
+			req: &extension.PostRouteModifyRequest{
+				Route: &routev3.Route{Name: "admin"},
+				PostRouteContext: &extension.PostRouteExtensionContext{
+					ExtensionResources: []*extension.ExtensionResource{
+						{
+							UnstructuredBytes: testdata.ExtensionJSON,
+						},
+					},
+				},
+			},
+			want: &extension.PostRouteModifyResponse{
+				Route: &routev3.Route{Name: "admin"},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:     "Nil route",
+			features: &commoncfg.FeatureGates{},
+			req:      &extension.PostRouteModifyRequest{},
+			want:     &extension.PostRouteModifyResponse{},
+			wantErr:  assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGatewayExtension(tt.features)
+
+			got, err := s.PostRouteModify(t.Context(), tt.req)
+			if !tt.wantErr(t, err, fmt.Sprintf("PostRouteModify(ctx, %v)", tt.req)) {
+				return
+			}
+
+			diff := cmp.Diff(tt.want, got, protocmp.Transform(), protocmp.IgnoreDefaultScalars())
+			if diff != "" {
+				assert.Fail(t, fmt.Sprintf("Not equal: \n"+
+					"expected: %s\n"+
+					"actual  : %s%s", tt.want, got, diff), "PostRouteModify(%v)", tt.req)
+			}
+
+			assert.Equal(t, tt.wantRequirements, slices.Sorted(maps.Keys(s.routeJWTRequirements)))
+		})
+	}
+}
+
+func TestGatewayExtension_PostRouteModify_ListenerRequirement(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	route, err := s.PostRouteModify(t.Context(), &extension.PostRouteModifyRequest{
+		Route: &routev3.Route{Name: "admin"},
+		PostRouteContext: &extension.PostRouteExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{
+				{
+					UnstructuredBytes: testdata.ExtensionJSON,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// The other routes of the virtual host select the listener requirement.
+	virtualHost, err := s.PostVirtualHostModify(t.Context(), &extension.PostVirtualHostModifyRequest{
+		VirtualHost: &routev3.VirtualHost{
+			Name:   "kms/gateway/https/example_com",
+			Routes: []*routev3.Route{route.GetRoute(), {Name: "public"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	// The listener has no policies attached, the provider is only referenced by the route.
+	listener := &listenerv3.Listener{
+		Name: "kms/gateway/https",
+		DefaultFilterChain: &listenerv3.FilterChain{
+			Filters: []*listenerv3.Filter{{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &listenerv3.Filter_TypedConfig{
+					TypedConfig: mustNewAny(&hcm.HttpConnectionManager{}),
+				},
+			}},
+		},
+	}
+
+	other := &listenerv3.Listener{
+		Name:               "kms/other/https",
+		DefaultFilterChain: proto.CloneOf(listener.GetDefaultFilterChain()),
+	}
+
+	resp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener:            listener,
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{},
+	})
+	assert.NoError(t, err)
+
+	httpConManager, _, err := findHCM(resp.GetListener().GetDefaultFilterChain())
+	assert.NoError(t, err)
+
+	jwtAuthn, _, err := findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+	if !assert.NoError(t, err) || !assert.NotNil(t, jwtAuthn) {
+		return
+	}
+
+	assert.Contains(t, jwtAuthn.GetProviders(), "Provider")
+
+	// Every requirement selected by the routes is registered, the other routes are not secured.
+	want := map[string]*jwtauth3.JwtRequirement{
+		routeRequirementName([]types.NamespacedName{{}}): {
+			RequiresType: &jwtauth3.JwtRequirement_ProviderName{ProviderName: "Provider"},
+		},
+		JwtAuthSecureMappingName: {
+			RequiresType: &jwtauth3.JwtRequirement_AllowMissingOrFailed{AllowMissingOrFailed: &emptypb.Empty{}},
+		},
+	}
+
+	diff := cmp.Diff(want, jwtAuthn.GetRequirementMap(), protocmp.Transform())
+	assert.Empty(t, diff)
+
+	for _, r := range virtualHost.GetVirtualHost().GetRoutes() {
+		perRoute := &jwtauth3.PerRouteConfig{}
+		if !assert.NoError(t, r.GetTypedPerFilterConfig()[egv1a1.EnvoyFilterJWTAuthn.String()].UnmarshalTo(perRoute)) {
+			continue
+		}
+
+		assert.Contains(t, jwtAuthn.GetRequirementMap(), perRoute.GetRequirementName(), "route %s", r.GetName())
+	}
+
+	assert.Contains(t, s.referencedJWTAuthClusters(), "example_com_443")
+
+	// The listeners of the other Gateways do not serve the route, they are left untouched.
+	resp, err = s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener:            other,
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{},
+	})
+	assert.NoError(t, err)
+
+	httpConManager, _, err = findHCM(resp.GetListener().GetDefaultFilterChain())
+	assert.NoError(t, err)
+
+	jwtAuthn, _, err = findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+	assert.NoError(t, err)
+	assert.Nil(t, jwtAuthn)
+
+	// The route requirements are forgotten once the translation finished.
+	_, err = s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{})
+	assert.NoError(t, err)
+	assert.False(t, s.hasRouteJWTRequirements(listener))
+}
+
+func TestGatewayExtension_PostHTTPListenerModify_JWTRequirement(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	resp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener: &listenerv3.Listener{
+			Name: "kms/kms-public/https",
+			DefaultFilterChain: &listenerv3.FilterChain{
+				Filters: []*listenerv3.Filter{{
+					Name: wellknown.HTTPConnectionManager,
+					ConfigType: &listenerv3.Filter_TypedConfig{
+						TypedConfig: mustNewAny(&hcm.HttpConnectionManager{}),
+					},
+				}},
+			},
+		},
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{
+				{UnstructuredBytes: testdata.ExtensionJSON},
+				{UnstructuredBytes: testdata.JWTRequirementJSON},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	httpConManager, _, err := findHCM(resp.GetListener().GetDefaultFilterChain())
+	assert.NoError(t, err)
+
+	jwtAuthn, _, err := findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+	if !assert.NoError(t, err) || !assert.NotNil(t, jwtAuthn) {
+		return
+	}
+
+	assert.Contains(t, jwtAuthn.GetRequirementMap(), JwtAuthSecureMappingName)
+
+	diff := cmp.Diff(&jwtauth3.JwtRequirement{
+		RequiresType: &jwtauth3.JwtRequirement_RequiresAny{
+			RequiresAny: &jwtauth3.JwtRequirementOrList{
+				Requirements: []*jwtauth3.JwtRequirement{
+					{RequiresType: &jwtauth3.JwtRequirement_ProviderName{ProviderName: "Provider"}},
+					{RequiresType: &jwtauth3.JwtRequirement_AllowMissing{AllowMissing: &emptypb.Empty{}}},
+				},
+			},
+		},
+	}, jwtAuthn.GetRequirementMap()["kms-admin"], protocmp.Transform())
+	assert.Empty(t, diff)
+
+	// Routes referencing the JWTRequirement select the named requirement.
+	route, err := s.PostRouteModify(t.Context(), &extension.PostRouteModifyRequest{
+		Route: &routev3.Route{Name: "admin"},
+		PostRouteContext: &extension.PostRouteExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{
+				{UnstructuredBytes: testdata.ExtensionJSON},
+				{UnstructuredBytes: testdata.JWTRequirementJSON},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	diff = cmp.Diff(mustNewAny(&jwtauth3.PerRouteConfig{
+		RequirementSpecifier: &jwtauth3.PerRouteConfig_RequirementName{RequirementName: "kms-admin"},
+	}), route.GetRoute().GetTypedPerFilterConfig()[egv1a1.EnvoyFilterJWTAuthn.String()], protocmp.Transform())
+	assert.Empty(t, diff)
+}
@@ -2,6 +2,7 @@ package extensions
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 
@@ -26,3 +27,27 @@ func findHCM(filterChain *listenerv3.FilterChain) (*hcm.HttpConnectionManager, i
 
 	return nil, -1, fmt.Errorf("unable to find HTTPConnectionManager in FilterChain: %s", filterChain.GetName())
 }
+
+// replaceFiltersAfter removes the HTTP filters whose name starts with the given prefix and inserts the
+// given filters right after the filter at the given index, or first if the index is -1.
+func replaceFiltersAfter(filters []*hcm.HttpFilter, index int, prefix string, inserted []*hcm.HttpFilter) []*hcm.HttpFilter {
+	result := make([]*hcm.HttpFilter, 0, len(filters)+len(inserted))
+
+	if index == -1 {
+		result = append(result, inserted...)
+	}
+
+	for i, filter := range filters {
+		if strings.HasPrefix(filter.GetName(), prefix) {
+			continue
+		}
+
+		result = append(result, filter)
+
+		if i == index {
+			result = append(result, inserted...)
+		}
+	}
+
+	return result
+}
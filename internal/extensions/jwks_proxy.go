@@ -0,0 +1,210 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tcpproxyv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	slogctx "github.com/veqryn/slog-context"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+	"github.com/openkcm/gateway-extension/internal/flags"
+)
+
+const (
+	proxyClusterPrefix   = "proxy_"
+	tunnelListenerPrefix = "tunnel_"
+)
+
+var (
+	ErrInvalidProxyURI    = errors.New("invalid proxy URI")
+	ErrNoJWKSProxyTunnels = errors.New("no listeners given to the translation hook to add the JWKS proxy tunnels to")
+)
+
+// ParseProxyURI parses the URI of an HTTP proxy; an http or https URI with a host.
+func ParseProxyURI(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProxyURI, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %q must be an http or https URI with a host", ErrInvalidProxyURI, uri)
+	}
+
+	return u, nil
+}
+
+// proxyURLCluster returns the cluster reaching the HTTP proxy of the URL.
+func proxyURLCluster(u *url.URL) (*urlCluster, error) {
+	c, err := url2Cluster((&url.URL{Scheme: u.Scheme, Host: u.Host}).String())
+	if err != nil {
+		return nil, err
+	}
+
+	c.name = proxyClusterPrefix + c.name
+
+	return c, nil
+}
+
+// jwksProxyCluster returns the cluster of the HTTP proxy the JWKS of the JWT provider is fetched through;
+// the proxy of the JWT provider, if any, or the one of the extension. A nil cluster is returned for
+// direct connections.
+func (s *GatewayExtension) jwksProxyCluster(jwtp *v1alpha1.JWTProvider) (*urlCluster, error) {
+	proxyURI := s.jwksProxy
+
+	if jwtp.Spec.RemoteJwks != nil && jwtp.Spec.RemoteJwks.Proxy != nil {
+		if jwtp.Spec.RemoteJwks.Proxy.URI == "" {
+			return nil, nil
+		}
+
+		u, err := ParseProxyURI(jwtp.Spec.RemoteJwks.Proxy.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		proxyURI = u
+	}
+
+	if proxyURI == nil {
+		return nil, nil
+	}
+
+	return proxyURLCluster(proxyURI)
+}
+
+// setProxy sets the HTTP proxy cluster the upstream is reached through.
+func (c *urlCluster) setProxy(proxy *urlCluster) {
+	if proxy == nil {
+		return
+	}
+
+	c.proxy = proxy
+	c.rename()
+}
+
+// tunnelListenerName returns the name of the internal listener tunnelling the connections of the cluster
+// through its proxy.
+func tunnelListenerName(c *urlCluster) string {
+	return tunnelListenerPrefix + c.CustomName()
+}
+
+// buildTunnelListener returns the internal listener tunnelling the connections of the cluster to its
+// upstream, through HTTP/1.1 CONNECT requests sent to its proxy.
+func buildTunnelListener(c *urlCluster) (*listenerv3.Listener, error) {
+	name := tunnelListenerName(c)
+
+	tcpProxyAny, err := newAny(&tcpproxyv3.TcpProxy{
+		StatPrefix: name,
+		ClusterSpecifier: &tcpproxyv3.TcpProxy_Cluster{
+			Cluster: c.proxy.CustomName(),
+		},
+		TunnelingConfig: &tcpproxyv3.TcpProxy_TunnelingConfig{
+			Hostname: net.JoinHostPort(c.hostname, strconv.FormatUint(uint64(c.port), 10)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &listenerv3.Listener{
+		Name: name,
+		ListenerSpecifier: &listenerv3.Listener_InternalListener{
+			InternalListener: &listenerv3.Listener_InternalListenerConfig{},
+		},
+		FilterChains: []*listenerv3.FilterChain{{
+			Filters: []*listenerv3.Filter{{
+				Name: wellknown.TCPProxy,
+				ConfigType: &listenerv3.Filter_TypedConfig{
+					TypedConfig: tcpProxyAny,
+				},
+			}},
+		}},
+	}, nil
+}
+
+// buildInternalAddress returns the address of the internal listener.
+func buildInternalAddress(listenerName string) *corev3.Address {
+	return &corev3.Address{
+		Address: &corev3.Address_EnvoyInternalAddress{
+			EnvoyInternalAddress: &corev3.EnvoyInternalAddress{
+				AddressNameSpecifier: &corev3.EnvoyInternalAddress_ServerListenerName{
+					ServerListenerName: listenerName,
+				},
+			},
+		},
+	}
+}
+
+func cleanUpListeners(ls []*listenerv3.Listener) []*listenerv3.Listener {
+	listeners := make([]*listenerv3.Listener, 0, len(ls))
+
+	// remove listeners that has as suffix name `openkcm`,
+	for _, l := range ls {
+		if IsCustomName(l.GetName()) {
+			continue
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners
+}
+
+// TranslateModifyListeners returns the listeners with the internal listeners tunnelling the JWKS clusters
+// through their proxy. It fails if the tunnels are needed but the listeners are not given to the hook, as
+// the clusters would connect to internal listeners that do not exist.
+func (s *GatewayExtension) TranslateModifyListeners(ctx context.Context, ls []*listenerv3.Listener) ([]*listenerv3.Listener, error) {
+	s.jwtAuthClustersMu.RLock()
+	defer s.jwtAuthClustersMu.RUnlock()
+
+	if s.features.IsFeatureEnabled(flags.DisableJWTProviderComputation) {
+		return cleanUpListeners(ls), nil
+	}
+
+	jwtAuthClusters := s.referencedJWTAuthClusters()
+
+	if len(ls) == 0 && hasProxiedClusters(jwtAuthClusters) {
+		slogctx.Error(ctx, "Failed to add the JWKS proxy tunnels", "error", ErrNoJWKSProxyTunnels)
+		return nil, ErrNoJWKSProxyTunnels
+	}
+
+	listeners := cleanUpListeners(ls)
+
+	for _, name := range slices.Sorted(maps.Keys(jwtAuthClusters)) {
+		v := jwtAuthClusters[name]
+		if v.proxy == nil {
+			continue
+		}
+
+		listener, err := buildTunnelListener(v)
+		if err != nil {
+			return nil, err
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// hasProxiedClusters reports if any of the clusters is reached through a proxy.
+func hasProxiedClusters(clusters map[string]*urlCluster) bool {
+	for _, v := range clusters {
+		if v.proxy != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,210 @@
+package extensions
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/google/go-cmp/cmp"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tcpproxyv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func mustParseProxyURI(t *testing.T, uri string) *url.URL {
+	t.Helper()
+
+	u, err := ParseProxyURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func TestParseProxyURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{name: "HTTP proxy", uri: "http://proxy.example.com:3128"},
+		{name: "HTTPS proxy", uri: "https://proxy.example.com"},
+		{name: "No scheme", uri: "proxy.example.com:3128", wantErr: true},
+		{name: "SOCKS proxy", uri: "socks5://proxy.example.com:1080", wantErr: true},
+		{name: "No host", uri: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseProxyURI(tt.uri)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidProxyURI)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayExtension_jwksProxyCluster(t *testing.T) {
+	tests := []struct {
+		name      string
+		global    string
+		proxy     *v1alpha1.JWKSProxy
+		wantName  string
+		wantError bool
+	}{
+		{
+			name: "No proxy",
+		},
+		{
+			name:     "Extension proxy",
+			global:   "http://proxy.example.com:3128",
+			wantName: "proxy_proxy_example_com_3128_http",
+		},
+		{
+			name:     "JWT provider proxy",
+			global:   "http://proxy.example.com:3128",
+			proxy:    &v1alpha1.JWKSProxy{URI: "https://egress.example.com"},
+			wantName: "proxy_egress_example_com_443",
+		},
+		{
+			name:   "JWT provider without proxy",
+			global: "http://proxy.example.com:3128",
+			proxy:  &v1alpha1.JWKSProxy{},
+		},
+		{
+			name:      "Invalid JWT provider proxy",
+			proxy:     &v1alpha1.JWKSProxy{URI: "ftp://proxy.example.com"},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.global != "" {
+				opts = append(opts, WithJWKSProxy(mustParseProxyURI(t, tt.global)))
+			}
+
+			s := NewGatewayExtension(&commoncfg.FeatureGates{}, opts...)
+
+			jwtp := &v1alpha1.JWTProvider{Spec: v1alpha1.JWTProviderSpec{
+				RemoteJwks: &v1alpha1.RemoteJWKS{URI: "https://idp.example.com/jwks", Proxy: tt.proxy},
+			}}
+
+			got, err := s.jwksProxyCluster(jwtp)
+			if tt.wantError {
+				assert.ErrorIs(t, err, ErrInvalidProxyURI)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			if tt.wantName == "" {
+				assert.Nil(t, got)
+				return
+			}
+
+			assert.Equal(t, tt.wantName, got.name)
+		})
+	}
+}
+
+func TestGatewayExtension_PostTranslateModify_JWKSProxy(t *testing.T) {
+	proxy, err := proxyURLCluster(mustParseProxyURI(t, "http://proxy.example.com:3128"))
+	assert.NoError(t, err)
+
+	jwks, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	jwks.setProxy(proxy)
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+	s.setListenerJWTAuthClusters("kms/gateway/https", map[string]*urlCluster{jwks.name: jwks})
+
+	gatewayListener := &listenerv3.Listener{Name: "kms/gateway/https"}
+
+	resp, err := s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{
+		Clusters:  []*clusterv3.Cluster{{Name: "httproute/kms/api/rule/0"}},
+		Listeners: []*listenerv3.Listener{gatewayListener, {Name: tunnelListenerName(jwks)}},
+	})
+	assert.NoError(t, err)
+
+	clusters := make(map[string]*clusterv3.Cluster)
+	for _, c := range resp.GetClusters() {
+		clusters[c.GetName()] = c
+	}
+
+	assert.Len(t, clusters, 3)
+	assert.Contains(t, clusters, "httproute/kms/api/rule/0")
+
+	// The JWKS cluster connects to its tunnel, which connects to the proxy cluster
+	jwksCluster := clusters[jwks.CustomName()]
+	if assert.NotNil(t, jwksCluster) {
+		assert.Equal(t, clusterv3.Cluster_STATIC, jwksCluster.GetType())
+		assert.Equal(t, tunnelListenerName(jwks), jwksCluster.GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].
+			GetEndpoint().GetAddress().GetEnvoyInternalAddress().GetServerListenerName())
+		assert.Equal(t, wellknown.TransportSocketTls, jwksCluster.GetTransportSocket().GetName())
+	}
+
+	proxyCluster := clusters["proxy_proxy_example_com_3128_http|openkcm"]
+	if assert.NotNil(t, proxyCluster) {
+		assert.Equal(t, clusterv3.Cluster_STRICT_DNS, proxyCluster.GetType())
+		assert.Nil(t, proxyCluster.GetTransportSocket())
+	}
+
+	// The stale tunnel is replaced
+	listeners := resp.GetListeners()
+	if assert.Len(t, listeners, 2) {
+		assert.Equal(t, gatewayListener, listeners[0])
+
+		want := &listenerv3.Listener{
+			Name: tunnelListenerName(jwks),
+			ListenerSpecifier: &listenerv3.Listener_InternalListener{
+				InternalListener: &listenerv3.Listener_InternalListenerConfig{},
+			},
+			FilterChains: []*listenerv3.FilterChain{{
+				Filters: []*listenerv3.Filter{{
+					Name: wellknown.TCPProxy,
+					ConfigType: &listenerv3.Filter_TypedConfig{
+						TypedConfig: mustNewAny(&tcpproxyv3.TcpProxy{
+							StatPrefix:       tunnelListenerName(jwks),
+							ClusterSpecifier: &tcpproxyv3.TcpProxy_Cluster{Cluster: "proxy_proxy_example_com_3128_http|openkcm"},
+							TunnelingConfig:  &tcpproxyv3.TcpProxy_TunnelingConfig{Hostname: "idp.example.com:443"},
+						}),
+					},
+				}},
+			}},
+		}
+
+		diff := cmp.Diff(want, listeners[1], protocmp.Transform())
+		assert.Empty(t, diff)
+	}
+}
+
+func TestGatewayExtension_PostTranslateModify_JWKSProxyWithoutListeners(t *testing.T) {
+	proxy, err := proxyURLCluster(mustParseProxyURI(t, "http://proxy.example.com:3128"))
+	assert.NoError(t, err)
+
+	jwks, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	jwks.setProxy(proxy)
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+	s.setListenerJWTAuthClusters("kms/gateway/https", map[string]*urlCluster{jwks.name: jwks})
+
+	// The JWKS cluster must not point to a tunnel that is never created
+	_, err = s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{
+		Clusters: []*clusterv3.Cluster{{Name: "httproute/kms/api/rule/0"}},
+	})
+	assert.ErrorIs(t, err, ErrNoJWKSProxyTunnels)
+}
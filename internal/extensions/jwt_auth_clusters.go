@@ -0,0 +1,57 @@
+package extensions
+
+import (
+	"context"
+
+	slogctx "github.com/veqryn/slog-context"
+)
+
+// listenerJWTAuthClusters are the JWKS clusters referenced by the JWT providers of a listener.
+type listenerJWTAuthClusters struct {
+	// translation is the translation the listener was last processed in.
+	translation uint64
+	clusters    map[string]*urlCluster
+}
+
+// setListenerJWTAuthClusters replaces the JWKS clusters referenced by the listener in the current
+// translation. It must be called with the clusters lock held.
+func (s *GatewayExtension) setListenerJWTAuthClusters(listener string, clusters map[string]*urlCluster) {
+	if s.jwtAuthClusters == nil {
+		s.jwtAuthClusters = make(map[string]*listenerJWTAuthClusters)
+	}
+
+	s.jwtAuthClusters[listener] = &listenerJWTAuthClusters{
+		translation: s.translation,
+		clusters:    clusters,
+	}
+}
+
+// referencedJWTAuthClusters returns the union of the JWKS clusters referenced by the listeners, by
+// name. It must be called with the clusters lock held.
+func (s *GatewayExtension) referencedJWTAuthClusters() map[string]*urlCluster {
+	clusters := make(map[string]*urlCluster)
+
+	for _, l := range s.jwtAuthClusters {
+		for name, c := range l.clusters {
+			clusters[name] = c
+		}
+	}
+
+	return clusters
+}
+
+// pruneJWTAuthClusters forgets the JWKS clusters of the listeners not processed in the current translation,
+// as they vanished, and starts the next translation.
+func (s *GatewayExtension) pruneJWTAuthClusters(ctx context.Context) {
+	s.jwtAuthClustersMu.Lock()
+	defer s.jwtAuthClustersMu.Unlock()
+
+	for listener, l := range s.jwtAuthClusters {
+		if l.translation < s.translation {
+			slogctx.Info(ctx, "Pruning the JWKS clusters of a vanished listener", "listener", listener)
+			delete(s.jwtAuthClusters, listener)
+		}
+	}
+
+	s.translation++
+}
@@ -0,0 +1,94 @@
+package extensions
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+// testHTTPListener returns a listener with an HTTP connection manager only routing the requests.
+func testHTTPListener(name string) *listenerv3.Listener {
+	return &listenerv3.Listener{
+		Name: name,
+		DefaultFilterChain: &listenerv3.FilterChain{
+			Filters: []*listenerv3.Filter{{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &listenerv3.Filter_TypedConfig{
+					TypedConfig: mustNewAny(&hcm.HttpConnectionManager{
+						HttpFilters: []*hcm.HttpFilter{{Name: wellknown.Router}},
+					}),
+				},
+			}},
+		},
+	}
+}
+
+func TestGatewayExtension_JWTAuthClusters_PerListener(t *testing.T) {
+	resources := []*extension.ExtensionResource{
+		{UnstructuredBytes: mustMarshalResource(testJWTProvider("public", v1alpha1.JWTProviderSpec{
+			Name: "Public", Issuer: "https://public.example.com",
+			RemoteJwks: &v1alpha1.RemoteJWKS{URI: "https://public.example.com/jwks"},
+			TargetRefs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("public", nil)},
+		}))},
+		{UnstructuredBytes: mustMarshalResource(testJWTProvider("internal", v1alpha1.JWTProviderSpec{
+			Name: "Internal", Issuer: "https://internal.example.com",
+			RemoteJwks: &v1alpha1.RemoteJWKS{URI: "https://internal.example.com/jwks"},
+			TargetRefs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("internal", nil)},
+		}))},
+	}
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	translate := func(t *testing.T, listeners ...string) []string {
+		t.Helper()
+
+		for _, name := range listeners {
+			_, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+				Listener:            testHTTPListener(name),
+				PostListenerContext: &extension.PostHTTPListenerExtensionContext{ExtensionResources: resources},
+			})
+			assert.NoError(t, err)
+		}
+
+		resp, err := s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{
+			Clusters: []*clusterv3.Cluster{{Name: "httproute/kms/api/rule/0"}},
+		})
+		assert.NoError(t, err)
+
+		names := make([]string, 0, len(resp.GetClusters()))
+		for _, c := range resp.GetClusters() {
+			names = append(names, c.GetName())
+		}
+
+		slices.Sort(names)
+
+		return names
+	}
+
+	// The clusters of both Gateways are kept, whatever the listener processed last
+	got := translate(t, "kms/public/https", "kms/internal/https")
+	assert.Equal(t, []string{"httproute/kms/api/rule/0", "internal_example_com_443|openkcm", "public_example_com_443|openkcm"}, got)
+
+	got = translate(t, "kms/internal/https", "kms/public/https")
+	assert.Equal(t, []string{"httproute/kms/api/rule/0", "internal_example_com_443|openkcm", "public_example_com_443|openkcm"}, got)
+
+	// The clusters of a vanished listener are pruned
+	got = translate(t, "kms/public/https")
+	assert.Equal(t, []string{"httproute/kms/api/rule/0", "public_example_com_443|openkcm"}, got)
+	assert.NotContains(t, s.jwtAuthClusters, "kms/internal/https")
+
+	got = translate(t)
+	assert.Equal(t, []string{"httproute/kms/api/rule/0"}, got)
+	assert.Empty(t, s.jwtAuthClusters)
+}
@@ -0,0 +1,377 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	jwtauthnv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	slogctx "github.com/veqryn/slog-context"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+var ErrInvalidJWTExemption = errors.New("invalid JWT exemption")
+
+// ApplyJWTExemptions disables the JWT authentication on the routes of the virtual host matching the rules
+// of the JWTExemptions targeting its Gateway listener. The routes serving requests matching a rule, among
+// others, are preceded by exempted copies narrowed to these requests. Only the routes requiring the JWT
+// providers of the listener are exempted. The JWTExemptions are read from the cluster, in the namespace
+// of the Gateway.
+func (s *GatewayExtension) ApplyJWTExemptions(ctx context.Context, vh *routev3.VirtualHost) error {
+	target, ok := virtualHostTarget(vh)
+	if !ok {
+		slogctx.Debug(ctx, "Skipping the JWTExemptions of the virtual host not named after a Gateway listener", "name", vh.GetName())
+		return nil
+	}
+
+	exemptions, err := s.listJWTExemptions(ctx, target)
+	if err != nil {
+		// The routes are left secured, the exemptions are applied on the next translation.
+		slogctx.Error(ctx, "Failed to list the JWTExemptions", "namespace", target.namespace, "error", err)
+		s.metrics.recordError(ctx, reasonInternal)
+
+		return nil
+	}
+
+	var rules []v1alpha1.JWTExemptionRule
+
+	for _, exemption := range exemptions {
+		err := validateJWTExemption(exemption)
+		if err != nil {
+			slogctx.Error(ctx, "Skipping invalid JWTExemption", "name", exemption.GetName(), "namespace", exemption.GetNamespace(), "error", err)
+			s.metrics.recordError(ctx, reasonInvalidResource)
+
+			continue
+		}
+
+		if !matchesTargetRefs(exemption.GetNamespace(), exemption.Spec.TargetRefs, []listenerTarget{target}) {
+			continue
+		}
+
+		rules = append(rules, exemption.Spec.Rules...)
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	routes := make([]*routev3.Route, 0, len(vh.GetRoutes()))
+
+	for _, r := range vh.GetRoutes() {
+		if !requiresListenerJWTProviders(r) {
+			routes = append(routes, r)
+			continue
+		}
+
+		if isRouteExempted(r, vh.GetDomains(), rules) {
+			slogctx.Info(ctx, "Exempted VirtualHost Route from JWT authentication", "name", r.GetName())
+
+			err := exemptRoute(r)
+			if err != nil {
+				return err
+			}
+
+			routes = append(routes, r)
+
+			continue
+		}
+
+		// The requests of the route matching a rule are served by a dedicated exempted route inserted before it.
+		exemptedRoutes, err := buildExemptedRoutes(r, vh.GetDomains(), rules)
+		if err != nil {
+			return err
+		}
+
+		for _, exempted := range exemptedRoutes {
+			slogctx.Info(ctx, "Inserted VirtualHost Route exempted from JWT authentication", "name", exempted.GetName())
+		}
+
+		routes = append(routes, exemptedRoutes...)
+		routes = append(routes, r)
+	}
+
+	vh.Routes = routes
+
+	return nil
+}
+
+// buildExemptedRoutes returns the exempted copies of the route, narrowed to the requests matching each rule.
+func buildExemptedRoutes(route *routev3.Route, domains []string, rules []v1alpha1.JWTExemptionRule) ([]*routev3.Route, error) {
+	var routes []*routev3.Route
+
+	for i, rule := range rules {
+		if !matchesExemptionHostnames(domains, rule.Hostnames) {
+			continue
+		}
+
+		match, ok := buildExemptedRouteMatch(route.GetMatch(), rule)
+		if !ok {
+			continue
+		}
+
+		exempted := proto.CloneOf(route)
+		exempted.Name = fmt.Sprintf("%s/jwt_exemption/%d", route.GetName(), i)
+		exempted.Match = match
+
+		err := exemptRoute(exempted)
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, exempted)
+	}
+
+	return routes, nil
+}
+
+// buildExemptedRouteMatch returns the match of the requests served by the route that also match the rule
+// path and methods. False is returned if the intersection cannot be expressed by a route match.
+func buildExemptedRouteMatch(match *routev3.RouteMatch, rule v1alpha1.JWTExemptionRule) (*routev3.RouteMatch, bool) {
+	if match == nil {
+		return nil, false
+	}
+
+	exempted := proto.CloneOf(match)
+
+	if !matchesExemptionPath(match, rule) {
+		switch {
+		case rule.PathPrefix != "" && isWithinRoutePath(match, rule.PathPrefix):
+			exempted.PathSpecifier = &routev3.RouteMatch_Prefix{Prefix: rule.PathPrefix}
+		case rule.PathRegex != "" && match.GetPrefix() == "/":
+			exempted.PathSpecifier = &routev3.RouteMatch_SafeRegex{
+				SafeRegex: &matcherv3.RegexMatcher{Regex: rule.PathRegex},
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	if !matchesExemptionMethods(match, rule.Methods) {
+		methods := make([]string, 0, len(rule.Methods))
+		for _, method := range rule.Methods {
+			methods = append(methods, regexp.QuoteMeta(strings.ToUpper(method)))
+		}
+
+		exempted.Headers = append(exempted.Headers, &routev3.HeaderMatcher{
+			Name: requestMethodHeaderName,
+			HeaderMatchSpecifier: &routev3.HeaderMatcher_StringMatch{
+				StringMatch: &matcherv3.StringMatcher{
+					MatchPattern: &matcherv3.StringMatcher_SafeRegex{
+						SafeRegex: &matcherv3.RegexMatcher{Regex: strings.Join(methods, "|")},
+					},
+				},
+			},
+		})
+	}
+
+	return exempted, true
+}
+
+// isWithinRoutePath reports if all the paths starting with the prefix are matched by the route path.
+func isWithinRoutePath(match *routev3.RouteMatch, prefix string) bool {
+	switch specifier := match.GetPathSpecifier().(type) {
+	case *routev3.RouteMatch_Prefix:
+		return strings.HasPrefix(prefix, specifier.Prefix)
+	case *routev3.RouteMatch_PathSeparatedPrefix:
+		return prefix == specifier.PathSeparatedPrefix || strings.HasPrefix(prefix, specifier.PathSeparatedPrefix+"/")
+	default:
+		return false
+	}
+}
+
+// listJWTExemptions returns the JWTExemptions of the namespace of the Gateway listener.
+func (s *GatewayExtension) listJWTExemptions(ctx context.Context, target listenerTarget) ([]*v1alpha1.JWTExemption, error) {
+	if s.kubeClient == nil {
+		slogctx.Debug(ctx, "No kubernetes client configured; JWTExemptions are not applied")
+		return nil, nil
+	}
+
+	list := &v1alpha1.JWTExemptionList{}
+
+	err := s.kubeClient.List(ctx, list, client.InNamespace(target.namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	exemptions := make([]*v1alpha1.JWTExemption, 0, len(list.Items))
+	for i := range list.Items {
+		exemptions = append(exemptions, &list.Items[i])
+	}
+
+	return exemptions, nil
+}
+
+// validateJWTExemption rejects the JWTExemptions without target Gateway, or with an invalid path regex.
+func validateJWTExemption(exemption *v1alpha1.JWTExemption) error {
+	if !hasTargetRefs(exemption.Spec.TargetRefs) {
+		return fmt.Errorf("%w: no target reference specified", ErrInvalidJWTExemption)
+	}
+
+	for _, rule := range exemption.Spec.Rules {
+		if rule.PathRegex == "" {
+			continue
+		}
+
+		_, err := regexp.Compile(rule.PathRegex)
+		if err != nil {
+			return fmt.Errorf("%w: invalid path regex: %w", ErrInvalidJWTExemption, err)
+		}
+	}
+
+	return nil
+}
+
+// requiresListenerJWTProviders reports if the route requires the JWT providers of the listener, rather
+// than the ones referenced by its filters.
+func requiresListenerJWTProviders(route *routev3.Route) bool {
+	return routeRequirementNameOf(route) == JwtAuthSecureMappingName
+}
+
+// exemptRoute disables the JWT authentication, as well as the claim authorization, on the route.
+func exemptRoute(route *routev3.Route) error {
+	jwtCfgAny, err := newAny(&jwtauthnv3.PerRouteConfig{
+		RequirementSpecifier: &jwtauthnv3.PerRouteConfig_Disabled{Disabled: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	// An RBAC per route configuration without rules disables the RBAC filter.
+	rbacCfgAny, err := newAny(&rbacv3.RBACPerRoute{})
+	if err != nil {
+		return err
+	}
+
+	route.TypedPerFilterConfig[egv1a1.EnvoyFilterJWTAuthn.String()] = jwtCfgAny
+	route.TypedPerFilterConfig[ClaimAuthzDenyFilterName] = rbacCfgAny
+	route.TypedPerFilterConfig[ClaimAuthzAllowFilterName] = rbacCfgAny
+
+	return nil
+}
+
+// isRouteExempted reports if the route of a virtual host with the given domains matches any rule.
+func isRouteExempted(route *routev3.Route, domains []string, rules []v1alpha1.JWTExemptionRule) bool {
+	return slices.ContainsFunc(rules, func(rule v1alpha1.JWTExemptionRule) bool {
+		return matchesExemptionPath(route.GetMatch(), rule) &&
+			matchesExemptionMethods(route.GetMatch(), rule.Methods) &&
+			matchesExemptionHostnames(domains, rule.Hostnames)
+	})
+}
+
+// matchesExemptionPath reports if all the paths matched by the route match the rule path.
+func matchesExemptionPath(match *routev3.RouteMatch, rule v1alpha1.JWTExemptionRule) bool {
+	switch {
+	case rule.PathPrefix != "":
+		var routePath string
+
+		switch specifier := match.GetPathSpecifier().(type) {
+		case *routev3.RouteMatch_Prefix:
+			routePath = specifier.Prefix
+		case *routev3.RouteMatch_Path:
+			routePath = specifier.Path
+		case *routev3.RouteMatch_PathSeparatedPrefix:
+			routePath = specifier.PathSeparatedPrefix
+		default:
+			return false
+		}
+
+		return strings.HasPrefix(routePath, rule.PathPrefix)
+	case rule.PathRegex != "":
+		switch specifier := match.GetPathSpecifier().(type) {
+		case *routev3.RouteMatch_Path:
+			re, err := regexp.Compile(rule.PathRegex)
+			if err != nil {
+				return false
+			}
+
+			return re.MatchString(specifier.Path)
+		case *routev3.RouteMatch_SafeRegex:
+			return specifier.SafeRegex.GetRegex() == rule.PathRegex
+		default:
+			return false
+		}
+	default:
+		return true
+	}
+}
+
+// matchesExemptionMethods reports if the route is restricted to one of the methods.
+func matchesExemptionMethods(match *routev3.RouteMatch, methods []string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+
+	for _, header := range match.GetHeaders() {
+		if header.GetName() != requestMethodHeaderName || header.GetInvertMatch() {
+			continue
+		}
+
+		method := header.GetStringMatch().GetExact()
+		if method == "" {
+			method = header.GetExactMatch() //nolint:staticcheck
+		}
+
+		if method != "" && slices.ContainsFunc(methods, func(m string) bool {
+			return strings.EqualFold(m, method)
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesExemptionHostnames reports if all the virtual host domains match one of the hostnames.
+func matchesExemptionHostnames(domains []string, hostnames []string) bool {
+	if len(hostnames) == 0 {
+		return true
+	}
+
+	if len(domains) == 0 {
+		return false
+	}
+
+	for _, domain := range domains {
+		// Domains can be suffixed with a port
+		host, _, _ := strings.Cut(domain, ":")
+
+		if !slices.ContainsFunc(hostnames, func(hostname string) bool {
+			return matchesHostname(host, hostname)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesHostname reports if the domain only covers hosts matching the hostname.
+func matchesHostname(domain string, hostname string) bool {
+	domain = strings.ToLower(domain)
+	hostname = strings.ToLower(hostname)
+
+	if domain == hostname {
+		return true
+	}
+
+	// *.example.com covers foo.example.com and *.foo.example.com, but not example.com
+	suffix, ok := strings.CutPrefix(hostname, "*")
+	if !ok || !strings.HasPrefix(suffix, ".") {
+		return false
+	}
+
+	domain = strings.TrimPrefix(domain, "*")
+
+	return strings.HasSuffix(domain, suffix) && len(domain) > len(suffix)
+}
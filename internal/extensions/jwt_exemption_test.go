@@ -0,0 +1,335 @@
+package extensions
+
+import (
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/google/go-cmp/cmp"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func methodMatch(method string) *routev3.HeaderMatcher {
+	return &routev3.HeaderMatcher{
+		Name: ":method",
+		HeaderMatchSpecifier: &routev3.HeaderMatcher_StringMatch{
+			StringMatch: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: method}},
+		},
+	}
+}
+
+func TestIsRouteExempted(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   *routev3.RouteMatch
+		domains []string
+		rule    v1alpha1.JWTExemptionRule
+		want    bool
+	}{
+		{
+			name:  "Route prefix within the exempted prefix",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/.well-known/jwks"}},
+			rule:  v1alpha1.JWTExemptionRule{PathPrefix: "/.well-known/"},
+			want:  true,
+		},
+		{
+			name:  "Route prefix wider than the exempted prefix",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+			rule:  v1alpha1.JWTExemptionRule{PathPrefix: "/healthz"},
+			want:  false,
+		},
+		{
+			name:  "Exact route path matching the regex",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Path{Path: "/readyz"}},
+			rule:  v1alpha1.JWTExemptionRule{PathRegex: "^/(healthz|readyz)$"},
+			want:  true,
+		},
+		{
+			name: "Same route regex",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_SafeRegex{
+				SafeRegex: &matcherv3.RegexMatcher{Regex: "/status/.*"},
+			}},
+			rule: v1alpha1.JWTExemptionRule{PathRegex: "/status/.*"},
+			want: true,
+		},
+		{
+			name:  "Route prefix with regex rule",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/readyz"}},
+			rule:  v1alpha1.JWTExemptionRule{PathRegex: "^/readyz$"},
+			want:  false,
+		},
+		{
+			name: "Route restricted to an exempted method",
+			match: &routev3.RouteMatch{
+				PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"},
+				Headers:       []*routev3.HeaderMatcher{methodMatch("OPTIONS")},
+			},
+			rule: v1alpha1.JWTExemptionRule{Methods: []string{"options"}},
+			want: true,
+		},
+		{
+			name:  "Route without method restriction",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+			rule:  v1alpha1.JWTExemptionRule{Methods: []string{"OPTIONS"}},
+			want:  false,
+		},
+		{
+			name:    "Virtual host within the exempted hostnames",
+			match:   &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+			domains: []string{"status.example.com", "status.example.com:443"},
+			rule:    v1alpha1.JWTExemptionRule{Hostnames: []string{"*.example.com"}},
+			want:    true,
+		},
+		{
+			name:    "Virtual host with other hostnames",
+			match:   &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+			domains: []string{"status.example.com", "*"},
+			rule:    v1alpha1.JWTExemptionRule{Hostnames: []string{"*.example.com"}},
+			want:    false,
+		},
+		{
+			name:    "Path and hostname",
+			match:   &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Path{Path: "/healthz"}},
+			domains: []string{"kms.example.com"},
+			rule:    v1alpha1.JWTExemptionRule{PathPrefix: "/healthz", Hostnames: []string{"api.example.com"}},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &routev3.Route{Match: tt.match}
+			assert.Equal(t, tt.want, isRouteExempted(route, tt.domains, []v1alpha1.JWTExemptionRule{tt.rule}))
+		})
+	}
+}
+
+func testJWTExemption(namespace string, gateway string, rules ...v1alpha1.JWTExemptionRule) *v1alpha1.JWTExemption {
+	exemption := &v1alpha1.JWTExemption{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "probes"},
+		Spec: v1alpha1.JWTExemptionSpec{
+			Rules: rules,
+		},
+	}
+
+	if gateway != "" {
+		exemption.Spec.TargetRefs = []gwapiv1.LocalPolicyTargetReferenceWithSectionName{{
+			LocalPolicyTargetReference: gwapiv1.LocalPolicyTargetReference{
+				Group: gwapiv1.GroupName, Kind: "Gateway", Name: gwapiv1.ObjectName(gateway),
+			},
+		}}
+	}
+
+	return exemption
+}
+
+func TestGatewayExtension_PostVirtualHostModify_JWTExemption(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	exempted := map[string]*anypb.Any{
+		egv1a1.EnvoyFilterJWTAuthn.String(): mustNewAny(&jwtauth3.PerRouteConfig{
+			RequirementSpecifier: &jwtauth3.PerRouteConfig_Disabled{Disabled: true},
+		}),
+		ClaimAuthzDenyFilterName:  mustNewAny(&rbacv3.RBACPerRoute{}),
+		ClaimAuthzAllowFilterName: mustNewAny(&rbacv3.RBACPerRoute{}),
+	}
+	secured := map[string]*anypb.Any{
+		egv1a1.EnvoyFilterJWTAuthn.String(): mustNewAny(&jwtauth3.PerRouteConfig{
+			RequirementSpecifier: &jwtauth3.PerRouteConfig_RequirementName{RequirementName: JwtAuthSecureMappingName},
+		}),
+	}
+
+	type wantRoute struct {
+		name   string
+		config map[string]*anypb.Any
+	}
+
+	tests := []struct {
+		name        string
+		exemption   *v1alpha1.JWTExemption
+		virtualHost string
+		want        []wantRoute
+	}{
+		{
+			name:        "Exemption targeting the Gateway",
+			exemption:   testJWTExemption("kms", "gateway", v1alpha1.JWTExemptionRule{PathPrefix: "/healthz"}),
+			virtualHost: "kms/gateway/https/kms_example_com",
+			want: []wantRoute{
+				{name: "healthz", config: exempted},
+				{name: "api/jwt_exemption/0", config: exempted},
+				{name: "api", config: secured},
+			},
+		},
+		{
+			name:        "Exemption of the preflight requests",
+			exemption:   testJWTExemption("kms", "gateway", v1alpha1.JWTExemptionRule{Methods: []string{"OPTIONS"}}),
+			virtualHost: "kms/gateway/https/kms_example_com",
+			want: []wantRoute{
+				{name: "healthz/jwt_exemption/0", config: exempted},
+				{name: "healthz", config: secured},
+				{name: "api/jwt_exemption/0", config: exempted},
+				{name: "api", config: secured},
+			},
+		},
+		{
+			name:        "Exemption targeting another Gateway",
+			exemption:   testJWTExemption("kms", "other", v1alpha1.JWTExemptionRule{PathPrefix: "/"}),
+			virtualHost: "kms/gateway/https/kms_example_com",
+			want:        []wantRoute{{name: "healthz", config: secured}, {name: "api", config: secured}},
+		},
+		{
+			name:        "Exemption in another namespace",
+			exemption:   testJWTExemption("other", "gateway", v1alpha1.JWTExemptionRule{PathPrefix: "/"}),
+			virtualHost: "kms/gateway/https/kms_example_com",
+			want:        []wantRoute{{name: "healthz", config: secured}, {name: "api", config: secured}},
+		},
+		{
+			name:        "Exemption without target",
+			exemption:   testJWTExemption("kms", "", v1alpha1.JWTExemptionRule{PathPrefix: "/"}),
+			virtualHost: "kms/gateway/https/kms_example_com",
+			want:        []wantRoute{{name: "healthz", config: secured}, {name: "api", config: secured}},
+		},
+		{
+			name:        "Virtual host not named after a Gateway listener",
+			exemption:   testJWTExemption("kms", "gateway", v1alpha1.JWTExemptionRule{PathPrefix: "/"}),
+			virtualHost: "kms_example_com",
+			want:        []wantRoute{{name: "healthz", config: secured}, {name: "api", config: secured}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.exemption).Build()
+			s := NewGatewayExtension(&commoncfg.FeatureGates{}, WithKubernetesClient(kubeClient))
+
+			resp, err := s.PostVirtualHostModify(t.Context(), &extension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{
+					Name:    tt.virtualHost,
+					Domains: []string{"kms.example.com"},
+					Routes: []*routev3.Route{{
+						Name:  "healthz",
+						Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/healthz"}},
+					}, {
+						Name:  "api",
+						Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+					}},
+				},
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			routes := resp.GetVirtualHost().GetRoutes()
+			if !assert.Len(t, routes, len(tt.want)) {
+				return
+			}
+
+			for i, want := range tt.want {
+				assert.Equal(t, want.name, routes[i].GetName())
+				assert.Empty(t, cmp.Diff(want.config, routes[i].GetTypedPerFilterConfig(), protocmp.Transform()), routes[i].GetName())
+			}
+		})
+	}
+}
+
+func TestBuildExemptedRouteMatch(t *testing.T) {
+	methodsMatch := func(regex string) *routev3.HeaderMatcher {
+		return &routev3.HeaderMatcher{
+			Name: ":method",
+			HeaderMatchSpecifier: &routev3.HeaderMatcher_StringMatch{StringMatch: &matcherv3.StringMatcher{
+				MatchPattern: &matcherv3.StringMatcher_SafeRegex{SafeRegex: &matcherv3.RegexMatcher{Regex: regex}},
+			}},
+		}
+	}
+	queryMatch := []*routev3.QueryParameterMatcher{{Name: "debug"}}
+
+	tests := []struct {
+		name   string
+		match  *routev3.RouteMatch
+		rule   v1alpha1.JWTExemptionRule
+		want   *routev3.RouteMatch
+		wantOk bool
+	}{
+		{
+			name:  "Prefix within the route prefix",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}, QueryParameters: queryMatch},
+			rule:  v1alpha1.JWTExemptionRule{PathPrefix: "/.well-known/"},
+			want: &routev3.RouteMatch{
+				PathSpecifier:   &routev3.RouteMatch_Prefix{Prefix: "/.well-known/"},
+				QueryParameters: queryMatch,
+			},
+			wantOk: true,
+		},
+		{
+			name:  "Prefix within the route separated prefix",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: "/api"}},
+			rule:  v1alpha1.JWTExemptionRule{PathPrefix: "/api/public"},
+			want: &routev3.RouteMatch{
+				PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/api/public"},
+			},
+			wantOk: true,
+		},
+		{
+			name:  "Prefix outside of the route prefix",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: "/api"}},
+			rule:  v1alpha1.JWTExemptionRule{PathPrefix: "/apis"},
+		},
+		{
+			name:  "Regex on the catch-all route",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+			rule:  v1alpha1.JWTExemptionRule{PathRegex: "/status/.*"},
+			want: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_SafeRegex{
+				SafeRegex: &matcherv3.RegexMatcher{Regex: "/status/.*"},
+			}},
+			wantOk: true,
+		},
+		{
+			name:  "Regex on another route",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/api"}},
+			rule:  v1alpha1.JWTExemptionRule{PathRegex: "/api/status/.*"},
+		},
+		{
+			name:  "Methods",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/api"}},
+			rule:  v1alpha1.JWTExemptionRule{Methods: []string{"options", "HEAD"}},
+			want: &routev3.RouteMatch{
+				PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/api"},
+				Headers:       []*routev3.HeaderMatcher{methodsMatch("OPTIONS|HEAD")},
+			},
+			wantOk: true,
+		},
+		{
+			name:  "Prefix and methods",
+			match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+			rule:  v1alpha1.JWTExemptionRule{PathPrefix: "/.well-known/", Methods: []string{"GET"}},
+			want: &routev3.RouteMatch{
+				PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/.well-known/"},
+				Headers:       []*routev3.HeaderMatcher{methodsMatch("GET")},
+			},
+			wantOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := buildExemptedRouteMatch(tt.match, tt.rule)
+			if !assert.Equal(t, tt.wantOk, ok) {
+				return
+			}
+
+			assert.Empty(t, cmp.Diff(tt.want, got, protocmp.Transform()))
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+
+	slogctx "github.com/veqryn/slog-context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+// jwtProviderStatus is the status of a JWTProvider observed during a translation.
+type jwtProviderStatus struct {
+	generation int64
+	conditions map[v1alpha1.JWTProviderConditionType]metav1.Condition
+	jwksURI    string
+	listeners  map[string]struct{}
+}
+
+// jwtProviderStatus returns the status of the JWTProvider observed during the current translation. It must be
+// called with the statuses lock held.
+func (s *GatewayExtension) jwtProviderStatus(jwtp *v1alpha1.JWTProvider) *jwtProviderStatus {
+	if s.jwtProviderStatuses == nil {
+		s.jwtProviderStatuses = make(map[types.NamespacedName]*jwtProviderStatus)
+	}
+
+	name := types.NamespacedName{Namespace: jwtp.GetNamespace(), Name: jwtp.GetName()}
+
+	status, ok := s.jwtProviderStatuses[name]
+	if !ok {
+		status = &jwtProviderStatus{
+			conditions: make(map[v1alpha1.JWTProviderConditionType]metav1.Condition),
+			listeners:  make(map[string]struct{}),
+		}
+		s.jwtProviderStatuses[name] = status
+	}
+
+	status.generation = jwtp.GetGeneration()
+
+	return status
+}
+
+// observeJWTProvider records that the JWTProvider is part of the current translation.
+func (s *GatewayExtension) observeJWTProvider(jwtp *v1alpha1.JWTProvider) {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	s.jwtProviderStatus(jwtp)
+}
+
+// setJWTProviderCondition records a condition of the JWTProvider for the current translation.
+func (s *GatewayExtension) setJWTProviderCondition(jwtp *v1alpha1.JWTProvider, conditionType v1alpha1.JWTProviderConditionType,
+	status metav1.ConditionStatus, reason v1alpha1.JWTProviderConditionReason, message string,
+) {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	s.jwtProviderStatus(jwtp).conditions[conditionType] = metav1.Condition{
+		Type:    string(conditionType),
+		Status:  status,
+		Reason:  string(reason),
+		Message: message,
+	}
+}
+
+// rejectJWTProviderJWKS records that the JWKS of the JWTProvider cannot be resolved.
+func (s *GatewayExtension) rejectJWTProviderJWKS(jwtp *v1alpha1.JWTProvider, reason v1alpha1.JWTProviderConditionReason, err error) {
+	s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionResolvedJwks, metav1.ConditionFalse, reason, err.Error())
+}
+
+// setJWTProviderJWKSURI records the URI the JWKS of the JWTProvider is fetched from.
+func (s *GatewayExtension) setJWTProviderJWKSURI(jwtp *v1alpha1.JWTProvider, uri string) {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	s.jwtProviderStatus(jwtp).jwksURI = uri
+}
+
+// attachJWTProvider records that the JWTProvider is configured on the listener.
+func (s *GatewayExtension) attachJWTProvider(jwtp *v1alpha1.JWTProvider, listener string) {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	s.jwtProviderStatus(jwtp).listeners[listener] = struct{}{}
+}
+
+// takeJWTProviderStatuses returns the statuses observed so far during the translation, and resets them.
+func (s *GatewayExtension) takeJWTProviderStatuses() map[types.NamespacedName]*jwtProviderStatus {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	statuses := s.jwtProviderStatuses
+	s.jwtProviderStatuses = nil
+
+	return statuses
+}
+
+// mergeJWTProviderStatuses records the statuses as observed during the current translation. The given
+// statuses are copied, the later observations taking precedence.
+func (s *GatewayExtension) mergeJWTProviderStatuses(statuses map[types.NamespacedName]*jwtProviderStatus) {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	for name, observed := range statuses {
+		jwtp := &v1alpha1.JWTProvider{}
+		jwtp.Namespace, jwtp.Name, jwtp.Generation = name.Namespace, name.Name, observed.generation
+
+		status := s.jwtProviderStatus(jwtp)
+		maps.Copy(status.conditions, observed.conditions)
+		maps.Copy(status.listeners, observed.listeners)
+
+		if observed.conditions[v1alpha1.JWTProviderConditionResolvedJwks].Status == metav1.ConditionTrue {
+			status.jwksURI = observed.jwksURI
+		}
+	}
+}
+
+// configuredJWTProviders returns the number of JWTProviders configured on listeners during the translation.
+func (s *GatewayExtension) configuredJWTProviders() int {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	n := 0
+
+	for _, status := range s.jwtProviderStatuses {
+		if len(status.listeners) > 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// writeJWTProviderStatuses writes the statuses observed during the translation to the JWTProviders, and
+// resets them for the next translation. Unchanged statuses are not written.
+func (s *GatewayExtension) writeJWTProviderStatuses(ctx context.Context) {
+	s.jwtProviderStatusesMu.Lock()
+	defer s.jwtProviderStatusesMu.Unlock()
+
+	statuses := s.jwtProviderStatuses
+	s.jwtProviderStatuses = nil
+
+	if s.kubeClient == nil {
+		slogctx.Debug(ctx, "No kubernetes client configured; JWTProvider statuses are not written")
+		return
+	}
+
+	for name, observed := range statuses {
+		jwtp := &v1alpha1.JWTProvider{}
+
+		err := s.kubeClient.Get(ctx, name, jwtp)
+		if err != nil {
+			slogctx.Error(ctx, "Failed to get the JWTProvider to update its status",
+				"name", name.Name, "namespace", name.Namespace, "error", err)
+
+			continue
+		}
+
+		status := observed.apply(jwtp.Status.DeepCopy())
+		if equality.Semantic.DeepEqual(&jwtp.Status, status) {
+			continue
+		}
+
+		jwtp.Status = *status
+
+		err = s.kubeClient.Status().Update(ctx, jwtp)
+		if err != nil {
+			slogctx.Error(ctx, "Failed to update the JWTProvider status",
+				"name", name.Name, "namespace", name.Namespace, "error", err)
+
+			continue
+		}
+
+		slogctx.Info(ctx, "Updated the JWTProvider status", "name", name.Name, "namespace", name.Namespace)
+	}
+}
+
+// apply sets the observed conditions, JWKS URI and listeners on the status. The transition time of
+// the conditions is only updated when their status changes.
+func (o *jwtProviderStatus) apply(status *v1alpha1.JWTProviderStatus) *v1alpha1.JWTProviderStatus {
+	for _, conditionType := range []v1alpha1.JWTProviderConditionType{
+		v1alpha1.JWTProviderConditionAccepted,
+		v1alpha1.JWTProviderConditionResolvedJwks,
+		v1alpha1.JWTProviderConditionProgrammed,
+	} {
+		condition, ok := o.condition(conditionType)
+		if !ok {
+			meta.RemoveStatusCondition(&status.Conditions, string(conditionType))
+			continue
+		}
+
+		condition.ObservedGeneration = o.generation
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+
+	status.JwksURI = o.jwksURI
+	status.Listeners = nil
+
+	if len(o.listeners) > 0 {
+		status.Listeners = slices.Sorted(maps.Keys(o.listeners))
+	}
+
+	return status
+}
+
+// condition returns the observed condition of the given type. The Programmed condition is derived from
+// the other conditions and the listeners.
+func (o *jwtProviderStatus) condition(conditionType v1alpha1.JWTProviderConditionType) (metav1.Condition, bool) {
+	if conditionType != v1alpha1.JWTProviderConditionProgrammed {
+		condition, ok := o.conditions[conditionType]
+		return condition, ok
+	}
+
+	for _, t := range []v1alpha1.JWTProviderConditionType{v1alpha1.JWTProviderConditionAccepted, v1alpha1.JWTProviderConditionResolvedJwks} {
+		if c, ok := o.conditions[t]; ok && c.Status != metav1.ConditionTrue {
+			return metav1.Condition{
+				Type:    string(conditionType),
+				Status:  metav1.ConditionFalse,
+				Reason:  string(v1alpha1.JWTProviderReasonInvalid),
+				Message: fmt.Sprintf("The %s condition is not true", t),
+			}, true
+		}
+	}
+
+	if len(o.listeners) == 0 {
+		return metav1.Condition{
+			Type:    string(conditionType),
+			Status:  metav1.ConditionFalse,
+			Reason:  string(v1alpha1.JWTProviderReasonNotAttached),
+			Message: "The JWTProvider is not configured on any listener",
+		}, true
+	}
+
+	return metav1.Condition{
+		Type:    string(conditionType),
+		Status:  metav1.ConditionTrue,
+		Reason:  string(v1alpha1.JWTProviderReasonProgrammed),
+		Message: fmt.Sprintf("The JWTProvider is configured on %d listeners", len(o.listeners)),
+	}, true
+}
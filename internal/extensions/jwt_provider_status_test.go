@@ -0,0 +1,167 @@
+package extensions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api"
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func testJWTProvider(name string, spec v1alpha1.JWTProviderSpec) *v1alpha1.JWTProvider {
+	return &v1alpha1.JWTProvider{
+		TypeMeta:   metav1.TypeMeta{Kind: api.JWTProviderKind, APIVersion: api.JWTProviderV1Alpha1},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: name, Generation: 2},
+		Spec:       spec,
+	}
+}
+
+func TestGatewayExtension_JWTProviderStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	inlineJWKS := &v1alpha1.LocalJWKS{Inline: ptr.To(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`)}
+
+	providers := []*v1alpha1.JWTProvider{
+		testJWTProvider("programmed", v1alpha1.JWTProviderSpec{
+			Name: "Programmed", Issuer: "https://example.com", LocalJwks: inlineJWKS,
+		}),
+		testJWTProvider("remote", v1alpha1.JWTProviderSpec{
+			Name: "Remote", Issuer: "https://example.com", RemoteJwks: &v1alpha1.RemoteJWKS{URI: "https://idp.example.com/jwks"},
+		}),
+		testJWTProvider("invalid", v1alpha1.JWTProviderSpec{
+			Name: "Invalid", Issuer: "https://example.com", LocalJwks: inlineJWKS,
+			MaxLifetime: &metav1.Duration{Duration: time.Hour},
+		}),
+		testJWTProvider("unresolved", v1alpha1.JWTProviderSpec{
+			Name: "Unresolved", Issuer: "https://example.com",
+			LocalJwks: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{Kind: "ConfigMap", Name: "missing"}},
+		}),
+		testJWTProvider("conflicted", v1alpha1.JWTProviderSpec{
+			Name: "Conflicted", Issuer: "https://example.com", LocalJwks: inlineJWKS,
+			PayloadInMetadata: "Programmed",
+		}),
+		testJWTProvider("detached", v1alpha1.JWTProviderSpec{
+			Name: "Detached", Issuer: "https://example.com", LocalJwks: inlineJWKS,
+			TargetRefs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("other", nil)},
+		}),
+	}
+
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&v1alpha1.JWTProvider{})
+	resources := make([]*extension.ExtensionResource, 0, len(providers))
+
+	for _, jwtp := range providers {
+		builder = builder.WithObjects(jwtp.DeepCopy())
+		resources = append(resources, &extension.ExtensionResource{UnstructuredBytes: mustMarshalResource(jwtp)})
+	}
+
+	kubeClient := builder.Build()
+	s := NewGatewayExtension(&commoncfg.FeatureGates{}, WithKubernetesClient(kubeClient))
+
+	translate := func(t *testing.T) {
+		t.Helper()
+
+		for _, name := range []string{"kms/gateway/http", "kms/gateway/https"} {
+			_, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+				Listener:            testHTTPListener(name),
+				PostListenerContext: &extension.PostHTTPListenerExtensionContext{ExtensionResources: resources},
+			})
+			assert.NoError(t, err)
+		}
+
+		_, err := s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{})
+		assert.NoError(t, err)
+	}
+
+	translate(t)
+
+	type want struct {
+		accepted, resolved, programmed string
+		jwksURI                        string
+		listeners                      []string
+	}
+
+	tests := []struct {
+		name string
+		want want
+	}{
+		{
+			name: "programmed",
+			want: want{
+				accepted: "Accepted", resolved: "Resolved", programmed: "Programmed",
+				listeners: []string{"kms/gateway/http", "kms/gateway/https"},
+			},
+		},
+		{
+			name: "remote",
+			want: want{
+				accepted: "Accepted", resolved: "Resolved", programmed: "Programmed",
+				jwksURI:   "https://idp.example.com/jwks",
+				listeners: []string{"kms/gateway/http", "kms/gateway/https"},
+			},
+		},
+		{
+			name: "invalid",
+			want: want{accepted: "Invalid", programmed: "Invalid"},
+		},
+		{
+			name: "unresolved",
+			want: want{accepted: "Accepted", resolved: "Unresolved", programmed: "Invalid"},
+		},
+		{
+			name: "conflicted",
+			want: want{accepted: "Conflicted", resolved: "Resolved", programmed: "Invalid"},
+		},
+		{
+			name: "detached",
+			want: want{programmed: "NotAttached"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwtp := &v1alpha1.JWTProvider{}
+			assert.NoError(t, kubeClient.Get(t.Context(), types.NamespacedName{Namespace: "kms", Name: tt.name}, jwtp))
+
+			reason := func(conditionType v1alpha1.JWTProviderConditionType) string {
+				condition := meta.FindStatusCondition(jwtp.Status.Conditions, string(conditionType))
+				if condition == nil {
+					return ""
+				}
+
+				assert.Equal(t, int64(2), condition.ObservedGeneration)
+
+				return condition.Reason
+			}
+
+			assert.Equal(t, tt.want.accepted, reason(v1alpha1.JWTProviderConditionAccepted))
+			assert.Equal(t, tt.want.resolved, reason(v1alpha1.JWTProviderConditionResolvedJwks))
+			assert.Equal(t, tt.want.programmed, reason(v1alpha1.JWTProviderConditionProgrammed))
+			assert.Equal(t, tt.want.jwksURI, jwtp.Status.JwksURI)
+			assert.Equal(t, tt.want.listeners, jwtp.Status.Listeners)
+		})
+	}
+
+	// The unchanged statuses are not written again
+	before := &v1alpha1.JWTProvider{}
+	assert.NoError(t, kubeClient.Get(t.Context(), types.NamespacedName{Namespace: "kms", Name: "programmed"}, before))
+
+	translate(t)
+
+	after := &v1alpha1.JWTProvider{}
+	assert.NoError(t, kubeClient.Get(t.Context(), types.NamespacedName{Namespace: "kms", Name: "programmed"}, after))
+	assert.Equal(t, before.GetResourceVersion(), after.GetResourceVersion())
+}
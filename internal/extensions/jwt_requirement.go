@@ -0,0 +1,143 @@
+package extensions
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"k8s.io/utils/ptr"
+
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+var (
+	ErrInvalidJWTRequirement = errors.New("invalid JWT requirement")
+	ErrUnknownJWTProvider    = errors.New("unknown JWT provider")
+)
+
+// buildJWTRequirement translates a JWTRequirement rule into an Envoy JWT requirement. All the
+// referenced providers must be part of the given providers.
+func buildJWTRequirement(rule *v1alpha1.JWTRequirementRule, providers map[string]*jwtauth3.JwtProvider) (*jwtauth3.JwtRequirement, error) {
+	switch {
+	case rule.ProviderName != "":
+		return buildProviderNameRequirement(rule.ProviderName, providers)
+	case len(rule.RequiresAny) > 0:
+		reqs, err := buildJWTRequirementNodes(rule.RequiresAny, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		return &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_RequiresAny{
+				RequiresAny: &jwtauth3.JwtRequirementOrList{Requirements: reqs},
+			},
+		}, nil
+	case len(rule.RequiresAll) > 0:
+		reqs, err := buildJWTRequirementNodes(rule.RequiresAll, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		return &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_RequiresAll{
+				RequiresAll: &jwtauth3.JwtRequirementAndList{Requirements: reqs},
+			},
+		}, nil
+	default:
+		return buildAllowRequirement(rule.AllowMissing, rule.AllowMissingOrFailed)
+	}
+}
+
+func buildJWTRequirementNodes(nodes []v1alpha1.JWTRequirementNode, providers map[string]*jwtauth3.JwtProvider) ([]*jwtauth3.JwtRequirement, error) {
+	reqs := make([]*jwtauth3.JwtRequirement, 0, len(nodes))
+
+	for _, node := range nodes {
+		req, err := buildJWTRequirementNode(&node, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
+func buildJWTRequirementNode(node *v1alpha1.JWTRequirementNode, providers map[string]*jwtauth3.JwtProvider) (*jwtauth3.JwtRequirement, error) {
+	switch {
+	case node.ProviderName != "":
+		return buildProviderNameRequirement(node.ProviderName, providers)
+	case len(node.RequiresAny) > 0:
+		reqs, err := buildProviderNameRequirements(node.RequiresAny, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		return &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_RequiresAny{
+				RequiresAny: &jwtauth3.JwtRequirementOrList{Requirements: reqs},
+			},
+		}, nil
+	case len(node.RequiresAll) > 0:
+		reqs, err := buildProviderNameRequirements(node.RequiresAll, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		return &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_RequiresAll{
+				RequiresAll: &jwtauth3.JwtRequirementAndList{Requirements: reqs},
+			},
+		}, nil
+	default:
+		return buildAllowRequirement(node.AllowMissing, node.AllowMissingOrFailed)
+	}
+}
+
+func buildProviderNameRequirements(names []string, providers map[string]*jwtauth3.JwtProvider) ([]*jwtauth3.JwtRequirement, error) {
+	reqs := make([]*jwtauth3.JwtRequirement, 0, len(names))
+
+	for _, name := range names {
+		req, err := buildProviderNameRequirement(name, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
+func buildProviderNameRequirement(name string, providers map[string]*jwtauth3.JwtProvider) (*jwtauth3.JwtRequirement, error) {
+	if _, ok := providers[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJWTProvider, name)
+	}
+
+	return &jwtauth3.JwtRequirement{
+		RequiresType: &jwtauth3.JwtRequirement_ProviderName{
+			ProviderName: name,
+		},
+	}, nil
+}
+
+func buildAllowRequirement(allowMissing, allowMissingOrFailed *bool) (*jwtauth3.JwtRequirement, error) {
+	switch {
+	case ptr.Deref(allowMissingOrFailed, false):
+		return &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_AllowMissingOrFailed{
+				AllowMissingOrFailed: &emptypb.Empty{},
+			},
+		}, nil
+	case ptr.Deref(allowMissing, false):
+		return &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_AllowMissing{
+				AllowMissing: &emptypb.Empty{},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: no requirement specified", ErrInvalidJWTRequirement)
+	}
+}
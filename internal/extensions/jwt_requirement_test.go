@@ -0,0 +1,162 @@
+package extensions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"k8s.io/utils/ptr"
+
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func providerRequirement(name string) *jwtauth3.JwtRequirement {
+	return &jwtauth3.JwtRequirement{
+		RequiresType: &jwtauth3.JwtRequirement_ProviderName{ProviderName: name},
+	}
+}
+
+func TestBuildJWTRequirement(t *testing.T) {
+	providers := map[string]*jwtauth3.JwtProvider{
+		"user":     {},
+		"workload": {},
+		"partner":  {},
+	}
+
+	tests := []struct {
+		name    string
+		rule    *v1alpha1.JWTRequirementRule
+		want    *jwtauth3.JwtRequirement
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Provider name",
+			rule:    &v1alpha1.JWTRequirementRule{ProviderName: "user"},
+			want:    providerRequirement("user"),
+			wantErr: assert.NoError,
+		},
+		{
+			name: "User and workload tokens",
+			rule: &v1alpha1.JWTRequirementRule{
+				RequiresAll: []v1alpha1.JWTRequirementNode{
+					{ProviderName: "user"},
+					{ProviderName: "workload"},
+				},
+			},
+			want: &jwtauth3.JwtRequirement{
+				RequiresType: &jwtauth3.JwtRequirement_RequiresAll{
+					RequiresAll: &jwtauth3.JwtRequirementAndList{
+						Requirements: []*jwtauth3.JwtRequirement{
+							providerRequirement("user"),
+							providerRequirement("workload"),
+						},
+					},
+				},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Nested any in all",
+			rule: &v1alpha1.JWTRequirementRule{
+				RequiresAll: []v1alpha1.JWTRequirementNode{
+					{RequiresAny: []string{"user", "partner"}},
+					{ProviderName: "workload"},
+				},
+			},
+			want: &jwtauth3.JwtRequirement{
+				RequiresType: &jwtauth3.JwtRequirement_RequiresAll{
+					RequiresAll: &jwtauth3.JwtRequirementAndList{
+						Requirements: []*jwtauth3.JwtRequirement{
+							{
+								RequiresType: &jwtauth3.JwtRequirement_RequiresAny{
+									RequiresAny: &jwtauth3.JwtRequirementOrList{
+										Requirements: []*jwtauth3.JwtRequirement{
+											providerRequirement("user"),
+											providerRequirement("partner"),
+										},
+									},
+								},
+							},
+							providerRequirement("workload"),
+						},
+					},
+				},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Optional token",
+			rule: &v1alpha1.JWTRequirementRule{
+				RequiresAny: []v1alpha1.JWTRequirementNode{
+					{RequiresAll: []string{"user", "workload"}},
+					{AllowMissing: ptr.To(true)},
+				},
+			},
+			want: &jwtauth3.JwtRequirement{
+				RequiresType: &jwtauth3.JwtRequirement_RequiresAny{
+					RequiresAny: &jwtauth3.JwtRequirementOrList{
+						Requirements: []*jwtauth3.JwtRequirement{
+							{
+								RequiresType: &jwtauth3.JwtRequirement_RequiresAll{
+									RequiresAll: &jwtauth3.JwtRequirementAndList{
+										Requirements: []*jwtauth3.JwtRequirement{
+											providerRequirement("user"),
+											providerRequirement("workload"),
+										},
+									},
+								},
+							},
+							{
+								RequiresType: &jwtauth3.JwtRequirement_AllowMissing{AllowMissing: &emptypb.Empty{}},
+							},
+						},
+					},
+				},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Allow missing or failed",
+			rule: &v1alpha1.JWTRequirementRule{AllowMissingOrFailed: ptr.To(true)},
+			want: &jwtauth3.JwtRequirement{
+				RequiresType: &jwtauth3.JwtRequirement_AllowMissingOrFailed{AllowMissingOrFailed: &emptypb.Empty{}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Unknown provider",
+			rule: &v1alpha1.JWTRequirementRule{
+				RequiresAny: []v1alpha1.JWTRequirementNode{
+					{ProviderName: "user"},
+					{RequiresAll: []string{"workload", "unknown"}},
+				},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Empty rule",
+			rule:    &v1alpha1.JWTRequirementRule{AllowMissing: ptr.To(false)},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildJWTRequirement(tt.rule, providers)
+			if !tt.wantErr(t, err, fmt.Sprintf("buildJWTRequirement(%v)", tt.rule)) {
+				return
+			}
+
+			diff := cmp.Diff(tt.want, got, protocmp.Transform())
+			if diff != "" {
+				assert.Fail(t, fmt.Sprintf("Not equal: \n"+
+					"expected: %s\n"+
+					"actual  : %s%s", tt.want, got, diff), "buildJWTRequirement(%v)", tt.rule)
+			}
+		})
+	}
+}
@@ -2,15 +2,18 @@ package extensions
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"maps"
 	"net/url"
+	"slices"
 
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/ptr"
 
 	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
@@ -19,6 +22,7 @@ import (
 	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	slogctx "github.com/veqryn/slog-context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/openkcm/gateway-extension/api/v1alpha1"
 	"github.com/openkcm/gateway-extension/internal/flags"
@@ -28,25 +32,41 @@ const (
 	JwtAuthSecureMappingName = "jwt_auth_secure_openkcm"
 )
 
+var (
+	ErrInvalidJWTProvider  = errors.New("invalid JWT provider")
+	ErrMetadataKeyConflict = errors.New("metadata key written by several JWT providers")
+
+	// defaultSpaceDelimitedClaims are normalized to facilitate matching in Authorization.
+	defaultSpaceDelimitedClaims = []string{"scope"}
+)
+
 // ProcessJWTProviders is called after Envoy Gateway is done generating a
 // Listener xDS configuration and before that configuration is passed on to
-// Envoy Proxy.
-func (s *GatewayExtension) ProcessJWTProviders(ctx context.Context, listener *listenerv3.Listener, resources []any) error {
-	providers := make(map[string]*jwtauth3.JwtProvider)
-	reqMap := make(map[string]*jwtauth3.JwtRequirement)
+// Envoy Proxy. The JWTRequirements are registered next to the requirement
+// built from the JWT providers. The target references are evaluated per
+// filter chain, so the Gateway listeners sharing the xDS listener only
+// enforce the JWT providers targeting them.
+func (s *GatewayExtension) ProcessJWTProviders(ctx context.Context, listener *listenerv3.Listener, resources []any, requirements []any) error {
+	ctx, span := startSpan(ctx, "GatewayExtension.ProcessJWTProviders",
+		attribute.String("xds.listener", listener.GetName()), attribute.Int("jwt_providers", len(resources)))
+	defer span.End()
 
 	// Collect all jwt providers
 	slogctx.Info(ctx, "Processing JWTProviders", "number", len(resources))
 
-	reqs := []*jwtauth3.JwtRequirement{}
+	metadataKeys := make(map[string]string)
+	targets := listenerTargets(listener)
+
+	// listenerProviders target at least one filter chain, built holds them along with the route providers.
+	listenerProviders := []builtJWTProvider{}
+	built := make(map[types.NamespacedName]builtJWTProvider)
+
+	// The clusters of the other listeners are kept, they are all emitted by the translation.
+	clusters := make(map[string]*urlCluster)
 
 	s.jwtAuthClustersMu.Lock()
 	defer s.jwtAuthClustersMu.Unlock()
 
-	for k := range s.jwtAuthClusters {
-		delete(s.jwtAuthClusters, k)
-	}
-
 	for _, resource := range resources {
 		jwtp, ok := resource.(*v1alpha1.JWTProvider)
 		if !ok {
@@ -58,113 +78,221 @@ func (s *GatewayExtension) ProcessJWTProviders(ctx context.Context, listener *li
 			continue
 		}
 
-		slogctx.Info(ctx, "Processing JWTProvider", "name", jwtp.Name)
-		slogctx.Debug(ctx, "Details on hte JWTProvider", "resource", jwtp)
+		s.observeJWTProvider(jwtp)
+
+		if !matchesTargetRefs(jwtp.GetNamespace(), jwtp.Spec.TargetRefs, targets) {
+			slogctx.Info(ctx, "Skipping JWTProvider as is not targeting the listener",
+				"name", jwtp.GetName(), "listener", listener.GetName())
+
+			continue
+		}
+
+		jwt, urlCLuster, err := s.buildJWTProvider(ctx, jwtp)
+		if err != nil {
+			return err
+		}
+
+		if jwt == nil {
+			continue
+		}
+
+		err = reserveMetadataKeys(metadataKeys, jwtp.Spec.Name, jwt)
+		if err != nil {
+			slogctx.Error(ctx, "Skipping JWTProvider with conflicting metadata keys", "name", jwtp.GetName(), "error", err)
+			s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionAccepted, metav1.ConditionFalse, v1alpha1.JWTProviderReasonConflicted, err.Error())
+
+			continue
+		}
+
+		provider := builtJWTProvider{resource: jwtp, provider: jwt}
+		listenerProviders = append(listenerProviders, provider)
+		built[jwtProviderKey(jwtp)] = provider
+
+		if urlCLuster != nil {
+			clusters[urlCLuster.name] = urlCLuster
+		}
+
+		s.attachJWTProvider(jwtp, listener.GetName())
 
-		jwksTimeoutSec := int64(2)             // 2 seconds
-		jwksCacheDurationSec := int64(10 * 60) // 600 seconds
-		jwksFailedRefetchSec := int64(5)       // 5 seconds
+		slogctx.Info(ctx, "Processed JWTProvider resource", "name", jwtp.Name)
+	}
 
-		var jwksUri string
-		if jwtp.Spec.RemoteJwks != nil {
-			jwksUri = jwtp.Spec.RemoteJwks.URI
+	// Register the providers referenced by the routes of the Gateways of the listener, they are not part of the
+	// listener requirement. They are processed by requirement name, so the same provider wins the metadata key
+	// conflicts on every call.
+	routeRequirements := s.gatewayRouteJWTRequirementsOf(targets)
 
-			if jwtp.Spec.RemoteJwks.TimeoutSec > 0 {
-				jwksTimeoutSec = jwtp.Spec.RemoteJwks.TimeoutSec
+	for _, requirementName := range slices.Sorted(maps.Keys(routeRequirements)) {
+		for _, jwtp := range routeRequirements[requirementName] {
+			if _, ok := built[jwtProviderKey(jwtp)]; ok {
+				continue
 			}
 
-			if jwtp.Spec.RemoteJwks.CacheDuration > 0 {
-				jwksCacheDurationSec = jwtp.Spec.RemoteJwks.CacheDuration
+			if !matchesTargetRefs(jwtp.GetNamespace(), jwtp.Spec.TargetRefs, targets) {
+				slogctx.Info(ctx, "Skipping route JWTProvider as is not targeting the listener",
+					"name", jwtp.GetName(), "listener", listener.GetName())
+
+				continue
 			}
-		} else {
-			uri, err := extractJWKSFromWellKnownOpenIDConfiguration(ctx, jwtp.Spec.Issuer)
+
+			jwt, urlCLuster, err := s.buildJWTProvider(ctx, jwtp)
 			if err != nil {
 				return err
 			}
 
-			jwksUri = uri
-		}
+			if jwt == nil {
+				continue
+			}
 
-		_, err := url.Parse(jwksUri)
-		if err != nil {
-			slogctx.Error(ctx, "Failed to parse the remote Jwks uri", "error", err)
-			continue
+			err = reserveMetadataKeys(metadataKeys, jwtp.Spec.Name, jwt)
+			if err != nil {
+				slogctx.Error(ctx, "Skipping route JWTProvider with conflicting metadata keys", "name", jwtp.GetName(), "error", err)
+				s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionAccepted, metav1.ConditionFalse, v1alpha1.JWTProviderReasonConflicted, err.Error())
+
+				continue
+			}
+
+			built[jwtProviderKey(jwtp)] = builtJWTProvider{resource: jwtp, provider: jwt}
+
+			if urlCLuster != nil {
+				clusters[urlCLuster.name] = urlCLuster
+			}
+
+			s.attachJWTProvider(jwtp, listener.GetName())
+
+			slogctx.Info(ctx, "Processed route JWTProvider resource", "name", jwtp.Name)
 		}
+	}
 
-		urlCLuster, err := url2Cluster(jwksUri)
-		if err != nil {
-			slogctx.Error(ctx, "Failed to translate url to cluster", "error", err)
-			continue
+	s.setListenerJWTAuthClusters(listener.GetName(), clusters)
+
+	// Go over all the chains, and add the jwt authentication http filter
+	for _, currChain := range listenerFilterChains(listener) {
+		chainTargets := filterChainTargets(listener, currChain)
+
+		// The providers of the chain, by provider name
+		chainProviders := make(map[string]builtJWTProvider)
+		reqs := []*jwtauth3.JwtRequirement{}
+		claimHeaders := []claimHeader{}
+		errorResponses := make(map[int32]errorResponse)
+
+		addProvider := func(p builtJWTProvider) {
+			if _, ok := chainProviders[p.resource.Spec.Name]; ok {
+				return
+			}
+
+			chainProviders[p.resource.Spec.Name] = p
+			claimHeaders = append(claimHeaders, buildClaimHeaders(p.provider.GetPayloadInMetadata(), p.resource.Spec.ClaimToHeaders)...)
+			collectErrorResponses(errorResponses, p.resource.Spec.Name, p.resource.Spec.ErrorResponses)
 		}
 
-		remoteJwks := &jwtauth3.RemoteJwks{
-			HttpUri: &corev3.HttpUri{
-				Uri: jwksUri,
-				HttpUpstreamType: &corev3.HttpUri_Cluster{
-					Cluster: urlCLuster.CustomName(),
+		for _, p := range listenerProviders {
+			if !matchesTargetRefs(p.resource.GetNamespace(), p.resource.Spec.TargetRefs, chainTargets) {
+				continue
+			}
+
+			addProvider(p)
+			reqs = append(reqs, &jwtauth3.JwtRequirement{
+				RequiresType: &jwtauth3.JwtRequirement_ProviderName{
+					ProviderName: p.resource.Spec.Name,
 				},
-				Timeout: &durationpb.Duration{Seconds: jwksTimeoutSec},
-			},
-			CacheDuration: &durationpb.Duration{Seconds: jwksCacheDurationSec},
-			AsyncFetch: &jwtauth3.JwksAsyncFetch{
-				FastListener:          true,
-				FailedRefetchDuration: &durationpb.Duration{Seconds: jwksFailedRefetchSec},
-			},
+			})
 		}
-		// Set the retry policy if it exists.
-		if jwtp.Spec.RemoteJwks != nil && jwtp.Spec.RemoteJwks.Retry != nil {
-			rp, err := buildNonRouteRetryPolicy(jwtp.Spec.RemoteJwks.Retry)
+
+		// The requirements selected by the routes of the Gateway of the chain are registered along with their providers.
+		routeReqs := make(map[string][]string)
+
+		chainRouteRequirements := s.gatewayRouteJWTRequirementsOf(chainTargets)
+		for _, requirementName := range slices.Sorted(maps.Keys(chainRouteRequirements)) {
+			providers, err := chainRouteProviders(chainProviders, built, chainTargets, chainRouteRequirements[requirementName])
 			if err != nil {
-				return err
+				slogctx.Error(ctx, "Skipping route JWT requirement", "name", requirementName,
+					"filter-chain", currChain.GetName(), "error", err)
+
+				continue
+			}
+
+			names := make([]string, 0, len(providers))
+			for _, p := range providers {
+				addProvider(p)
+				names = append(names, p.resource.Spec.Name)
 			}
 
-			remoteJwks.RetryPolicy = rp
+			routeReqs[requirementName] = names
 		}
 
-		jwt := &jwtauth3.JwtProvider{
-			Issuer:            jwtp.Spec.Issuer,
-			Audiences:         jwtp.Spec.Audiences,
-			RequireExpiration: jwtp.Spec.RequireExpiration,
-			JwksSourceSpecifier: &jwtauth3.JwtProvider_RemoteJwks{
-				RemoteJwks: remoteJwks,
-			},
-			PayloadInMetadata: jwtp.Spec.Name,
-			Forward:           true,
-			NormalizePayloadInMetadata: &jwtauth3.JwtProvider_NormalizePayload{
-				// Normalize the scopes to facilitate matching in Authorization.
-				SpaceDelimitedClaims: []string{"scope"},
-			},
+		providers := make(map[string]*jwtauth3.JwtProvider, len(chainProviders))
+		for name, p := range chainProviders {
+			providers[name] = p.provider
 		}
 
-		if jwtp.Spec.RecomputeRoute != nil {
-			jwt.ClearRouteCache = *jwtp.Spec.RecomputeRoute
+		reqMap := s.buildJWTRequirementMap(ctx, currChain, chainTargets, providers, reqs, routeReqs, requirements)
+
+		err := setJWTAuthentication(ctx, currChain, providers, reqMap, claimHeaders, errorResponses)
+		if err != nil {
+			return err
 		}
+	}
 
-		if len(jwtp.Spec.FromHeaders) > 0 {
-			jwt.FromHeaders = buildJwtFromHeaders(jwtp.Spec.FromHeaders)
+	return nil
+}
+
+// chainRouteProviders returns the built providers of a route requirement targeting the filter chain. An
+// error is returned if none of them targets the chain, if one of them was not built, or if another provider
+// with the same name is already registered on the filter chain.
+func chainRouteProviders(
+	chainProviders map[string]builtJWTProvider,
+	built map[types.NamespacedName]builtJWTProvider,
+	targets []listenerTarget,
+	requirement []*v1alpha1.JWTProvider,
+) ([]builtJWTProvider, error) {
+	providers := make([]builtJWTProvider, 0, len(requirement))
+
+	for _, jwtp := range requirement {
+		if !matchesTargetRefs(jwtp.GetNamespace(), jwtp.Spec.TargetRefs, targets) {
+			continue
 		}
 
-		if len(jwtp.Spec.ClaimToHeaders) > 0 {
-			jwt.ClaimToHeaders = buildJwtClaimToHeader(jwtp.Spec.ClaimToHeaders)
+		p, ok := built[jwtProviderKey(jwtp)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not configured", ErrInvalidJWTProvider, jwtProviderKey(jwtp))
 		}
 
-		if jwtp.Spec.ExtractFrom != nil {
-			jwt.FromHeaders = buildJwtFromHeaders(jwtp.Spec.ExtractFrom.Headers)
-			jwt.FromCookies = jwtp.Spec.ExtractFrom.Cookies
-			jwt.FromParams = jwtp.Spec.ExtractFrom.Params
+		other, ok := chainProviders[jwtp.Spec.Name]
+		if ok && jwtProviderKey(other.resource) != jwtProviderKey(jwtp) {
+			return nil, fmt.Errorf("%w: %s and %s are both named %q",
+				ErrInvalidJWTProvider, jwtProviderKey(other.resource), jwtProviderKey(jwtp), jwtp.Spec.Name)
 		}
 
-		providers[jwtp.Spec.Name] = jwt
-		reqs = append(reqs, &jwtauth3.JwtRequirement{
-			RequiresType: &jwtauth3.JwtRequirement_ProviderName{
-				ProviderName: jwtp.Spec.Name,
-			},
-		})
-		s.jwtAuthClusters[urlCLuster.name] = urlCLuster
+		providers = append(providers, p)
+	}
 
-		slogctx.Info(ctx, "Processed JWTProvider resource", "name", jwtp.Name)
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("%w: none of the providers targets the filter chain", ErrInvalidJWTProvider)
 	}
 
+	return providers, nil
+}
+
+// builtJWTProvider is an Envoy JWT provider along with the JWTProvider resource it was built from.
+type builtJWTProvider struct {
+	resource *v1alpha1.JWTProvider
+	provider *jwtauth3.JwtProvider
+}
+
+// buildJWTRequirementMap returns the requirements of a filter chain; the requirement satisfied by any of
+// the JWT providers targeting it, the requirements of the routes and the JWTRequirements targeting it.
+func (s *GatewayExtension) buildJWTRequirementMap(
+	ctx context.Context,
+	filterChain *listenerv3.FilterChain,
+	targets []listenerTarget,
+	providers map[string]*jwtauth3.JwtProvider,
+	reqs []*jwtauth3.JwtRequirement,
+	routeReqs map[string][]string,
+	requirements []any,
+) map[string]*jwtauth3.JwtRequirement {
+	reqMap := make(map[string]*jwtauth3.JwtRequirement)
+
 	var jwtRequirement *jwtauth3.JwtRequirement
 
 	switch len(reqs) {
@@ -192,86 +320,458 @@ func (s *GatewayExtension) ProcessJWTProviders(ctx context.Context, listener *li
 		reqMap[JwtAuthSecureMappingName] = jwtRequirement
 	}
 
-	// First, get the filter chains from the listener
-	filterChains := listener.GetFilterChains()
-
-	defaultFC := listener.GetDefaultFilterChain()
-	if defaultFC != nil {
-		filterChains = append(filterChains, defaultFC)
+	for name, providerNames := range routeReqs {
+		reqMap[name] = buildAnyProviderRequirement(providerNames)
 	}
-	// Go over all the chains, and add the basic authentication http filter
-	for _, currChain := range filterChains {
-		httpConManager, hcmIndex, err := findHCM(currChain)
+
+	for _, resource := range requirements {
+		jwtr, ok := resource.(*v1alpha1.JWTRequirement)
+		if !ok {
+			continue
+		}
+
+		if s.features.IsFeatureEnabled(flags.DisableJWTProviderComputation) {
+			slogctx.Warn(ctx, "Skipping JWTRequirement as is disabled through flags", "name", jwtr.GetName())
+			continue
+		}
+
+		if !matchesTargetRefs(jwtr.GetNamespace(), jwtr.Spec.TargetRefs, targets) {
+			slogctx.Info(ctx, "Skipping JWTRequirement as is not targeting the filter chain",
+				"name", jwtr.GetName(), "filter-chain", filterChain.GetName())
+
+			continue
+		}
+
+		requirement, err := buildJWTRequirement(&jwtr.Spec.JWTRequirementRule, providers)
 		if err != nil {
-			slogctx.Warn(ctx, "Failed to find an HCM in the current chain", "filter-chain", currChain.GetName())
+			slogctx.Error(ctx, "Failed to build the JWTRequirement", "name", jwtr.GetName(), "error", err)
 			continue
 		}
 
-		slogctx.Info(ctx, "Processing HTTPConnectionManager", "index", hcmIndex)
-		// If a jwt authentication filter already exists, update it. Otherwise, create it.
-		jwtAuthFilter, baIndex, err := findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+		reqMap[jwtr.Spec.Name] = requirement
+
+		slogctx.Info(ctx, "Processed JWTRequirement resource", "name", jwtr.GetName(), "requirement", jwtr.Spec.Name)
+	}
+
+	// The routes without requirement of their own select the secure requirement. Once the filter is
+	// configured for the other requirements, it must be registered as well, without enforcing any JWT
+	// as no JWT provider targets the filter chain.
+	if _, ok := reqMap[JwtAuthSecureMappingName]; !ok && len(reqMap) > 0 {
+		reqMap[JwtAuthSecureMappingName] = &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_AllowMissingOrFailed{
+				AllowMissingOrFailed: &emptypb.Empty{},
+			},
+		}
+	}
+
+	return reqMap
+}
+
+// setJWTAuthentication adds, updates or removes the JWT authentication filter of the filter chain, along
+// with the claim to headers filter and the local replies of the JWT providers.
+func setJWTAuthentication(
+	ctx context.Context,
+	filterChain *listenerv3.FilterChain,
+	providers map[string]*jwtauth3.JwtProvider,
+	reqMap map[string]*jwtauth3.JwtRequirement,
+	claimHeaders []claimHeader,
+	errorResponses map[int32]errorResponse,
+) error {
+	httpConManager, hcmIndex, err := findHCM(filterChain)
+	if err != nil {
+		slogctx.Warn(ctx, "Failed to find an HCM in the current chain", "filter-chain", filterChain.GetName())
+		return nil
+	}
+
+	slogctx.Info(ctx, "Processing HTTPConnectionManager", "index", hcmIndex)
+	// If a jwt authentication filter already exists, update it. Otherwise, create it.
+	jwtAuthFilter, baIndex, err := findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+	if err != nil {
+		slogctx.Warn(ctx, "Failed to unmarshal the existing jwtAuthFilter filter; Continue.",
+			"name", filterChain.GetName(), "error", err)
+
+		return nil
+	}
+
+	// The JWT providers report their failures for the error responses to only apply to them
+	if len(errorResponses) > 0 {
+		providers = withFailedStatusInMetadata(providers)
+	}
+
+	if baIndex == -1 {
+		// Create a new jwt auth filter
+		jwtAuthFilter = &jwtauth3.JwtAuthentication{
+			Providers:      providers,
+			RequirementMap: reqMap,
+		}
+	} else {
+		// Update the jwt auth filter
+		jwtAuthFilter.Providers = providers
+		jwtAuthFilter.RequirementMap = reqMap
+	}
+
+	var anyFilterConfig *anypb.Any
+	if len(reqMap) > 0 {
+		anyFilterConfig, err = newAny(jwtAuthFilter)
+		if err != nil {
+			slogctx.Error(ctx, "Failed to unmarshal the existing jwtAuthFilter filter.", "error", err)
+			return err
+		}
+	}
+
+	// Add or update the Jwt Authentication filter in the HCM
+	if baIndex > -1 {
+		if anyFilterConfig == nil {
+			httpConManager.HttpFilters[baIndex] = nil
+		} else {
+			httpConManager.HttpFilters[baIndex].ConfigType = &hcm.HttpFilter_TypedConfig{
+				TypedConfig: anyFilterConfig,
+			}
+		}
+	} else {
+		filters := make([]*hcm.HttpFilter, 0)
+		if anyFilterConfig != nil {
+			filters = append(filters, &hcm.HttpFilter{
+				Name: egv1a1.EnvoyFilterJWTAuthn.String(),
+				ConfigType: &hcm.HttpFilter_TypedConfig{
+					TypedConfig: anyFilterConfig,
+				},
+			})
+		}
+
+		filters = append(filters, httpConManager.GetHttpFilters()...)
+		httpConManager.HttpFilters = filters
+	}
+
+	// The claims not supported by the JWT authentication filter are copied by a Lua filter right after it
+	claimFilters, err := buildClaimToHeadersFilter(claimHeaders)
+	if err != nil {
+		return err
+	}
+
+	jwtIndex := slices.IndexFunc(httpConManager.GetHttpFilters(), func(filter *hcm.HttpFilter) bool {
+		return filter.GetName() == egv1a1.EnvoyFilterJWTAuthn.String()
+	})
+	if jwtIndex == -1 {
+		claimFilters = nil
+	}
+
+	httpConManager.HttpFilters = replaceFiltersAfter(httpConManager.GetHttpFilters(), jwtIndex, ClaimToHeadersFilterName, claimFilters)
+
+	// The error responses of the JWT providers are applied to the local replies of the HCM
+	setLocalReplyMappers(httpConManager, buildLocalReplyMappers(errorResponses))
+
+	// Write the updated HCM back to the filter chain
+	anyConnectionMgr, _ := newAny(httpConManager)
+	filterChain.Filters[hcmIndex].ConfigType = &listenerv3.Filter_TypedConfig{
+		TypedConfig: anyConnectionMgr,
+	}
+
+	slogctx.Info(ctx, "Processed HTTPConnectionManager", "index", hcmIndex, "name", filterChain.GetName())
+
+	return nil
+}
+
+// buildJWTProvider translates a JWTProvider resource into an Envoy JWT provider and the cluster
+// serving its JWKS. A nil provider is returned if the resource has to be skipped.
+func (s *GatewayExtension) buildJWTProvider(ctx context.Context, jwtp *v1alpha1.JWTProvider) (*jwtauth3.JwtProvider, *urlCluster, error) {
+	ctx, span := startSpan(ctx, "GatewayExtension.buildJWTProvider",
+		attribute.String("jwt_provider.name", jwtp.GetName()), attribute.String("jwt_provider.namespace", jwtp.GetNamespace()))
+	defer span.End()
+
+	slogctx.Info(ctx, "Processing JWTProvider", "name", jwtp.Name)
+	slogctx.Debug(ctx, "Details on hte JWTProvider", "resource", jwtp)
+
+	err := validateJWTProvider(jwtp)
+	if err != nil {
+		slogctx.Error(ctx, "Skipping invalid JWTProvider", "name", jwtp.GetName(), "error", err)
+		s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionAccepted, metav1.ConditionFalse, v1alpha1.JWTProviderReasonInvalid, err.Error())
+
+		return nil, nil, nil
+	}
+
+	s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionAccepted, metav1.ConditionTrue, v1alpha1.JWTProviderReasonAccepted, "The JWTProvider is valid")
+
+	jwt := &jwtauth3.JwtProvider{
+		Issuer:            jwtp.Spec.Issuer,
+		Audiences:         jwtp.Spec.Audiences,
+		RequireExpiration: jwtp.Spec.RequireExpiration,
+		PayloadInMetadata: payloadInMetadata(jwtp),
+		HeaderInMetadata:  jwtp.Spec.HeaderInMetadata,
+		Forward:           ptr.Deref(jwtp.Spec.Forward, true),
+		NormalizePayloadInMetadata: &jwtauth3.JwtProvider_NormalizePayload{
+			SpaceDelimitedClaims: defaultSpaceDelimitedClaims,
+		},
+	}
+
+	if len(jwtp.Spec.SpaceDelimitedClaims) > 0 {
+		jwt.NormalizePayloadInMetadata.SpaceDelimitedClaims = jwtp.Spec.SpaceDelimitedClaims
+	}
+
+	var urlCLuster *urlCluster
+
+	if jwtp.Spec.LocalJwks != nil {
+		jwks, err := s.resolveLocalJWKS(ctx, jwtp.GetNamespace(), jwtp.Spec.LocalJwks)
+		if err != nil {
+			slogctx.Error(ctx, "Failed to resolve the local Jwks", "name", jwtp.GetName(), "error", err)
+			s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+			return nil, nil, nil
+		}
+
+		s.setJWTProviderJWKSURI(jwtp, "")
+		s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionResolvedJwks, metav1.ConditionTrue, v1alpha1.JWTProviderReasonResolved, "The local JWKS is resolved")
+
+		jwt.JwksSourceSpecifier = &jwtauth3.JwtProvider_LocalJwks{
+			LocalJwks: &corev3.DataSource{
+				Specifier: &corev3.DataSource_InlineString{InlineString: jwks},
+			},
+		}
+	} else {
+		remoteJwks, cluster, err := s.buildRemoteJwks(ctx, jwtp)
 		if err != nil {
-			slogctx.Warn(ctx, "Failed to unmarshal the existing jwtAuthFilter filter; Continue.",
-				"name", currChain.GetName(), "error", err)
+			return nil, nil, err
+		}
+
+		if remoteJwks == nil {
+			return nil, nil, nil
+		}
+
+		jwt.JwksSourceSpecifier = &jwtauth3.JwtProvider_RemoteJwks{
+			RemoteJwks: remoteJwks,
+		}
+		urlCLuster = cluster
+	}
+
+	jwt.JwtCacheConfig = s.buildJwtCacheConfig(jwtp.Spec.Cache)
+
+	if jwtp.Spec.ForwardPayloadHeader != "" {
+		jwt.ForwardPayloadHeader = jwtp.Spec.ForwardPayloadHeader
+		jwt.PadForwardPayloadHeader = jwtp.Spec.PadForwardPayloadHeader
+	}
+
+	if jwtp.Spec.MaxLifetime != nil {
+		jwt.MaxLifetime = durationpb.New(jwtp.Spec.MaxLifetime.Duration)
+	}
+
+	if jwtp.Spec.ClockSkewSeconds != nil {
+		jwt.ClockSkewSeconds = *jwtp.Spec.ClockSkewSeconds
+	}
+
+	if jwtp.Spec.RecomputeRoute != nil {
+		jwt.ClearRouteCache = *jwtp.Spec.RecomputeRoute
+	}
+
+	if len(jwtp.Spec.FromHeaders) > 0 {
+		jwt.FromHeaders = buildJwtFromHeaders(jwtp.Spec.FromHeaders)
+	}
+
+	if len(jwtp.Spec.ClaimToHeaders) > 0 {
+		jwt.ClaimToHeaders = buildJwtClaimToHeader(jwtp.Spec.ClaimToHeaders)
+	}
+
+	if jwtp.Spec.ExtractFrom != nil {
+		jwt.FromHeaders = buildJwtFromHeaders(jwtp.Spec.ExtractFrom.Headers)
+		jwt.FromCookies = jwtp.Spec.ExtractFrom.Cookies
+		jwt.FromParams = jwtp.Spec.ExtractFrom.Params
+	}
+
+	return jwt, urlCLuster, nil
+}
+
+// validateJWTProvider checks the constraints of a JWTProvider that are not enforced by Envoy.
+func validateJWTProvider(jwtp *v1alpha1.JWTProvider) error {
+	if jwtp.Spec.MaxLifetime != nil {
+		if jwtp.Spec.MaxLifetime.Duration <= 0 {
+			return fmt.Errorf("%w: maxLifetime must be positive", ErrInvalidJWTProvider)
+		}
+
+		if !jwtp.Spec.RequireExpiration {
+			return fmt.Errorf("%w: maxLifetime requires requireExpiration", ErrInvalidJWTProvider)
+		}
+	}
+
+	if jwtp.Spec.HeaderInMetadata != "" && jwtp.Spec.HeaderInMetadata == payloadInMetadata(jwtp) {
+		return fmt.Errorf("%w: headerInMetadata must differ from payloadInMetadata", ErrInvalidJWTProvider)
+	}
+
+	return nil
+}
+
+// payloadInMetadata returns the metadata key of the verified payload of the JWT provider, its name by default.
+func payloadInMetadata(jwtp *v1alpha1.JWTProvider) string {
+	if jwtp.Spec.PayloadInMetadata != "" {
+		return jwtp.Spec.PayloadInMetadata
+	}
+
+	return jwtp.Spec.Name
+}
+
+// reserveMetadataKeys records the metadata keys written by the JWT provider, keyed to its name. An error is
+// returned, and nothing recorded, if another JWT provider already writes one of them.
+func reserveMetadataKeys(keys map[string]string, name string, jwt *jwtauth3.JwtProvider) error {
+	written := make([]string, 0, 2)
 
+	for _, key := range []string{jwt.GetPayloadInMetadata(), jwt.GetHeaderInMetadata()} {
+		if key == "" {
 			continue
 		}
 
-		if baIndex == -1 {
-			// Create a new jwt auth filter
-			jwtAuthFilter = &jwtauth3.JwtAuthentication{
-				Providers:      providers,
-				RequirementMap: reqMap,
-			}
-		} else {
-			// Update the jwt auth filter
-			jwtAuthFilter.Providers = providers
-			jwtAuthFilter.RequirementMap = reqMap
+		if other, ok := keys[key]; ok && other != name {
+			return fmt.Errorf("%w: %q is also written by JWT provider %s", ErrMetadataKeyConflict, key, other)
 		}
 
-		var anyFilterConfig *anypb.Any
-		if len(reqMap) > 0 {
-			anyFilterConfig, err = anypb.New(jwtAuthFilter)
-			if err != nil {
-				slogctx.Error(ctx, "Failed to unmarshal the existing jwtAuthFilter filter.", "error", err)
-				return err
-			}
+		written = append(written, key)
+	}
+
+	for _, key := range written {
+		keys[key] = name
+	}
+
+	return nil
+}
+
+// buildRemoteJwks returns the remote JWKS source of a JWTProvider and the cluster serving it. The JWKS URI is
+// discovered from the issuer if not explicitly provided. A nil source is returned if the resource has to be skipped.
+func (s *GatewayExtension) buildRemoteJwks(ctx context.Context, jwtp *v1alpha1.JWTProvider) (*jwtauth3.RemoteJwks, *urlCluster, error) {
+	jwksTimeoutSec := int64(2)             // 2 seconds
+	jwksCacheDurationSec := int64(10 * 60) // 600 seconds
+	jwksFailedRefetchSec := int64(5)       // 5 seconds
+
+	var jwksUri string
+	if jwtp.Spec.RemoteJwks != nil {
+		jwksUri = jwtp.Spec.RemoteJwks.URI
+
+		if jwtp.Spec.RemoteJwks.TimeoutSec > 0 {
+			jwksTimeoutSec = jwtp.Spec.RemoteJwks.TimeoutSec
 		}
 
-		// Add or update the Jwt Authentication filter in the HCM
-		if baIndex > -1 {
-			if anyFilterConfig == nil {
-				httpConManager.HttpFilters[baIndex] = nil
-			} else {
-				httpConManager.HttpFilters[baIndex].ConfigType = &hcm.HttpFilter_TypedConfig{
-					TypedConfig: anyFilterConfig,
-				}
+		if jwtp.Spec.RemoteJwks.CacheDuration > 0 {
+			jwksCacheDurationSec = jwtp.Spec.RemoteJwks.CacheDuration
+		}
+	} else {
+		uri, err := s.discovery.JWKSURI(ctx, jwtp.Spec.Issuer)
+		if err != nil {
+			reason := DiscoveryRequestFailed
+
+			discoveryErr := &DiscoveryError{}
+			if errors.As(err, &discoveryErr) {
+				reason = discoveryErr.Reason
 			}
-		} else {
-			filters := make([]*hcm.HttpFilter, 0)
-			if anyFilterConfig != nil {
-				filters = append(filters, &hcm.HttpFilter{
-					Name: egv1a1.EnvoyFilterJWTAuthn.String(),
-					ConfigType: &hcm.HttpFilter_TypedConfig{
-						TypedConfig: anyFilterConfig,
-					},
-				})
+
+			// Only the affected provider is skipped, the others are still processed.
+			slogctx.Error(ctx, "Skipping JWTProvider as its JWKS URI cannot be discovered",
+				"name", jwtp.GetName(), "namespace", jwtp.GetNamespace(), "issuer", jwtp.Spec.Issuer,
+				"reason", reason, "error", err)
+			s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonDiscoveryFailed, err)
+			s.metrics.recordError(ctx, string(reason))
+
+			return nil, nil, nil
+		}
+
+		jwksUri = uri
+	}
+
+	_, err := url.Parse(jwksUri)
+	if err != nil {
+		slogctx.Error(ctx, "Failed to parse the remote Jwks uri", "error", err)
+		s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+		return nil, nil, nil
+	}
+
+	urlCLuster, err := url2Cluster(jwksUri)
+	if err != nil {
+		slogctx.Error(ctx, "Failed to translate url to cluster", "error", err)
+		s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+		return nil, nil, nil
+	}
+
+	if jwtp.Spec.RemoteJwks != nil {
+		validation, err := s.resolveUpstreamValidation(ctx, jwtp.GetNamespace(), jwtp.Spec.RemoteJwks.TLS)
+		if err != nil {
+			slogctx.Error(ctx, "Skipping JWTProvider as the validation of its JWKS server certificate cannot be resolved",
+				"name", jwtp.GetName(), "namespace", jwtp.GetNamespace(), "error", err)
+			s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+			return nil, nil, nil
+		}
+
+		urlCLuster.setValidation(validation)
+
+		cert, err := s.resolveClientCertificate(ctx, jwtp.GetNamespace(), jwtp.Spec.RemoteJwks.TLS)
+		if err != nil {
+			slogctx.Error(ctx, "Skipping JWTProvider as the client certificate for its JWKS server cannot be resolved",
+				"name", jwtp.GetName(), "namespace", jwtp.GetNamespace(), "error", err)
+			s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+			return nil, nil, nil
+		}
+
+		if cert != nil && !urlCLuster.tls {
+			slogctx.Error(ctx, "Skipping JWTProvider with a client certificate for a plain HTTP JWKS server",
+				"name", jwtp.GetName(), "namespace", jwtp.GetNamespace(), "uri", jwksUri)
+			s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, ErrPlainHTTPClientCertificate)
+
+			return nil, nil, nil
+		}
+
+		urlCLuster.setClientCertificate(cert)
+
+		if jwtp.Spec.RemoteJwks.DNSLookupFamily != nil {
+			family, err := ParseDNSLookupFamily(string(*jwtp.Spec.RemoteJwks.DNSLookupFamily))
+			if err != nil {
+				slogctx.Error(ctx, "Skipping JWTProvider with invalid DNS lookup family",
+					"name", jwtp.GetName(), "namespace", jwtp.GetNamespace(), "error", err)
+				s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+				return nil, nil, nil
 			}
 
-			filters = append(filters, httpConManager.GetHttpFilters()...)
-			httpConManager.HttpFilters = filters
+			urlCLuster.setDNSLookupFamily(&family)
 		}
+	}
+
+	proxy, err := s.jwksProxyCluster(jwtp)
+	if err != nil {
+		slogctx.Error(ctx, "Skipping JWTProvider with invalid JWKS proxy",
+			"name", jwtp.GetName(), "namespace", jwtp.GetNamespace(), "error", err)
+		s.rejectJWTProviderJWKS(jwtp, v1alpha1.JWTProviderReasonUnresolved, err)
+
+		return nil, nil, nil
+	}
+
+	urlCLuster.setProxy(proxy)
 
-		// Write the updated HCM back to the filter chain
-		anyConnectionMgr, _ := anypb.New(httpConManager)
-		currChain.Filters[hcmIndex].ConfigType = &listenerv3.Filter_TypedConfig{
-			TypedConfig: anyConnectionMgr,
+	s.setJWTProviderJWKSURI(jwtp, jwksUri)
+	s.setJWTProviderCondition(jwtp, v1alpha1.JWTProviderConditionResolvedJwks, metav1.ConditionTrue, v1alpha1.JWTProviderReasonResolved, "The JWKS URI is resolved")
+
+	remoteJwks := &jwtauth3.RemoteJwks{
+		HttpUri: &corev3.HttpUri{
+			Uri: jwksUri,
+			HttpUpstreamType: &corev3.HttpUri_Cluster{
+				Cluster: urlCLuster.CustomName(),
+			},
+			Timeout: &durationpb.Duration{Seconds: jwksTimeoutSec},
+		},
+		CacheDuration: &durationpb.Duration{Seconds: jwksCacheDurationSec},
+		AsyncFetch: &jwtauth3.JwksAsyncFetch{
+			FastListener:          true,
+			FailedRefetchDuration: &durationpb.Duration{Seconds: jwksFailedRefetchSec},
+		},
+	}
+	// Set the retry policy if it exists.
+	if jwtp.Spec.RemoteJwks != nil && jwtp.Spec.RemoteJwks.Retry != nil {
+		rp, err := buildNonRouteRetryPolicy(jwtp.Spec.RemoteJwks.Retry)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		slogctx.Info(ctx, "Processed HTTPConnectionManager", "index", hcmIndex, "name", currChain.GetName())
+		remoteJwks.RetryPolicy = rp
 	}
 
-	return nil
+	return remoteJwks, urlCLuster, nil
 }
 
 // Tries to find the JWT Authentication HTTP filter in the provided chain
@@ -292,6 +792,22 @@ func findJwtAuthenticationFilter(chain []*hcm.HttpFilter) (*jwtauth3.JwtAuthenti
 	return nil, -1, nil
 }
 
+// buildJwtCacheConfig returns the cache of the verified JWTs, nil when disabled. The settings not
+// defined by the JWT provider default to the extension ones.
+func (s *GatewayExtension) buildJwtCacheConfig(cache *v1alpha1.JWTCache) *jwtauth3.JwtCacheConfig {
+	enabled, size := s.jwtCacheEnabled, s.jwtCacheSize
+	if cache != nil {
+		enabled = ptr.Deref(cache.Enabled, enabled)
+		size = ptr.Deref(cache.Size, size)
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	return &jwtauth3.JwtCacheConfig{JwtCacheSize: size}
+}
+
 // buildJwtFromHeaders returns a list of JwtHeader transformed from JWTFromHeader struct
 func buildJwtFromHeaders(headers []*v1alpha1.JWTHeader) []*jwtauth3.JwtHeader {
 	jwtHeaders := make([]*jwtauth3.JwtHeader, 0, len(headers))
@@ -313,6 +829,11 @@ func buildJwtClaimToHeader(headers []*v1alpha1.JWTClaimToHeader) []*jwtauth3.Jwt
 	jwtHeaders := make([]*jwtauth3.JwtClaimToHeader, 0, len(headers))
 
 	for _, header := range headers {
+		// The other encodings are handled by the claim to headers Lua filter
+		if !isNativeClaimEncoding(header.Encoding) {
+			continue
+		}
+
 		jwtHeader := &jwtauth3.JwtClaimToHeader{
 			HeaderName: header.HeaderName,
 			ClaimName:  header.ClaimName,
@@ -372,46 +893,3 @@ func buildNonRouteRetryPolicy(rr *v1alpha1.Retry) (*corev3.RetryPolicy, error) {
 
 	return rp, nil
 }
-
-type wellKnownOpenIDConfiguration struct {
-	Issuer string `json:"issuer"`
-	JURIS  string `json:"jwks_uri"`
-}
-
-func extractJWKSFromWellKnownOpenIDConfiguration(ctx context.Context, issuer string) (string, error) {
-	wkoc := wellKnownOpenIDConfiguration{}
-
-	parsedURL, err := url.Parse(issuer)
-	if err != nil {
-		return "", err
-	}
-
-	wkocURI := parsedURL.JoinPath(".well-known/openid-configuration")
-
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, wkocURI.String(), nil)
-	if err != nil {
-		return "", fmt.Errorf("could not build request to get well known OpenID configuration: %w", err)
-	}
-
-	client := http.DefaultClient
-
-	response, err := client.Do(request)
-	if err != nil {
-		return "", fmt.Errorf("could not get well known OpenID configuration: %w", err)
-	}
-
-	defer func() {
-		err := response.Body.Close()
-		if err != nil {
-			slogctx.Error(ctx, "could not close response body", "error", err)
-		}
-	}()
-
-	// decode the well known OpenID configuration
-	err = json.NewDecoder(response.Body).Decode(&wkoc)
-	if err != nil {
-		return "", fmt.Errorf("could not decode well known OpenID configuration: %w", err)
-	}
-
-	return wkoc.JURIS, nil
-}
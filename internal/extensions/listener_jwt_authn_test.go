@@ -0,0 +1,361 @@
+package extensions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func TestValidateJWTProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v1alpha1.JWTProviderSpec
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "No lifetime",
+			spec:    v1alpha1.JWTProviderSpec{},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Lifetime with required expiration",
+			spec:    v1alpha1.JWTProviderSpec{MaxLifetime: &metav1.Duration{Duration: time.Hour}, RequireExpiration: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Lifetime without required expiration",
+			spec:    v1alpha1.JWTProviderSpec{MaxLifetime: &metav1.Duration{Duration: time.Hour}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Non positive lifetime",
+			spec:    v1alpha1.JWTProviderSpec{MaxLifetime: &metav1.Duration{}, RequireExpiration: true},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Header and payload in distinct metadata",
+			spec:    v1alpha1.JWTProviderSpec{Name: "Provider", HeaderInMetadata: "jwt_header"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Header in the metadata of the payload",
+			spec:    v1alpha1.JWTProviderSpec{Name: "Provider", HeaderInMetadata: "Provider"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Header in the custom metadata of the payload",
+			spec:    v1alpha1.JWTProviderSpec{Name: "Provider", PayloadInMetadata: "jwt", HeaderInMetadata: "jwt"},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJWTProvider(&v1alpha1.JWTProvider{Spec: tt.spec})
+			if tt.wantErr(t, err) && err != nil {
+				assert.ErrorIs(t, err, ErrInvalidJWTProvider)
+			}
+		})
+	}
+}
+
+func TestGatewayExtension_buildJWTProvider_Lifetime(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	jwt, _, err := s.buildJWTProvider(t.Context(), &v1alpha1.JWTProvider{
+		Spec: v1alpha1.JWTProviderSpec{
+			Name:              "Local",
+			Issuer:            "https://issuer.example.com",
+			LocalJwks:         &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)},
+			RequireExpiration: true,
+			MaxLifetime:       &metav1.Duration{Duration: 15 * time.Minute},
+			ClockSkewSeconds:  ptr.To(uint32(5)),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, jwt.GetMaxLifetime().AsDuration())
+	assert.Equal(t, uint32(5), jwt.GetClockSkewSeconds())
+
+	jwt, _, err = s.buildJWTProvider(t.Context(), &v1alpha1.JWTProvider{
+		Spec: v1alpha1.JWTProviderSpec{
+			Name:        "Local",
+			Issuer:      "https://issuer.example.com",
+			LocalJwks:   &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)},
+			MaxLifetime: &metav1.Duration{Duration: 15 * time.Minute},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, jwt, "the provider is skipped if maxLifetime is set without requireExpiration")
+}
+
+func TestGatewayExtension_buildJWTProvider_Forward(t *testing.T) {
+	tests := []struct {
+		name                        string
+		spec                        v1alpha1.JWTProviderSpec
+		wantForward                 bool
+		wantForwardPayloadHeader    string
+		wantPadForwardPayloadHeader bool
+	}{
+		{
+			name:        "Default",
+			spec:        v1alpha1.JWTProviderSpec{},
+			wantForward: true,
+		},
+		{
+			name:        "Not forwarded",
+			spec:        v1alpha1.JWTProviderSpec{Forward: ptr.To(false)},
+			wantForward: false,
+		},
+		{
+			name: "Forwarded payload",
+			spec: v1alpha1.JWTProviderSpec{
+				Forward:                 ptr.To(false),
+				ForwardPayloadHeader:    "x-jwt-payload",
+				PadForwardPayloadHeader: true,
+			},
+			wantForward:                 false,
+			wantForwardPayloadHeader:    "x-jwt-payload",
+			wantPadForwardPayloadHeader: true,
+		},
+		{
+			name:        "Padding without payload header",
+			spec:        v1alpha1.JWTProviderSpec{PadForwardPayloadHeader: true},
+			wantForward: true,
+		},
+	}
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.Name = "Local"
+			spec.Issuer = "https://issuer.example.com"
+			spec.LocalJwks = &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)}
+
+			jwt, _, err := s.buildJWTProvider(t.Context(), &v1alpha1.JWTProvider{Spec: spec})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantForward, jwt.GetForward())
+			assert.Equal(t, tt.wantForwardPayloadHeader, jwt.GetForwardPayloadHeader())
+			assert.Equal(t, tt.wantPadForwardPayloadHeader, jwt.GetPadForwardPayloadHeader())
+		})
+	}
+}
+
+func TestGatewayExtension_buildJWTProvider_Cache(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		spec v1alpha1.JWTProviderSpec
+		want *jwtauth3.JwtCacheConfig
+	}{
+		{
+			name: "Disabled by default",
+		},
+		{
+			name: "Extension cache",
+			opts: []Option{WithJWTCache(true, 1000)},
+			want: &jwtauth3.JwtCacheConfig{JwtCacheSize: 1000},
+		},
+		{
+			name: "Disabled by the JWT provider",
+			opts: []Option{WithJWTCache(true, 1000)},
+			spec: v1alpha1.JWTProviderSpec{Cache: &v1alpha1.JWTCache{Enabled: ptr.To(false)}},
+		},
+		{
+			name: "Size of the JWT provider",
+			opts: []Option{WithJWTCache(true, 1000)},
+			spec: v1alpha1.JWTProviderSpec{Cache: &v1alpha1.JWTCache{Size: ptr.To(uint32(50))}},
+			want: &jwtauth3.JwtCacheConfig{JwtCacheSize: 50},
+		},
+		{
+			name: "Enabled by the JWT provider",
+			opts: []Option{WithJWTCache(false, 1000)},
+			spec: v1alpha1.JWTProviderSpec{Cache: &v1alpha1.JWTCache{Enabled: ptr.To(true)}},
+			want: &jwtauth3.JwtCacheConfig{JwtCacheSize: 1000},
+		},
+		{
+			name: "Envoy default size",
+			spec: v1alpha1.JWTProviderSpec{Cache: &v1alpha1.JWTCache{Enabled: ptr.To(true)}},
+			want: &jwtauth3.JwtCacheConfig{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGatewayExtension(&commoncfg.FeatureGates{}, tt.opts...)
+
+			spec := tt.spec
+			spec.Name = "Local"
+			spec.Issuer = "https://issuer.example.com"
+			spec.LocalJwks = &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)}
+
+			jwt, _, err := s.buildJWTProvider(t.Context(), &v1alpha1.JWTProvider{Spec: spec})
+			assert.NoError(t, err)
+
+			diff := cmp.Diff(tt.want, jwt.GetJwtCacheConfig(), protocmp.Transform())
+			assert.Empty(t, diff)
+		})
+	}
+}
+
+func TestGatewayExtension_buildJWTProvider_Metadata(t *testing.T) {
+	tests := []struct {
+		name                     string
+		spec                     v1alpha1.JWTProviderSpec
+		wantPayloadInMetadata    string
+		wantHeaderInMetadata     string
+		wantSpaceDelimitedClaims []string
+	}{
+		{
+			name:                     "Default",
+			wantPayloadInMetadata:    "Local",
+			wantSpaceDelimitedClaims: []string{"scope"},
+		},
+		{
+			name: "Custom",
+			spec: v1alpha1.JWTProviderSpec{
+				PayloadInMetadata:    "jwt_payload",
+				HeaderInMetadata:     "jwt_header",
+				SpaceDelimitedClaims: []string{"scp", "roles"},
+			},
+			wantPayloadInMetadata:    "jwt_payload",
+			wantHeaderInMetadata:     "jwt_header",
+			wantSpaceDelimitedClaims: []string{"scp", "roles"},
+		},
+	}
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.Name = "Local"
+			spec.Issuer = "https://issuer.example.com"
+			spec.LocalJwks = &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)}
+
+			jwt, _, err := s.buildJWTProvider(t.Context(), &v1alpha1.JWTProvider{Spec: spec})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPayloadInMetadata, jwt.GetPayloadInMetadata())
+			assert.Equal(t, tt.wantHeaderInMetadata, jwt.GetHeaderInMetadata())
+			assert.Equal(t, tt.wantSpaceDelimitedClaims, jwt.GetNormalizePayloadInMetadata().GetSpaceDelimitedClaims())
+		})
+	}
+}
+
+func TestReserveMetadataKeys(t *testing.T) {
+	keys := make(map[string]string)
+
+	err := reserveMetadataKeys(keys, "A", &jwtauth3.JwtProvider{PayloadInMetadata: "jwt", HeaderInMetadata: "jwt_header"})
+	assert.NoError(t, err)
+
+	// The same provider can be reserved again
+	err = reserveMetadataKeys(keys, "A", &jwtauth3.JwtProvider{PayloadInMetadata: "jwt", HeaderInMetadata: "jwt_header"})
+	assert.NoError(t, err)
+
+	err = reserveMetadataKeys(keys, "B", &jwtauth3.JwtProvider{PayloadInMetadata: "B", HeaderInMetadata: "jwt"})
+	assert.ErrorIs(t, err, ErrMetadataKeyConflict)
+
+	// Nothing is reserved for the conflicting provider
+	_, ok := keys["B"]
+	assert.False(t, ok)
+
+	err = reserveMetadataKeys(keys, "C", &jwtauth3.JwtProvider{PayloadInMetadata: "C"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"jwt": "A", "jwt_header": "A", "C": "C"}, keys)
+}
+
+func TestChainRouteProviders(t *testing.T) {
+	provider := func(namespace, name string) *v1alpha1.JWTProvider {
+		return &v1alpha1.JWTProvider{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "idp"},
+			Spec:       v1alpha1.JWTProviderSpec{Name: name},
+		}
+	}
+
+	kms, other := provider("kms", "idp"), provider("other", "idp")
+	built := map[types.NamespacedName]builtJWTProvider{
+		jwtProviderKey(kms):   {resource: kms, provider: &jwtauth3.JwtProvider{Issuer: "kms"}},
+		jwtProviderKey(other): {resource: other, provider: &jwtauth3.JwtProvider{Issuer: "other"}},
+	}
+
+	detached := provider("kms", "detached")
+	detached.Name = "detached"
+	detached.Spec.TargetRefs = []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("other", nil)}
+	built[jwtProviderKey(detached)] = builtJWTProvider{resource: detached, provider: &jwtauth3.JwtProvider{Issuer: "detached"}}
+
+	targets := []listenerTarget{{namespace: "kms", gateway: "gateway", sectionName: "https"}}
+
+	tests := []struct {
+		name           string
+		chainProviders map[string]builtJWTProvider
+		requirement    []*v1alpha1.JWTProvider
+		want           []string
+		wantErr        assert.ErrorAssertionFunc
+	}{
+		{
+			name:           "Built provider",
+			chainProviders: map[string]builtJWTProvider{},
+			requirement:    []*v1alpha1.JWTProvider{kms},
+			want:           []string{"kms"},
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "Provider already registered on the chain",
+			chainProviders: map[string]builtJWTProvider{"idp": built[jwtProviderKey(kms)]},
+			requirement:    []*v1alpha1.JWTProvider{kms},
+			want:           []string{"kms"},
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "Provider of another namespace with the same name",
+			chainProviders: map[string]builtJWTProvider{"idp": built[jwtProviderKey(kms)]},
+			requirement:    []*v1alpha1.JWTProvider{other},
+			wantErr:        assert.Error,
+		},
+		{
+			name:           "Provider targeting another Gateway",
+			chainProviders: map[string]builtJWTProvider{},
+			requirement:    []*v1alpha1.JWTProvider{kms, detached},
+			want:           []string{"kms"},
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "No provider targeting the Gateway",
+			chainProviders: map[string]builtJWTProvider{},
+			requirement:    []*v1alpha1.JWTProvider{detached},
+			wantErr:        assert.Error,
+		},
+		{
+			name:           "Provider not built",
+			chainProviders: map[string]builtJWTProvider{},
+			requirement:    []*v1alpha1.JWTProvider{provider("missing", "idp")},
+			wantErr:        assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chainRouteProviders(tt.chainProviders, built, targets, tt.requirement)
+			if !tt.wantErr(t, err) {
+				return
+			}
+
+			var issuers []string
+			for _, p := range got {
+				issuers = append(issuers, p.provider.GetIssuer())
+			}
+
+			assert.Equal(t, tt.want, issuers)
+		})
+	}
+}
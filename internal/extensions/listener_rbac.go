@@ -0,0 +1,398 @@
+package extensions
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacconfigv3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	slogctx "github.com/veqryn/slog-context"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const (
+	// ClaimAuthzDenyFilterName is the name of the RBAC filter rejecting the requests matching a Deny policy.
+	ClaimAuthzDenyFilterName = "envoy.filters.http.rbac/openkcm_claims_deny"
+	// ClaimAuthzAllowFilterName is the name of the RBAC filter rejecting the requests not matching any Allow policy.
+	ClaimAuthzAllowFilterName = "envoy.filters.http.rbac/openkcm_claims_allow"
+
+	claimAuthzFilterPrefix  = "envoy.filters.http.rbac/openkcm_claims_"
+	claimAuthzStatPrefix    = "openkcm_claims_"
+	jwtAuthnMetadataFilter  = "envoy.filters.http.jwt_authn"
+	claimPathSeparator      = "."
+	requestMethodHeaderName = ":method"
+)
+
+// ProcessClaimAuthorizationPolicies compiles the ClaimAuthorizationPolicies targeting each filter chain into
+// RBAC filters inserted right after the JWT authentication filter. The claims are read from the
+// verified payload the JWT providers write in the dynamic metadata under their PayloadInMetadata key.
+func (s *GatewayExtension) ProcessClaimAuthorizationPolicies(ctx context.Context, listener *listenerv3.Listener, resources []any) error {
+	slogctx.Info(ctx, "Processing ClaimAuthorizationPolicies", "number", len(resources))
+
+	policies := make([]*v1alpha1.ClaimAuthorizationPolicy, 0, len(resources))
+
+	for _, resource := range resources {
+		policy, ok := resource.(*v1alpha1.ClaimAuthorizationPolicy)
+		if !ok {
+			continue
+		}
+
+		policies = append(policies, policy)
+	}
+
+	for _, currChain := range listenerFilterChains(listener) {
+		httpConManager, hcmIndex, err := findHCM(currChain)
+		if err != nil {
+			slogctx.Warn(ctx, "Failed to find an HCM in the current chain", "filter-chain", currChain.GetName())
+			continue
+		}
+
+		jwtAuthFilter, jwtIndex, err := findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+		if err != nil {
+			slogctx.Warn(ctx, "Failed to unmarshal the existing jwtAuthFilter filter; Continue.",
+				"name", currChain.GetName(), "error", err)
+
+			continue
+		}
+
+		// The policies are only enforced on the filter chains of the Gateway listeners they target
+		targets := filterChainTargets(listener, currChain)
+		chainPolicies := slices.DeleteFunc(slices.Clone(policies), func(policy *v1alpha1.ClaimAuthorizationPolicy) bool {
+			return !matchesTargetRefs(policy.GetNamespace(), policy.Spec.TargetRefs, targets)
+		})
+
+		rbacFilters, err := s.buildClaimAuthorizationFilters(ctx, chainPolicies, jwtAuthFilter.GetProviders())
+		if err != nil {
+			return err
+		}
+
+		httpConManager.HttpFilters = replaceFiltersAfter(httpConManager.GetHttpFilters(), jwtIndex, claimAuthzFilterPrefix, rbacFilters)
+
+		// Write the updated HCM back to the filter chain
+		anyConnectionMgr, err := newAny(httpConManager)
+		if err != nil {
+			return err
+		}
+
+		currChain.Filters[hcmIndex].ConfigType = &listenerv3.Filter_TypedConfig{
+			TypedConfig: anyConnectionMgr,
+		}
+
+		slogctx.Info(ctx, "Processed ClaimAuthorizationPolicies", "name", currChain.GetName(), "filters", len(rbacFilters))
+	}
+
+	return nil
+}
+
+// buildClaimAuthorizationFilters returns the RBAC filters of the policies, the Deny filter first. No
+// filter is returned for an action without any rule built, as an Allow filter without policies would
+// reject every request.
+func (s *GatewayExtension) buildClaimAuthorizationFilters(
+	ctx context.Context,
+	policies []*v1alpha1.ClaimAuthorizationPolicy,
+	providers map[string]*jwtauth3.JwtProvider,
+) ([]*hcm.HttpFilter, error) {
+	byAction := map[rbacconfigv3.RBAC_Action]*rbacconfigv3.RBAC{}
+
+	for _, policy := range policies {
+		action := rbacconfigv3.RBAC_ALLOW
+		if policy.Spec.Action == v1alpha1.ClaimAuthorizationActionDeny {
+			action = rbacconfigv3.RBAC_DENY
+		}
+
+		rules, ok := byAction[action]
+		if !ok {
+			rules = &rbacconfigv3.RBAC{
+				Action:   action,
+				Policies: make(map[string]*rbacconfigv3.Policy),
+			}
+			byAction[action] = rules
+		}
+
+		for _, rule := range policy.Spec.Rules {
+			principal := buildClaimPrincipal(ctx, &rule, providers)
+			if principal == nil {
+				slogctx.Error(ctx, "Skipping ClaimAuthorizationPolicy rule without known JWT provider",
+					"name", policy.GetName(), "namespace", policy.GetNamespace(), "rule", rule.Name)
+				s.metrics.recordError(ctx, reasonInvalidResource)
+
+				continue
+			}
+
+			rules.Policies[claimPolicyName(policy, rule.Name)] = &rbacconfigv3.Policy{
+				Permissions: []*rbacconfigv3.Permission{buildClaimPermission(&rule)},
+				Principals:  []*rbacconfigv3.Principal{principal},
+			}
+		}
+	}
+
+	filters := make([]*hcm.HttpFilter, 0, len(byAction))
+
+	for _, action := range []rbacconfigv3.RBAC_Action{rbacconfigv3.RBAC_DENY, rbacconfigv3.RBAC_ALLOW} {
+		rules, ok := byAction[action]
+		if !ok {
+			continue
+		}
+
+		if len(rules.GetPolicies()) == 0 {
+			slogctx.Error(ctx, "Skipping the claim authorization filter as none of its ClaimAuthorizationPolicy rules was built",
+				"action", action.String())
+
+			continue
+		}
+
+		name := ClaimAuthzAllowFilterName
+		if action == rbacconfigv3.RBAC_DENY {
+			name = ClaimAuthzDenyFilterName
+		}
+
+		anyFilterConfig, err := newAny(&rbacv3.RBAC{
+			Rules:           rules,
+			RulesStatPrefix: claimAuthzStatPrefix + strings.ToLower(action.String()) + "_",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, &hcm.HttpFilter{
+			Name: name,
+			ConfigType: &hcm.HttpFilter_TypedConfig{
+				TypedConfig: anyFilterConfig,
+			},
+		})
+	}
+
+	return filters, nil
+}
+
+// claimPolicyName returns the RBAC policy name of a ClaimAuthorizationPolicy rule.
+func claimPolicyName(policy *v1alpha1.ClaimAuthorizationPolicy, ruleName string) string {
+	return policy.GetNamespace() + "/" + policy.GetName() + "/" + ruleName
+}
+
+// buildClaimPrincipal returns the principal matching a payload, verified by any of the rule providers,
+// whose claims match all the rule claims. Nil is returned if none of the rule providers is known.
+func buildClaimPrincipal(ctx context.Context, rule *v1alpha1.ClaimAuthorizationRule, providers map[string]*jwtauth3.JwtProvider) *rbacconfigv3.Principal {
+	providerNames := rule.Providers
+	if len(providerNames) == 0 {
+		providerNames = slices.Sorted(maps.Keys(providers))
+	}
+
+	ids := make([]*rbacconfigv3.Principal, 0, len(providerNames))
+
+	for _, providerName := range providerNames {
+		provider, ok := providers[providerName]
+		if !ok {
+			slogctx.Warn(ctx, "Ignoring unknown JWT provider", "rule", rule.Name, "provider", providerName)
+			continue
+		}
+
+		payloadKey := provider.GetPayloadInMetadata()
+		if payloadKey == "" {
+			slogctx.Warn(ctx, "Ignoring JWT provider without verified payload in metadata",
+				"rule", rule.Name, "provider", providerName)
+
+			continue
+		}
+
+		claims := make([]*rbacconfigv3.Principal, 0, len(rule.Claims))
+		for _, claim := range rule.Claims {
+			claims = append(claims, buildClaimPrincipals(payloadKey, claim)...)
+		}
+
+		ids = append(ids, &rbacconfigv3.Principal{
+			Identifier: &rbacconfigv3.Principal_AndIds{
+				AndIds: &rbacconfigv3.Principal_Set{Ids: claims},
+			},
+		})
+	}
+
+	switch len(ids) {
+	case 0:
+		return nil
+	case 1:
+		return ids[0]
+	default:
+		return &rbacconfigv3.Principal{
+			Identifier: &rbacconfigv3.Principal_OrIds{
+				OrIds: &rbacconfigv3.Principal_Set{Ids: ids},
+			},
+		}
+	}
+}
+
+// buildClaimPrincipals returns the principals all matching a claim in the payload stored under the given
+// metadata key. An inverted matcher also matches a missing claim, or a missing payload, so the NotIn
+// operator requires the claim to be present as well.
+func buildClaimPrincipals(payloadKey string, claim v1alpha1.ClaimMatch) []*rbacconfigv3.Principal {
+	principals := make([]*rbacconfigv3.Principal, 0, 2)
+
+	if claim.Operator == v1alpha1.ClaimMatchOperatorNotIn {
+		principals = append(principals, &rbacconfigv3.Principal{
+			Identifier: &rbacconfigv3.Principal_Metadata{
+				Metadata: buildClaimMetadataMatcher(payloadKey, v1alpha1.ClaimMatch{
+					Claim:    claim.Claim,
+					Operator: v1alpha1.ClaimMatchOperatorExists,
+				}),
+			},
+		})
+	}
+
+	return append(principals, &rbacconfigv3.Principal{
+		Identifier: &rbacconfigv3.Principal_Metadata{
+			Metadata: buildClaimMetadataMatcher(payloadKey, claim),
+		},
+	})
+}
+
+// buildClaimMetadataMatcher returns the matcher of a claim in the payload stored under the given
+// metadata key. The In operator matches scalar claims equal to one of the values, as well as array
+// claims holding one of the values.
+func buildClaimMetadataMatcher(payloadKey string, claim v1alpha1.ClaimMatch) *matcherv3.MetadataMatcher {
+	path := []*matcherv3.MetadataMatcher_PathSegment{{
+		Segment: &matcherv3.MetadataMatcher_PathSegment_Key{Key: payloadKey},
+	}}
+
+	for _, segment := range strings.Split(claim.Claim, claimPathSeparator) {
+		path = append(path, &matcherv3.MetadataMatcher_PathSegment{
+			Segment: &matcherv3.MetadataMatcher_PathSegment_Key{Key: segment},
+		})
+	}
+
+	matcher := &matcherv3.MetadataMatcher{
+		Filter: jwtAuthnMetadataFilter,
+		Path:   path,
+	}
+
+	if claim.Operator == v1alpha1.ClaimMatchOperatorExists {
+		matcher.Value = &matcherv3.ValueMatcher{
+			MatchPattern: &matcherv3.ValueMatcher_PresentMatch{PresentMatch: true},
+		}
+
+		return matcher
+	}
+
+	values := make([]*matcherv3.ValueMatcher, 0, len(claim.Values))
+	for _, value := range claim.Values {
+		values = append(values, &matcherv3.ValueMatcher{
+			MatchPattern: &matcherv3.ValueMatcher_StringMatch{
+				StringMatch: &matcherv3.StringMatcher{
+					MatchPattern: &matcherv3.StringMatcher_Exact{Exact: value},
+				},
+			},
+		})
+	}
+
+	anyValue := &matcherv3.ValueMatcher{
+		MatchPattern: &matcherv3.ValueMatcher_OrMatch{
+			OrMatch: &matcherv3.OrMatcher{ValueMatchers: values},
+		},
+	}
+
+	matcher.Value = &matcherv3.ValueMatcher{
+		MatchPattern: &matcherv3.ValueMatcher_OrMatch{
+			OrMatch: &matcherv3.OrMatcher{
+				ValueMatchers: []*matcherv3.ValueMatcher{
+					anyValue,
+					{
+						MatchPattern: &matcherv3.ValueMatcher_ListMatch{
+							ListMatch: &matcherv3.ListMatcher{
+								MatchPattern: &matcherv3.ListMatcher_OneOf{OneOf: anyValue},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	matcher.Invert = claim.Operator == v1alpha1.ClaimMatchOperatorNotIn
+
+	return matcher
+}
+
+// buildClaimPathPermissions returns the permissions matching the path prefix on segment boundaries; the
+// path itself and the paths below it. A prefix ending with a slash only matches the paths below it.
+func buildClaimPathPermissions(path string) []*rbacconfigv3.Permission {
+	urlPath := func(pattern *matcherv3.StringMatcher) *rbacconfigv3.Permission {
+		return &rbacconfigv3.Permission{
+			Rule: &rbacconfigv3.Permission_UrlPath{
+				UrlPath: &matcherv3.PathMatcher{
+					Rule: &matcherv3.PathMatcher_Path{Path: pattern},
+				},
+			},
+		}
+	}
+
+	if strings.HasSuffix(path, "/") {
+		return []*rbacconfigv3.Permission{
+			urlPath(&matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Prefix{Prefix: path}}),
+		}
+	}
+
+	return []*rbacconfigv3.Permission{
+		urlPath(&matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: path}}),
+		urlPath(&matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Prefix{Prefix: path + "/"}}),
+	}
+}
+
+// buildClaimPermission returns the permission matching the rule paths and methods.
+func buildClaimPermission(rule *v1alpha1.ClaimAuthorizationRule) *rbacconfigv3.Permission {
+	rules := make([]*rbacconfigv3.Permission, 0, 2)
+
+	if len(rule.Paths) > 0 {
+		paths := make([]*rbacconfigv3.Permission, 0, 2*len(rule.Paths))
+		for _, path := range rule.Paths {
+			paths = append(paths, buildClaimPathPermissions(path)...)
+		}
+
+		rules = append(rules, &rbacconfigv3.Permission{
+			Rule: &rbacconfigv3.Permission_OrRules{
+				OrRules: &rbacconfigv3.Permission_Set{Rules: paths},
+			},
+		})
+	}
+
+	if len(rule.Methods) > 0 {
+		methods := make([]*rbacconfigv3.Permission, 0, len(rule.Methods))
+		for _, method := range rule.Methods {
+			methods = append(methods, &rbacconfigv3.Permission{
+				Rule: &rbacconfigv3.Permission_Header{
+					Header: &routev3.HeaderMatcher{
+						Name: requestMethodHeaderName,
+						HeaderMatchSpecifier: &routev3.HeaderMatcher_StringMatch{
+							StringMatch: &matcherv3.StringMatcher{
+								MatchPattern: &matcherv3.StringMatcher_Exact{Exact: strings.ToUpper(method)},
+							},
+						},
+					},
+				},
+			})
+		}
+
+		rules = append(rules, &rbacconfigv3.Permission{
+			Rule: &rbacconfigv3.Permission_OrRules{
+				OrRules: &rbacconfigv3.Permission_Set{Rules: methods},
+			},
+		})
+	}
+
+	if len(rules) == 0 {
+		return &rbacconfigv3.Permission{
+			Rule: &rbacconfigv3.Permission_Any{Any: true},
+		}
+	}
+
+	return &rbacconfigv3.Permission{
+		Rule: &rbacconfigv3.Permission_AndRules{
+			AndRules: &rbacconfigv3.Permission_Set{Rules: rules},
+		},
+	}
+}
@@ -0,0 +1,584 @@
+package extensions
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/google/go-cmp/cmp"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacconfigv3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+	"github.com/openkcm/gateway-extension/internal/extensions/testdata"
+)
+
+func metadataPath(keys ...string) []*matcherv3.MetadataMatcher_PathSegment {
+	path := make([]*matcherv3.MetadataMatcher_PathSegment, 0, len(keys))
+	for _, key := range keys {
+		path = append(path, &matcherv3.MetadataMatcher_PathSegment{
+			Segment: &matcherv3.MetadataMatcher_PathSegment_Key{Key: key},
+		})
+	}
+
+	return path
+}
+
+func inValueMatcher(values ...string) *matcherv3.ValueMatcher {
+	matchers := make([]*matcherv3.ValueMatcher, 0, len(values))
+	for _, value := range values {
+		matchers = append(matchers, &matcherv3.ValueMatcher{
+			MatchPattern: &matcherv3.ValueMatcher_StringMatch{
+				StringMatch: &matcherv3.StringMatcher{
+					MatchPattern: &matcherv3.StringMatcher_Exact{Exact: value},
+				},
+			},
+		})
+	}
+
+	anyValue := &matcherv3.ValueMatcher{
+		MatchPattern: &matcherv3.ValueMatcher_OrMatch{
+			OrMatch: &matcherv3.OrMatcher{ValueMatchers: matchers},
+		},
+	}
+
+	return &matcherv3.ValueMatcher{
+		MatchPattern: &matcherv3.ValueMatcher_OrMatch{
+			OrMatch: &matcherv3.OrMatcher{
+				ValueMatchers: []*matcherv3.ValueMatcher{
+					anyValue,
+					{
+						MatchPattern: &matcherv3.ValueMatcher_ListMatch{
+							ListMatch: &matcherv3.ListMatcher{
+								MatchPattern: &matcherv3.ListMatcher_OneOf{OneOf: anyValue},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestBuildClaimMetadataMatcher(t *testing.T) {
+	tests := []struct {
+		name  string
+		claim v1alpha1.ClaimMatch
+		want  *matcherv3.MetadataMatcher
+	}{
+		{
+			name:  "In",
+			claim: v1alpha1.ClaimMatch{Claim: "scope", Operator: v1alpha1.ClaimMatchOperatorIn, Values: []string{"read", "write"}},
+			want: &matcherv3.MetadataMatcher{
+				Filter: jwtAuthnMetadataFilter,
+				Path:   metadataPath("Provider", "scope"),
+				Value:  inValueMatcher("read", "write"),
+			},
+		},
+		{
+			name:  "NotIn on a nested claim",
+			claim: v1alpha1.ClaimMatch{Claim: "realm_access.roles", Operator: v1alpha1.ClaimMatchOperatorNotIn, Values: []string{"guest"}},
+			want: &matcherv3.MetadataMatcher{
+				Filter: jwtAuthnMetadataFilter,
+				Path:   metadataPath("Provider", "realm_access", "roles"),
+				Value:  inValueMatcher("guest"),
+				Invert: true,
+			},
+		},
+		{
+			name:  "Exists",
+			claim: v1alpha1.ClaimMatch{Claim: "tenant", Operator: v1alpha1.ClaimMatchOperatorExists},
+			want: &matcherv3.MetadataMatcher{
+				Filter: jwtAuthnMetadataFilter,
+				Path:   metadataPath("Provider", "tenant"),
+				Value: &matcherv3.ValueMatcher{
+					MatchPattern: &matcherv3.ValueMatcher_PresentMatch{PresentMatch: true},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := cmp.Diff(tt.want, buildClaimMetadataMatcher("Provider", tt.claim), protocmp.Transform())
+			assert.Empty(t, diff)
+		})
+	}
+}
+
+// matchesClaimPrincipal evaluates, as Envoy does, the principals built from the claims against the
+// metadata written by the JWT authentication filter, nil if no JWT was verified.
+func matchesClaimPrincipal(t *testing.T, principal *rbacconfigv3.Principal, metadata *structpb.Struct) bool {
+	t.Helper()
+
+	switch id := principal.GetIdentifier().(type) {
+	case *rbacconfigv3.Principal_AndIds:
+		for _, p := range id.AndIds.GetIds() {
+			if !matchesClaimPrincipal(t, p, metadata) {
+				return false
+			}
+		}
+
+		return true
+	case *rbacconfigv3.Principal_OrIds:
+		for _, p := range id.OrIds.GetIds() {
+			if matchesClaimPrincipal(t, p, metadata) {
+				return true
+			}
+		}
+
+		return false
+	case *rbacconfigv3.Principal_Metadata:
+		assert.Equal(t, jwtAuthnMetadataFilter, id.Metadata.GetFilter())
+
+		value := structpb.NewNullValue()
+		if metadata != nil {
+			value = structpb.NewStructValue(metadata)
+		}
+
+		for _, segment := range id.Metadata.GetPath() {
+			field, ok := value.GetStructValue().GetFields()[segment.GetKey()]
+			if !ok {
+				value = structpb.NewNullValue()
+				break
+			}
+
+			value = field
+		}
+
+		return matchesClaimValue(t, id.Metadata.GetValue(), value) != id.Metadata.GetInvert()
+	default:
+		t.Fatalf("unexpected principal %v", principal)
+		return false
+	}
+}
+
+// matchesClaimValue evaluates the value matchers built from the claims.
+func matchesClaimValue(t *testing.T, matcher *matcherv3.ValueMatcher, value *structpb.Value) bool {
+	t.Helper()
+
+	switch pattern := matcher.GetMatchPattern().(type) {
+	case *matcherv3.ValueMatcher_PresentMatch:
+		_, isNull := value.GetKind().(*structpb.Value_NullValue)
+		return pattern.PresentMatch && !isNull
+	case *matcherv3.ValueMatcher_StringMatch:
+		s, ok := value.GetKind().(*structpb.Value_StringValue)
+		return ok && s.StringValue == pattern.StringMatch.GetExact()
+	case *matcherv3.ValueMatcher_OrMatch:
+		for _, m := range pattern.OrMatch.GetValueMatchers() {
+			if matchesClaimValue(t, m, value) {
+				return true
+			}
+		}
+
+		return false
+	case *matcherv3.ValueMatcher_ListMatch:
+		for _, v := range value.GetListValue().GetValues() {
+			if matchesClaimValue(t, pattern.ListMatch.GetOneOf(), v) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		t.Fatalf("unexpected value matcher %v", matcher)
+		return false
+	}
+}
+
+func TestBuildClaimPrincipals(t *testing.T) {
+	payload := func(claims map[string]any) *structpb.Struct {
+		metadata, err := structpb.NewStruct(map[string]any{"Provider": claims})
+		assert.NoError(t, err)
+
+		return metadata
+	}
+
+	tests := []struct {
+		name     string
+		claim    v1alpha1.ClaimMatch
+		metadata *structpb.Struct
+		want     bool
+	}{
+		{
+			name:     "In with a matching claim",
+			claim:    v1alpha1.ClaimMatch{Claim: "scope", Operator: v1alpha1.ClaimMatchOperatorIn, Values: []string{"read"}},
+			metadata: payload(map[string]any{"scope": []any{"read", "write"}}),
+			want:     true,
+		},
+		{
+			name:     "In with a missing claim",
+			claim:    v1alpha1.ClaimMatch{Claim: "scope", Operator: v1alpha1.ClaimMatchOperatorIn, Values: []string{"read"}},
+			metadata: payload(map[string]any{"sub": "alice"}),
+			want:     false,
+		},
+		{
+			name:     "NotIn with another value",
+			claim:    v1alpha1.ClaimMatch{Claim: "realm_access.roles", Operator: v1alpha1.ClaimMatchOperatorNotIn, Values: []string{"guest"}},
+			metadata: payload(map[string]any{"realm_access": map[string]any{"roles": []any{"admin"}}}),
+			want:     true,
+		},
+		{
+			name:     "NotIn with an excluded value",
+			claim:    v1alpha1.ClaimMatch{Claim: "realm_access.roles", Operator: v1alpha1.ClaimMatchOperatorNotIn, Values: []string{"guest"}},
+			metadata: payload(map[string]any{"realm_access": map[string]any{"roles": []any{"admin", "guest"}}}),
+			want:     false,
+		},
+		{
+			name:     "NotIn with a missing claim",
+			claim:    v1alpha1.ClaimMatch{Claim: "realm_access.roles", Operator: v1alpha1.ClaimMatchOperatorNotIn, Values: []string{"guest"}},
+			metadata: payload(map[string]any{"sub": "alice"}),
+			want:     false,
+		},
+		{
+			name:     "NotIn without JWT",
+			claim:    v1alpha1.ClaimMatch{Claim: "realm_access.roles", Operator: v1alpha1.ClaimMatchOperatorNotIn, Values: []string{"guest"}},
+			metadata: nil,
+			want:     false,
+		},
+		{
+			name:     "Exists without JWT",
+			claim:    v1alpha1.ClaimMatch{Claim: "tenant", Operator: v1alpha1.ClaimMatchOperatorExists},
+			metadata: nil,
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal := &rbacconfigv3.Principal{
+				Identifier: &rbacconfigv3.Principal_AndIds{
+					AndIds: &rbacconfigv3.Principal_Set{Ids: buildClaimPrincipals("Provider", tt.claim)},
+				},
+			}
+
+			// The principals of an Allow rule admit the request, the ones of a Deny rule reject it
+			assert.Equal(t, tt.want, matchesClaimPrincipal(t, principal, tt.metadata))
+		})
+	}
+}
+
+func TestBuildClaimPermission(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *v1alpha1.ClaimAuthorizationRule
+		want *rbacconfigv3.Permission
+	}{
+		{
+			name: "Any request",
+			rule: &v1alpha1.ClaimAuthorizationRule{},
+			want: &rbacconfigv3.Permission{Rule: &rbacconfigv3.Permission_Any{Any: true}},
+		},
+		{
+			name: "Paths and methods",
+			rule: &v1alpha1.ClaimAuthorizationRule{Paths: []string{"/admin"}, Methods: []string{"post"}},
+			want: &rbacconfigv3.Permission{
+				Rule: &rbacconfigv3.Permission_AndRules{
+					AndRules: &rbacconfigv3.Permission_Set{Rules: []*rbacconfigv3.Permission{
+						{Rule: &rbacconfigv3.Permission_OrRules{OrRules: &rbacconfigv3.Permission_Set{Rules: []*rbacconfigv3.Permission{
+							{Rule: &rbacconfigv3.Permission_UrlPath{UrlPath: &matcherv3.PathMatcher{
+								Rule: &matcherv3.PathMatcher_Path{Path: &matcherv3.StringMatcher{
+									MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "/admin"},
+								}},
+							}}},
+							{Rule: &rbacconfigv3.Permission_UrlPath{UrlPath: &matcherv3.PathMatcher{
+								Rule: &matcherv3.PathMatcher_Path{Path: &matcherv3.StringMatcher{
+									MatchPattern: &matcherv3.StringMatcher_Prefix{Prefix: "/admin/"},
+								}},
+							}}},
+						}}}},
+						{Rule: &rbacconfigv3.Permission_OrRules{OrRules: &rbacconfigv3.Permission_Set{Rules: []*rbacconfigv3.Permission{{
+							Rule: &rbacconfigv3.Permission_Header{Header: &routev3.HeaderMatcher{
+								Name: ":method",
+								HeaderMatchSpecifier: &routev3.HeaderMatcher_StringMatch{StringMatch: &matcherv3.StringMatcher{
+									MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "POST"},
+								}},
+							}},
+						}}}}},
+					}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := cmp.Diff(tt.want, buildClaimPermission(tt.rule), protocmp.Transform())
+			assert.Empty(t, diff)
+		})
+	}
+}
+
+func TestBuildClaimPathPermissions(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		paths map[string]bool
+	}{
+		{
+			name:  "Prefix without trailing slash",
+			path:  "/admin",
+			paths: map[string]bool{"/admin": true, "/admin/keys": true, "/administrator": false, "/": false},
+		},
+		{
+			name:  "Prefix with trailing slash",
+			path:  "/admin/",
+			paths: map[string]bool{"/admin": false, "/admin/keys": true, "/administrator": false},
+		},
+		{
+			name:  "Root",
+			path:  "/",
+			paths: map[string]bool{"/": true, "/admin": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permissions := buildClaimPathPermissions(tt.path)
+
+			for path, want := range tt.paths {
+				matched := slices.ContainsFunc(permissions, func(permission *rbacconfigv3.Permission) bool {
+					pattern := permission.GetUrlPath().GetPath()
+					if pattern.GetExact() != "" {
+						return path == pattern.GetExact()
+					}
+
+					return strings.HasPrefix(path, pattern.GetPrefix())
+				})
+
+				assert.Equal(t, want, matched, "path %s", path)
+			}
+		})
+	}
+}
+
+func TestBuildClaimPrincipal(t *testing.T) {
+	providers := map[string]*jwtauth3.JwtProvider{
+		"first":  {PayloadInMetadata: "first"},
+		"second": {PayloadInMetadata: "second"},
+	}
+	claims := []v1alpha1.ClaimMatch{{Claim: "sub", Operator: v1alpha1.ClaimMatchOperatorExists}}
+
+	principalOf := func(payloadKey string) *rbacconfigv3.Principal {
+		return &rbacconfigv3.Principal{
+			Identifier: &rbacconfigv3.Principal_AndIds{AndIds: &rbacconfigv3.Principal_Set{Ids: []*rbacconfigv3.Principal{{
+				Identifier: &rbacconfigv3.Principal_Metadata{Metadata: buildClaimMetadataMatcher(payloadKey, claims[0])},
+			}}}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		rule *v1alpha1.ClaimAuthorizationRule
+		want *rbacconfigv3.Principal
+	}{
+		{
+			name: "All providers",
+			rule: &v1alpha1.ClaimAuthorizationRule{Name: "all", Claims: claims},
+			want: &rbacconfigv3.Principal{
+				Identifier: &rbacconfigv3.Principal_OrIds{OrIds: &rbacconfigv3.Principal_Set{Ids: []*rbacconfigv3.Principal{
+					principalOf("first"),
+					principalOf("second"),
+				}}},
+			},
+		},
+		{
+			name: "Selected provider",
+			rule: &v1alpha1.ClaimAuthorizationRule{Name: "second", Providers: []string{"second", "unknown"}, Claims: claims},
+			want: principalOf("second"),
+		},
+		{
+			name: "Unknown provider",
+			rule: &v1alpha1.ClaimAuthorizationRule{Name: "unknown", Providers: []string{"unknown"}, Claims: claims},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := cmp.Diff(tt.want, buildClaimPrincipal(t.Context(), tt.rule, providers), protocmp.Transform())
+			assert.Empty(t, diff)
+		})
+	}
+}
+
+func TestGatewayExtension_buildClaimAuthorizationFilters(t *testing.T) {
+	providers := map[string]*jwtauth3.JwtProvider{
+		"known": {PayloadInMetadata: "known"},
+	}
+
+	policy := func(action v1alpha1.ClaimAuthorizationAction, provider string) *v1alpha1.ClaimAuthorizationPolicy {
+		return &v1alpha1.ClaimAuthorizationPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: string(action)},
+			Spec: v1alpha1.ClaimAuthorizationPolicySpec{
+				Action: action,
+				Rules: []v1alpha1.ClaimAuthorizationRule{{
+					Name:      "rule",
+					Providers: []string{provider},
+					Claims:    []v1alpha1.ClaimMatch{{Claim: "sub", Operator: v1alpha1.ClaimMatchOperatorExists}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		policies []*v1alpha1.ClaimAuthorizationPolicy
+		want     []string
+	}{
+		{
+			name:     "Allow policy",
+			policies: []*v1alpha1.ClaimAuthorizationPolicy{policy(v1alpha1.ClaimAuthorizationActionAllow, "known")},
+			want:     []string{ClaimAuthzAllowFilterName},
+		},
+		{
+			name:     "Allow policy without known JWT provider",
+			policies: []*v1alpha1.ClaimAuthorizationPolicy{policy(v1alpha1.ClaimAuthorizationActionAllow, "unknown")},
+			want:     []string{},
+		},
+		{
+			name: "Deny policy along with an Allow policy without known JWT provider",
+			policies: []*v1alpha1.ClaimAuthorizationPolicy{
+				policy(v1alpha1.ClaimAuthorizationActionAllow, "unknown"),
+				policy(v1alpha1.ClaimAuthorizationActionDeny, "known"),
+			},
+			want: []string{ClaimAuthzDenyFilterName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+			filters, err := s.buildClaimAuthorizationFilters(t.Context(), tt.policies, providers)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			names := make([]string, 0, len(filters))
+			for _, filter := range filters {
+				names = append(names, filter.GetName())
+			}
+
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestReplaceFiltersAfter(t *testing.T) {
+	rbacFilters := []*hcm.HttpFilter{{Name: ClaimAuthzDenyFilterName}, {Name: ClaimAuthzAllowFilterName}}
+
+	tests := []struct {
+		name     string
+		filters  []*hcm.HttpFilter
+		jwtIndex int
+		want     []string
+	}{
+		{
+			name:     "After the JWT authentication filter",
+			filters:  []*hcm.HttpFilter{{Name: "envoy.filters.http.cors"}, {Name: "envoy.filters.http.jwt_authn"}, {Name: "envoy.filters.http.router"}},
+			jwtIndex: 1,
+			want: []string{
+				"envoy.filters.http.cors", "envoy.filters.http.jwt_authn",
+				ClaimAuthzDenyFilterName, ClaimAuthzAllowFilterName, "envoy.filters.http.router",
+			},
+		},
+		{
+			name:     "Without JWT authentication filter",
+			filters:  []*hcm.HttpFilter{{Name: "envoy.filters.http.router"}},
+			jwtIndex: -1,
+			want:     []string{ClaimAuthzDenyFilterName, ClaimAuthzAllowFilterName, "envoy.filters.http.router"},
+		},
+		{
+			name:     "Replacing the previous filters",
+			filters:  []*hcm.HttpFilter{{Name: "envoy.filters.http.jwt_authn"}, {Name: ClaimAuthzAllowFilterName}, {Name: "envoy.filters.http.router"}},
+			jwtIndex: 0,
+			want: []string{
+				"envoy.filters.http.jwt_authn", ClaimAuthzDenyFilterName, ClaimAuthzAllowFilterName, "envoy.filters.http.router",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := replaceFiltersAfter(tt.filters, tt.jwtIndex, claimAuthzFilterPrefix, rbacFilters)
+
+			names := make([]string, 0, len(got))
+			for _, filter := range got {
+				names = append(names, filter.GetName())
+			}
+
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestGatewayExtension_PostHTTPListenerModify_ClaimAuthorizationPolicy(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	resp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener: &listenerv3.Listener{
+			Name: "kms/kms-public/https",
+			DefaultFilterChain: &listenerv3.FilterChain{
+				Filters: []*listenerv3.Filter{{
+					Name: wellknown.HTTPConnectionManager,
+					ConfigType: &listenerv3.Filter_TypedConfig{
+						TypedConfig: mustNewAny(&hcm.HttpConnectionManager{
+							HttpFilters: []*hcm.HttpFilter{{Name: wellknown.Router}},
+						}),
+					},
+				}},
+			},
+		},
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{
+				{UnstructuredBytes: testdata.ClaimAuthorizationPolicyJSON},
+				{UnstructuredBytes: testdata.ExtensionJSON},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	httpConManager, _, err := findHCM(resp.GetListener().GetDefaultFilterChain())
+	if !assert.NoError(t, err) || !assert.Len(t, httpConManager.GetHttpFilters(), 3) {
+		return
+	}
+
+	filters := httpConManager.GetHttpFilters()
+	assert.Equal(t, "envoy.filters.http.jwt_authn", filters[0].GetName())
+	assert.Equal(t, ClaimAuthzAllowFilterName, filters[1].GetName())
+	assert.Equal(t, wellknown.Router, filters[2].GetName())
+
+	rbac := &rbacv3.RBAC{}
+	if !assert.NoError(t, filters[1].GetTypedConfig().UnmarshalTo(rbac)) {
+		return
+	}
+
+	claim := v1alpha1.ClaimMatch{Claim: "scope", Operator: v1alpha1.ClaimMatchOperatorIn, Values: []string{"kms.admin"}}
+	diff := cmp.Diff(&rbacv3.RBAC{
+		RulesStatPrefix: "openkcm_claims_allow_",
+		Rules: &rbacconfigv3.RBAC{
+			Action: rbacconfigv3.RBAC_ALLOW,
+			Policies: map[string]*rbacconfigv3.Policy{
+				"kms/admins/admin-scope": {
+					Permissions: []*rbacconfigv3.Permission{buildClaimPermission(&v1alpha1.ClaimAuthorizationRule{
+						Paths:   []string{"/admin"},
+						Methods: []string{"POST"},
+					})},
+					Principals: []*rbacconfigv3.Principal{{
+						Identifier: &rbacconfigv3.Principal_AndIds{AndIds: &rbacconfigv3.Principal_Set{Ids: []*rbacconfigv3.Principal{{
+							Identifier: &rbacconfigv3.Principal_Metadata{Metadata: buildClaimMetadataMatcher("Provider", claim)},
+						}}}},
+					}},
+				},
+			},
+		},
+	}, rbac, protocmp.Transform())
+	assert.Empty(t, diff)
+}
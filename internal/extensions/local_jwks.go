@@ -0,0 +1,124 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const (
+	defaultLocalJWKSKey = "jwks"
+
+	refKindConfigMap = "ConfigMap"
+	refKindSecret    = "Secret"
+)
+
+var (
+	ErrNoKubernetesClient = errors.New("no kubernetes client configured")
+	ErrInvalidJWKS        = errors.New("invalid JWKS document")
+)
+
+// resolveLocalJWKS returns the JWKS document of a local JWKS source. Referenced ConfigMaps and Secrets
+// are read from the given namespace.
+func (s *GatewayExtension) resolveLocalJWKS(ctx context.Context, namespace string, local *v1alpha1.LocalJWKS) (string, error) {
+	var jwks string
+
+	switch {
+	case local.Inline != nil:
+		jwks = ptr.Deref(local.Inline, "")
+	case local.ValueRef != nil:
+		doc, err := s.readLocalJWKSValueRef(ctx, namespace, local.ValueRef)
+		if err != nil {
+			return "", err
+		}
+
+		jwks = doc
+	default:
+		return "", fmt.Errorf("%w: neither inline nor valueRef is set", ErrInvalidJWKS)
+	}
+
+	err := validateJWKS(jwks)
+	if err != nil {
+		return "", err
+	}
+
+	return jwks, nil
+}
+
+// readLocalJWKSValueRef reads the JWKS document from the referenced ConfigMap or Secret key.
+func (s *GatewayExtension) readLocalJWKSValueRef(ctx context.Context, namespace string, ref *v1alpha1.LocalJWKSValueRef) (string, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultLocalJWKSKey
+	}
+
+	return s.readValueRef(ctx, ref.Kind, types.NamespacedName{Namespace: namespace, Name: ref.Name}, key)
+}
+
+// readValueRef reads the value of a ConfigMap or Secret key.
+func (s *GatewayExtension) readValueRef(ctx context.Context, kind string, name types.NamespacedName, key string) (string, error) {
+	if s.kubeClient == nil {
+		return "", ErrNoKubernetesClient
+	}
+
+	switch kind {
+	case refKindConfigMap:
+		cm := &corev1.ConfigMap{}
+
+		err := s.kubeClient.Get(ctx, name, cm)
+		if err != nil {
+			return "", fmt.Errorf("could not get ConfigMap %s: %w", name, err)
+		}
+
+		if v, ok := cm.Data[key]; ok {
+			return v, nil
+		}
+
+		if v, ok := cm.BinaryData[key]; ok {
+			return string(v), nil
+		}
+
+		return "", fmt.Errorf("key %s not found in ConfigMap %s", key, name)
+	case refKindSecret:
+		secret := &corev1.Secret{}
+
+		err := s.kubeClient.Get(ctx, name, secret)
+		if err != nil {
+			return "", fmt.Errorf("could not get Secret %s: %w", name, err)
+		}
+
+		if v, ok := secret.Data[key]; ok {
+			return string(v), nil
+		}
+
+		return "", fmt.Errorf("key %s not found in Secret %s", key, name)
+	default:
+		return "", fmt.Errorf("unsupported reference kind %s", kind)
+	}
+}
+
+// validateJWKS checks that the document is a JSON Web Key Set with at least one key.
+func validateJWKS(doc string) error {
+	jwks := struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{}
+
+	err := json.Unmarshal([]byte(doc), &jwks)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJWKS, err)
+	}
+
+	if len(jwks.Keys) == 0 {
+		return fmt.Errorf("%w: no keys", ErrInvalidJWKS)
+	}
+
+	return nil
+}
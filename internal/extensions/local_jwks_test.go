@@ -0,0 +1,152 @@
+package extensions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const testJWKS = `{"keys":[{"kty":"RSA","kid":"key","n":"AQAB","e":"AQAB"}]}`
+
+func TestGatewayExtension_resolveLocalJWKS(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "jwks"},
+			Data:       map[string]string{defaultLocalJWKSKey: testJWKS, "empty": `{"keys":[]}`},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "jwks"},
+			Data:       map[string][]byte{"custom": []byte(testJWKS)},
+		},
+	).Build()
+
+	tests := []struct {
+		name      string
+		namespace string
+		local     *v1alpha1.LocalJWKS
+		noClient  bool
+		want      string
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Inline",
+			local:   &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)},
+			want:    testJWKS,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Inline invalid document",
+			local:   &v1alpha1.LocalJWKS{Inline: ptr.To("<html></html>")},
+			wantErr: assert.Error,
+		},
+		{
+			name:      "ConfigMap default key",
+			namespace: "kms",
+			local: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{
+				Kind: refKindConfigMap,
+				Name: "jwks",
+			}},
+			want:    testJWKS,
+			wantErr: assert.NoError,
+		},
+		{
+			name:      "ConfigMap without keys",
+			namespace: "kms",
+			local: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{
+				Kind: refKindConfigMap,
+				Name: "jwks",
+				Key:  "empty",
+			}},
+			wantErr: assert.Error,
+		},
+		{
+			name:      "Secret custom key",
+			namespace: "kms",
+			local: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{
+				Kind: refKindSecret,
+				Name: "jwks",
+				Key:  "custom",
+			}},
+			want:    testJWKS,
+			wantErr: assert.NoError,
+		},
+		{
+			name:      "Secret missing key",
+			namespace: "kms",
+			local: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{
+				Kind: refKindSecret,
+				Name: "jwks",
+			}},
+			wantErr: assert.Error,
+		},
+		{
+			name:      "Secret in another namespace",
+			namespace: "other",
+			local: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{
+				Kind: refKindSecret,
+				Name: "jwks",
+				Key:  "custom",
+			}},
+			wantErr: assert.Error,
+		},
+		{
+			name:      "No kubernetes client",
+			namespace: "kms",
+			local: &v1alpha1.LocalJWKS{ValueRef: &v1alpha1.LocalJWKSValueRef{
+				Kind: refKindConfigMap,
+				Name: "jwks",
+			}},
+			noClient: true,
+			wantErr:  assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGatewayExtension(&commoncfg.FeatureGates{}, WithKubernetesClient(kubeClient))
+			if tt.noClient {
+				s = NewGatewayExtension(&commoncfg.FeatureGates{})
+			}
+
+			got, err := s.resolveLocalJWKS(t.Context(), tt.namespace, tt.local)
+			if !tt.wantErr(t, err, fmt.Sprintf("resolveLocalJWKS(%v, %v)", tt.namespace, tt.local)) {
+				return
+			}
+
+			assert.Equalf(t, tt.want, got, "resolveLocalJWKS(%v, %v)", tt.namespace, tt.local)
+		})
+	}
+}
+
+func TestGatewayExtension_buildJWTProvider_LocalJWKS(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	jwt, cluster, err := s.buildJWTProvider(t.Context(), &v1alpha1.JWTProvider{
+		Spec: v1alpha1.JWTProviderSpec{
+			Name:      "Local",
+			Issuer:    "https://issuer.example.com",
+			LocalJwks: &v1alpha1.LocalJWKS{Inline: ptr.To(testJWKS)},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, cluster, "no cluster is generated for local JWKS")
+
+	diff := cmp.Diff(&jwtauth3.JwtProvider_LocalJwks{
+		LocalJwks: &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineString{InlineString: testJWKS},
+		},
+	}, jwt.GetJwksSourceSpecifier(), protocmp.Transform())
+	assert.Empty(t, diff)
+}
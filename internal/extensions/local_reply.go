@@ -0,0 +1,194 @@
+package extensions
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const (
+	defaultErrorResponseContentType = "application/json"
+	wwwAuthenticateHeaderName       = "WWW-Authenticate"
+	errorResponseRuntimeKeyPrefix   = "openkcm.error_response."
+
+	// jwtFailedStatusMetadataKey is the key of the JWT verification failures in the dynamic metadata of
+	// the JWT authentication filter.
+	jwtFailedStatusMetadataKey = "failed_status"
+)
+
+var (
+	defaultErrorResponseBodies = map[int32]string{
+		401: `{"error":"unauthorized","reason":"%LOCAL_REPLY_BODY%"}`,
+		403: `{"error":"forbidden","reason":"%LOCAL_REPLY_BODY%"}`,
+	}
+	defaultWWWAuthenticateErrors = map[int32]string{
+		401: "invalid_token",
+		403: "insufficient_scope",
+	}
+)
+
+// errorResponse is an error response and the JWT provider defining it.
+type errorResponse struct {
+	providerName string
+	spec         v1alpha1.JWTErrorResponse
+}
+
+// collectErrorResponses adds the error responses of a JWT provider to the responses by status code. On
+// conflicts, the response of the JWT provider with the lowest name is kept.
+func collectErrorResponses(responses map[int32]errorResponse, providerName string, specs []v1alpha1.JWTErrorResponse) {
+	for _, spec := range specs {
+		current, ok := responses[spec.StatusCode]
+		if ok && current.providerName < providerName {
+			continue
+		}
+
+		responses[spec.StatusCode] = errorResponse{providerName: providerName, spec: spec}
+	}
+}
+
+// withFailedStatusInMetadata returns copies of the JWT providers reporting their verification failures
+// in the dynamic metadata, so the local reply mappers only rewrite the replies of the JWT authentication.
+func withFailedStatusInMetadata(providers map[string]*jwtauth3.JwtProvider) map[string]*jwtauth3.JwtProvider {
+	reporting := make(map[string]*jwtauth3.JwtProvider, len(providers))
+
+	for name, provider := range providers {
+		provider = proto.CloneOf(provider)
+		provider.FailedStatusInMetadata = jwtFailedStatusMetadataKey
+		reporting[name] = provider
+	}
+
+	return reporting
+}
+
+// buildLocalReplyMappers returns the local reply mappers of the error responses, sorted by status code.
+func buildLocalReplyMappers(responses map[int32]errorResponse) []*hcm.ResponseMapper {
+	mappers := make([]*hcm.ResponseMapper, 0, len(responses))
+
+	for _, statusCode := range slices.Sorted(maps.Keys(responses)) {
+		mappers = append(mappers, buildLocalReplyMapper(responses[statusCode].spec))
+	}
+
+	return mappers
+}
+
+// buildLocalReplyMapper returns the local reply mapper rewriting the local replies with the status
+// code of the error response, when the JWT authentication reported a verification failure.
+func buildLocalReplyMapper(spec v1alpha1.JWTErrorResponse) *hcm.ResponseMapper {
+	body := spec.Body
+	if body == "" {
+		body = defaultErrorResponseBodies[spec.StatusCode]
+	}
+
+	contentType := spec.ContentType
+	if contentType == "" {
+		contentType = defaultErrorResponseContentType
+	}
+
+	mapper := &hcm.ResponseMapper{
+		Filter: &accesslogv3.AccessLogFilter{
+			FilterSpecifier: &accesslogv3.AccessLogFilter_AndFilter{
+				AndFilter: &accesslogv3.AndFilter{
+					Filters: []*accesslogv3.AccessLogFilter{
+						{
+							FilterSpecifier: &accesslogv3.AccessLogFilter_StatusCodeFilter{
+								StatusCodeFilter: &accesslogv3.StatusCodeFilter{
+									Comparison: &accesslogv3.ComparisonFilter{
+										Op: accesslogv3.ComparisonFilter_EQ,
+										Value: &corev3.RuntimeUInt32{
+											DefaultValue: uint32(spec.StatusCode), //nolint:gosec
+											RuntimeKey:   fmt.Sprintf("%s%d", errorResponseRuntimeKeyPrefix, spec.StatusCode),
+										},
+									},
+								},
+							},
+						},
+						{
+							FilterSpecifier: &accesslogv3.AccessLogFilter_MetadataFilter{
+								MetadataFilter: &accesslogv3.MetadataFilter{
+									Matcher: &matcherv3.MetadataMatcher{
+										Filter: egv1a1.EnvoyFilterJWTAuthn.String(),
+										Path: []*matcherv3.MetadataMatcher_PathSegment{{
+											Segment: &matcherv3.MetadataMatcher_PathSegment_Key{Key: jwtFailedStatusMetadataKey},
+										}},
+										Value: &matcherv3.ValueMatcher{
+											MatchPattern: &matcherv3.ValueMatcher_PresentMatch{PresentMatch: true},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		BodyFormatOverride: &corev3.SubstitutionFormatString{
+			Format: &corev3.SubstitutionFormatString_TextFormatSource{
+				TextFormatSource: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineString{InlineString: body},
+				},
+			},
+			ContentType: contentType,
+		},
+	}
+
+	if spec.WWWAuthenticate != nil {
+		mapper.HeadersToAdd = []*corev3.HeaderValueOption{{
+			Header: &corev3.HeaderValue{
+				Key:   wwwAuthenticateHeaderName,
+				Value: buildWWWAuthenticate(spec.StatusCode, spec.WWWAuthenticate),
+			},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		}}
+	}
+
+	return mapper
+}
+
+// buildWWWAuthenticate returns the Bearer WWW-Authenticate header value. The percent signs are escaped
+// as the header value is a substitution format string.
+func buildWWWAuthenticate(statusCode int32, spec *v1alpha1.WWWAuthenticate) string {
+	errorCode := spec.Error
+	if errorCode == "" {
+		errorCode = defaultWWWAuthenticateErrors[statusCode]
+	}
+
+	params := make([]string, 0, 2)
+	if spec.Realm != "" {
+		params = append(params, "realm="+quoteAuthParam(spec.Realm))
+	}
+
+	params = append(params, "error="+quoteAuthParam(errorCode))
+
+	return strings.ReplaceAll("Bearer "+strings.Join(params, ", "), "%", "%%")
+}
+
+// quoteAuthParam returns the value as an HTTP quoted string.
+func quoteAuthParam(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// setLocalReplyMappers puts the mappers ahead of the local reply mappers of the HCM, so they take
+// precedence over the mappers configured by Envoy Gateway.
+func setLocalReplyMappers(httpConManager *hcm.HttpConnectionManager, mappers []*hcm.ResponseMapper) {
+	if len(mappers) == 0 {
+		return
+	}
+
+	if httpConManager.GetLocalReplyConfig() == nil {
+		httpConManager.LocalReplyConfig = &hcm.LocalReplyConfig{}
+	}
+
+	httpConManager.LocalReplyConfig.Mappers = append(slices.Clone(mappers), httpConManager.LocalReplyConfig.GetMappers()...)
+}
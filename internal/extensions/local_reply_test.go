@@ -0,0 +1,159 @@
+package extensions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func TestCollectErrorResponses(t *testing.T) {
+	responses := make(map[int32]errorResponse)
+
+	collectErrorResponses(responses, "second", []v1alpha1.JWTErrorResponse{{StatusCode: 401, Body: "second"}, {StatusCode: 403, Body: "second"}})
+	collectErrorResponses(responses, "first", []v1alpha1.JWTErrorResponse{{StatusCode: 401, Body: "first"}})
+	collectErrorResponses(responses, "third", []v1alpha1.JWTErrorResponse{{StatusCode: 403, Body: "third"}})
+
+	assert.Equal(t, "first", responses[401].spec.Body)
+	assert.Equal(t, "second", responses[403].spec.Body)
+}
+
+func TestBuildWWWAuthenticate(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int32
+		spec       *v1alpha1.WWWAuthenticate
+		want       string
+	}{
+		{
+			name:       "Default unauthorized error",
+			statusCode: 401,
+			spec:       &v1alpha1.WWWAuthenticate{Realm: "kms"},
+			want:       `Bearer realm="kms", error="invalid_token"`,
+		},
+		{
+			name:       "Default forbidden error without realm",
+			statusCode: 403,
+			spec:       &v1alpha1.WWWAuthenticate{},
+			want:       `Bearer error="insufficient_scope"`,
+		},
+		{
+			name:       "Escaped realm",
+			statusCode: 401,
+			spec:       &v1alpha1.WWWAuthenticate{Realm: `100% "kms"`, Error: "invalid_request"},
+			want:       `Bearer realm="100%% \"kms\"", error="invalid_request"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, buildWWWAuthenticate(tt.statusCode, tt.spec))
+		})
+	}
+}
+
+// jwtFailureFilter returns the filter of the local replies of the JWT authentication with the status code.
+func jwtFailureFilter(statusCode uint32) *accesslogv3.AccessLogFilter {
+	return &accesslogv3.AccessLogFilter{
+		FilterSpecifier: &accesslogv3.AccessLogFilter_AndFilter{
+			AndFilter: &accesslogv3.AndFilter{
+				Filters: []*accesslogv3.AccessLogFilter{
+					{
+						FilterSpecifier: &accesslogv3.AccessLogFilter_StatusCodeFilter{
+							StatusCodeFilter: &accesslogv3.StatusCodeFilter{
+								Comparison: &accesslogv3.ComparisonFilter{
+									Op:    accesslogv3.ComparisonFilter_EQ,
+									Value: &corev3.RuntimeUInt32{DefaultValue: statusCode, RuntimeKey: fmt.Sprintf("openkcm.error_response.%d", statusCode)},
+								},
+							},
+						},
+					},
+					{
+						FilterSpecifier: &accesslogv3.AccessLogFilter_MetadataFilter{
+							MetadataFilter: &accesslogv3.MetadataFilter{
+								Matcher: &matcherv3.MetadataMatcher{
+									Filter: "envoy.filters.http.jwt_authn",
+									Path: []*matcherv3.MetadataMatcher_PathSegment{{
+										Segment: &matcherv3.MetadataMatcher_PathSegment_Key{Key: "failed_status"},
+									}},
+									Value: &matcherv3.ValueMatcher{MatchPattern: &matcherv3.ValueMatcher_PresentMatch{PresentMatch: true}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWithFailedStatusInMetadata(t *testing.T) {
+	providers := map[string]*jwtauth3.JwtProvider{"Provider": {Issuer: "https://idp.example.com"}}
+
+	got := withFailedStatusInMetadata(providers)
+
+	diff := cmp.Diff(map[string]*jwtauth3.JwtProvider{
+		"Provider": {Issuer: "https://idp.example.com", FailedStatusInMetadata: "failed_status"},
+	}, got, protocmp.Transform())
+	assert.Empty(t, diff)
+	assert.Empty(t, providers["Provider"].GetFailedStatusInMetadata())
+}
+
+func TestSetLocalReplyMappers(t *testing.T) {
+	existing := &hcm.ResponseMapper{Body: &corev3.DataSource{Specifier: &corev3.DataSource_InlineString{InlineString: "gateway"}}}
+	httpConManager := &hcm.HttpConnectionManager{
+		LocalReplyConfig: &hcm.LocalReplyConfig{Mappers: []*hcm.ResponseMapper{existing}},
+	}
+
+	setLocalReplyMappers(httpConManager, buildLocalReplyMappers(map[int32]errorResponse{
+		403: {providerName: "Provider", spec: v1alpha1.JWTErrorResponse{StatusCode: 403}},
+		401: {providerName: "Provider", spec: v1alpha1.JWTErrorResponse{
+			StatusCode:      401,
+			Body:            `{"code":"%RESPONSE_CODE_DETAILS%"}`,
+			ContentType:     "application/problem+json",
+			WWWAuthenticate: &v1alpha1.WWWAuthenticate{Realm: "kms"},
+		}},
+	}))
+
+	want := []*hcm.ResponseMapper{
+		{
+			Filter: jwtFailureFilter(401),
+			BodyFormatOverride: &corev3.SubstitutionFormatString{
+				Format: &corev3.SubstitutionFormatString_TextFormatSource{
+					TextFormatSource: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{InlineString: `{"code":"%RESPONSE_CODE_DETAILS%"}`},
+					},
+				},
+				ContentType: "application/problem+json",
+			},
+			HeadersToAdd: []*corev3.HeaderValueOption{{
+				Header:       &corev3.HeaderValue{Key: "WWW-Authenticate", Value: `Bearer realm="kms", error="invalid_token"`},
+				AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+			}},
+		},
+		{
+			Filter: jwtFailureFilter(403),
+			BodyFormatOverride: &corev3.SubstitutionFormatString{
+				Format: &corev3.SubstitutionFormatString_TextFormatSource{
+					TextFormatSource: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineString{InlineString: `{"error":"forbidden","reason":"%LOCAL_REPLY_BODY%"}`},
+					},
+				},
+				ContentType: "application/json",
+			},
+		},
+		existing,
+	}
+
+	diff := cmp.Diff(want, httpConManager.GetLocalReplyConfig().GetMappers(), protocmp.Transform())
+	assert.Empty(t, diff)
+}
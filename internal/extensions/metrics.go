@@ -0,0 +1,152 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	metricsNamespace = "gateway_extension"
+
+	hookPostRouteModify        = "PostRouteModify"
+	hookPostClusterModify      = "PostClusterModify"
+	hookPostHTTPListenerModify = "PostHTTPListenerModify"
+	hookPostTranslateModify    = "PostTranslateModify"
+	hookPostVirtualHostModify  = "PostVirtualHostModify"
+
+	reasonInternal                   = "Internal"
+	reasonMalformedResource          = "MalformedResource"
+	reasonUnknownResourceKind        = "UnknownResourceKind"
+	reasonUnsupportedResourceVersion = "UnsupportedResourceVersion"
+	reasonInvalidResource            = "InvalidResource"
+)
+
+// Metrics are the Prometheus metrics of the extension hooks. A nil Metrics records nothing.
+type Metrics struct {
+	hookCalls             *prometheus.CounterVec
+	hookDuration          *prometheus.HistogramVec
+	errors                *prometheus.CounterVec
+	jwtProviders          prometheus.Gauge
+	jwksClusters          prometheus.Gauge
+	discoveryCacheEntries prometheus.Gauge
+}
+
+// NewMetrics returns the metrics of the extension hooks, registered with the registerer.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		hookCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "hook_calls_total",
+			Help:      "Number of calls of the extension hooks.",
+		}, []string{"hook"}),
+		hookDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "hook_duration_seconds",
+			Help:      "Latency of the extension hooks.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"hook"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "errors_total",
+			Help:      "Number of errors of the extension hooks, including the skipped resources, by reason.",
+		}, []string{"hook", "reason"}),
+		jwtProviders: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "jwt_providers",
+			Help:      "Number of JWTProviders configured on listeners by the last translation.",
+		}),
+		jwksClusters: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "jwks_clusters",
+			Help:      "Number of clusters generated by the last translation.",
+		}),
+		discoveryCacheEntries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "discovery_cache_entries",
+			Help:      "Number of issuers in the OpenID configuration discovery cache.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.hookCalls, m.hookDuration, m.errors, m.jwtProviders, m.jwksClusters, m.discoveryCacheEntries,
+	} {
+		err := reg.Register(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// observeHook records the call of the hook, its latency and its error, if any.
+func (m *Metrics) observeHook(hook string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.hookCalls.WithLabelValues(hook).Inc()
+	m.hookDuration.WithLabelValues(hook).Observe(duration.Seconds())
+
+	if err != nil {
+		m.errors.WithLabelValues(hook, errorReason(err)).Inc()
+	}
+}
+
+// recordError records an error of the hook called with the context.
+func (m *Metrics) recordError(ctx context.Context, reason string) {
+	if m == nil {
+		return
+	}
+
+	m.errors.WithLabelValues(hookFromContext(ctx), reason).Inc()
+}
+
+// setTranslation records the JWTProviders, the clusters and the discovery cache entries of a translation.
+func (m *Metrics) setTranslation(jwtProviders, jwksClusters, discoveryCacheEntries int) {
+	if m == nil {
+		return
+	}
+
+	m.jwtProviders.Set(float64(jwtProviders))
+	m.jwksClusters.Set(float64(jwksClusters))
+	m.discoveryCacheEntries.Set(float64(discoveryCacheEntries))
+}
+
+// errorReason returns the reason of the error recorded in the metrics.
+func errorReason(err error) string {
+	discoveryErr := &DiscoveryError{}
+	if errors.As(err, &discoveryErr) {
+		return string(discoveryErr.Reason)
+	}
+
+	return reasonInternal
+}
+
+type hookContextKey struct{}
+
+// hookFromContext returns the hook called with the context.
+func hookFromContext(ctx context.Context) string {
+	hook, _ := ctx.Value(hookContextKey{}).(string)
+	return hook
+}
+
+// observeHook calls the hook and records it in the metrics. The hook is set in the context, and the
+// xDS resource of the request is added to the span of the gRPC call.
+func observeHook[Req, Resp any](ctx context.Context, m *Metrics, hook string, req Req,
+	fn func(context.Context, Req) (Resp, error),
+) (Resp, error) {
+	ctx = context.WithValue(ctx, hookContextKey{}, hook)
+	trace.SpanFromContext(ctx).SetAttributes(hookAttributes(req)...)
+
+	start := time.Now()
+
+	resp, err := fn(ctx, req)
+	m.observeHook(hook, time.Since(start), err)
+
+	return resp, err
+}
@@ -0,0 +1,91 @@
+package extensions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func TestNewMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	_, err := NewMetrics(reg)
+	assert.NoError(t, err)
+
+	// The metrics cannot be registered twice with the same registry
+	_, err = NewMetrics(reg)
+	assert.Error(t, err)
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.observeHook(hookPostRouteModify, 0, assert.AnError)
+		m.recordError(t.Context(), reasonInternal)
+		m.setTranslation(1, 1, 1)
+	})
+}
+
+func TestErrorReason(t *testing.T) {
+	assert.Equal(t, "IssuerMismatch", errorReason(newDiscoveryError("https://idp.example.com", DiscoveryIssuerMismatch, "mismatch")))
+	assert.Equal(t, reasonInternal, errorReason(assert.AnError))
+}
+
+func TestGatewayExtension_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	metrics, err := NewMetrics(reg)
+	assert.NoError(t, err)
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{}, WithMetrics(metrics))
+
+	resources := append(testJWTProviderResources(2),
+		&extension.ExtensionResource{UnstructuredBytes: mustMarshalResource(testJWTProvider("undiscovered", v1alpha1.JWTProviderSpec{
+			Name: "Undiscovered", Issuer: "://idp.example.com",
+		}))},
+		testBackendResource("test.openkcm.io/v1", 1),
+	)
+
+	for _, name := range []string{"kms/gateway/http", "kms/gateway/https"} {
+		_, err = s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+			Listener:            testHTTPListener(name),
+			PostListenerContext: &extension.PostHTTPListenerExtensionContext{ExtensionResources: resources},
+		})
+		assert.NoError(t, err)
+	}
+
+	_, err = s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{
+		Clusters: []*clusterv3.Cluster{{Name: "httproute/kms/api/rule/0"}},
+	})
+	assert.NoError(t, err)
+
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.hookCalls.WithLabelValues(hookPostHTTPListenerModify)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.hookCalls.WithLabelValues(hookPostTranslateModify)), 0)
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.hookDuration))
+
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.errors.WithLabelValues(hookPostHTTPListenerModify, reasonUnknownResourceKind)), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.errors.WithLabelValues(hookPostHTTPListenerModify, string(DiscoveryInvalidIssuer))), 0)
+
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.jwtProviders), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.jwksClusters), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.discoveryCacheEntries), 0)
+
+	// The errors returned by the hooks are recorded
+	_, err = observeHook(t.Context(), metrics, hookPostRouteModify, &extension.PostRouteModifyRequest{},
+		func(context.Context, *extension.PostRouteModifyRequest) (*extension.PostRouteModifyResponse, error) {
+			return nil, assert.AnError
+		})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.hookCalls.WithLabelValues(hookPostRouteModify)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.errors.WithLabelValues(hookPostRouteModify, reasonInternal)), 0)
+}
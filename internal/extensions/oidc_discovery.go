@@ -0,0 +1,326 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+
+	slogctx "github.com/veqryn/slog-context"
+)
+
+const (
+	DefaultDiscoveryTimeout    = 5 * time.Second
+	DefaultDiscoveryTTL        = 10 * time.Minute
+	DefaultDiscoveryFailureTTL = 30 * time.Second
+
+	// maxDiscoveryDocumentSize bounds the size of the well known OpenID configuration documents
+	maxDiscoveryDocumentSize = 1 << 20
+)
+
+var (
+	ErrDiscoveryFailed = errors.New("OpenID configuration discovery failed")
+)
+
+// DiscoveryFailureReason is the reason of an OpenID configuration discovery failure.
+type DiscoveryFailureReason string
+
+const (
+	DiscoveryInvalidIssuer         DiscoveryFailureReason = "InvalidIssuer"
+	DiscoveryRequestFailed         DiscoveryFailureReason = "RequestFailed"
+	DiscoveryUnexpectedStatus      DiscoveryFailureReason = "UnexpectedStatus"
+	DiscoveryUnexpectedContentType DiscoveryFailureReason = "UnexpectedContentType"
+	DiscoveryInvalidDocument       DiscoveryFailureReason = "InvalidDocument"
+	DiscoveryIssuerMismatch        DiscoveryFailureReason = "IssuerMismatch"
+	DiscoveryInvalidJWKSURI        DiscoveryFailureReason = "InvalidJWKSURI"
+)
+
+// DiscoveryError is the OpenID configuration discovery failure of an issuer. It matches ErrDiscoveryFailed.
+type DiscoveryError struct {
+	Issuer string
+	Reason DiscoveryFailureReason
+	Err    error
+}
+
+func newDiscoveryError(issuer string, reason DiscoveryFailureReason, format string, args ...any) *DiscoveryError {
+	return &DiscoveryError{Issuer: issuer, Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *DiscoveryError) Error() string {
+	return fmt.Sprintf("%s for issuer %q: %s: %v", ErrDiscoveryFailed, e.Issuer, e.Reason, e.Err)
+}
+
+func (e *DiscoveryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DiscoveryError) Is(target error) bool {
+	return target == ErrDiscoveryFailed
+}
+
+type wellKnownOpenIDConfiguration struct {
+	Issuer string `json:"issuer"`
+	JURIS  string `json:"jwks_uri"`
+}
+
+// OIDCDiscovery discovers the JWKS URI of the issuers through their well known OpenID configuration.
+// The discovered URIs are cached by issuer. Once expired, the last known good URI keeps being served
+// while it is refreshed in the background, and is kept if the refresh fails. The issuers not read
+// within a TTL are evicted.
+type OIDCDiscovery struct {
+	client     *http.Client
+	ttl        time.Duration
+	failureTTL time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*discoveryEntry
+	refreshes sync.WaitGroup
+	// fetches deduplicates the concurrent synchronous discoveries of an issuer.
+	fetches singleflight.Group
+}
+
+// discoveryEntry is the cached discovery result of an issuer.
+type discoveryEntry struct {
+	// jwksURI is the last known good JWKS URI, empty if the discovery never succeeded.
+	jwksURI string
+	// err is the last discovery failure, reported while there is no known good JWKS URI.
+	err        error
+	expiresAt  time.Time
+	lastRead   time.Time
+	refreshing bool
+}
+
+// NewOIDCDiscovery returns a discovery bounding each request by the timeout, caching the discovered
+// JWKS URIs for the ttl and the failures for the failureTTL. Non positive values fall back to defaults.
+func NewOIDCDiscovery(timeout, ttl, failureTTL time.Duration) *OIDCDiscovery {
+	if timeout <= 0 {
+		timeout = DefaultDiscoveryTimeout
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultDiscoveryTTL
+	}
+
+	if failureTTL <= 0 {
+		failureTTL = DefaultDiscoveryFailureTTL
+	}
+
+	return &OIDCDiscovery{
+		client:     &http.Client{Timeout: timeout},
+		ttl:        ttl,
+		failureTTL: failureTTL,
+		now:        time.Now,
+		entries:    make(map[string]*discoveryEntry),
+	}
+}
+
+// JWKSURI returns the JWKS URI of the issuer. Only the first discovery of an issuer, or the ones
+// following failures without known good JWKS URI, are done synchronously.
+func (d *OIDCDiscovery) JWKSURI(ctx context.Context, issuer string) (string, error) {
+	ctx, span := startSpan(ctx, "OIDCDiscovery.JWKSURI", attribute.String("oidc.issuer", issuer))
+
+	jwksURI, err := d.jwksURI(ctx, issuer)
+	endSpan(span, err)
+
+	return jwksURI, err
+}
+
+// jwksURI implements JWKSURI.
+func (d *OIDCDiscovery) jwksURI(ctx context.Context, issuer string) (string, error) {
+	d.mu.Lock()
+
+	d.evictUnread()
+
+	entry, ok := d.entries[issuer]
+	if ok {
+		entry.lastRead = d.now()
+	}
+
+	switch {
+	case ok && d.now().Before(entry.expiresAt):
+		jwksURI, err := entry.jwksURI, entry.err
+		d.mu.Unlock()
+
+		if jwksURI == "" {
+			return "", err
+		}
+
+		return jwksURI, nil
+	case ok && entry.jwksURI != "":
+		if !entry.refreshing {
+			entry.refreshing = true
+
+			d.refreshes.Add(1)
+
+			go d.refresh(context.WithoutCancel(ctx), issuer)
+		}
+
+		jwksURI := entry.jwksURI
+		d.mu.Unlock()
+
+		return jwksURI, nil
+	}
+
+	d.mu.Unlock()
+
+	// The discovery is shared by the concurrent callers, it must not be canceled with the first one
+	v, err, _ := d.fetches.Do(issuer, func() (any, error) {
+		jwksURI, err := d.fetch(context.WithoutCancel(ctx), issuer)
+		d.store(ctx, issuer, jwksURI, err)
+
+		return jwksURI, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	jwksURI, _ := v.(string)
+
+	return jwksURI, nil
+}
+
+// Len returns the number of issuers in the cache, once the ones not read within a TTL are evicted.
+func (d *OIDCDiscovery) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.evictUnread()
+
+	return len(d.entries)
+}
+
+// evictUnread removes the issuers not read within a TTL, except the ones being refreshed. The caller
+// must hold the lock.
+func (d *OIDCDiscovery) evictUnread() {
+	now := d.now()
+
+	for issuer, entry := range d.entries {
+		if !entry.refreshing && now.Sub(entry.lastRead) > d.ttl {
+			delete(d.entries, issuer)
+		}
+	}
+}
+
+// refresh discovers the JWKS URI of the issuer in the background.
+func (d *OIDCDiscovery) refresh(ctx context.Context, issuer string) {
+	defer d.refreshes.Done()
+
+	jwksURI, err := d.fetch(ctx, issuer)
+	d.store(ctx, issuer, jwksURI, err)
+}
+
+// store caches the discovery result of the issuer. On failure, the last known good JWKS URI is kept.
+func (d *OIDCDiscovery) store(ctx context.Context, issuer string, jwksURI string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[issuer]
+	if !ok {
+		entry = &discoveryEntry{lastRead: d.now()}
+		d.entries[issuer] = entry
+	}
+
+	entry.refreshing = false
+
+	if err != nil {
+		if entry.jwksURI != "" {
+			slogctx.Warn(ctx, "Failed to refresh the OpenID configuration; Using the last known good JWKS URI",
+				"issuer", issuer, "jwksUri", entry.jwksURI, "error", err)
+		}
+
+		entry.err = err
+		entry.expiresAt = d.now().Add(d.failureTTL)
+
+		return
+	}
+
+	entry.jwksURI = jwksURI
+	entry.err = nil
+	entry.expiresAt = d.now().Add(d.ttl)
+}
+
+// fetch gets the JWKS URI from the well known OpenID configuration of the issuer. The configuration
+// must be a JSON document for the same issuer, with an https JWKS URI.
+func (d *OIDCDiscovery) fetch(ctx context.Context, issuer string) (string, error) {
+	ctx, span := startSpan(ctx, "OIDCDiscovery.fetch", attribute.String("oidc.issuer", issuer))
+	defer span.End()
+
+	wkoc := wellKnownOpenIDConfiguration{}
+
+	parsedURL, err := url.Parse(issuer)
+	if err != nil {
+		return "", newDiscoveryError(issuer, DiscoveryInvalidIssuer, "%w", err)
+	}
+
+	wkocURI := parsedURL.JoinPath(".well-known/openid-configuration")
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, wkocURI.String(), nil)
+	if err != nil {
+		return "", newDiscoveryError(issuer, DiscoveryInvalidIssuer, "could not build request to get well known OpenID configuration: %w", err)
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return "", newDiscoveryError(issuer, DiscoveryRequestFailed, "could not get well known OpenID configuration: %w", err)
+	}
+
+	defer func() {
+		err := response.Body.Close()
+		if err != nil {
+			slogctx.Error(ctx, "could not close response body", "error", err)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return "", newDiscoveryError(issuer, DiscoveryUnexpectedStatus, "unexpected status code %d", response.StatusCode)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	if !isJSONContentType(contentType) {
+		return "", newDiscoveryError(issuer, DiscoveryUnexpectedContentType, "unexpected content type %q", contentType)
+	}
+
+	// decode the well known OpenID configuration
+	err = json.NewDecoder(io.LimitReader(response.Body, maxDiscoveryDocumentSize)).Decode(&wkoc)
+	if err != nil {
+		return "", newDiscoveryError(issuer, DiscoveryInvalidDocument, "could not decode well known OpenID configuration: %w", err)
+	}
+
+	if wkoc.Issuer != issuer {
+		return "", newDiscoveryError(issuer, DiscoveryIssuerMismatch, "discovered issuer %q", wkoc.Issuer)
+	}
+
+	jwksURI, err := url.Parse(wkoc.JURIS)
+	if err != nil {
+		return "", newDiscoveryError(issuer, DiscoveryInvalidJWKSURI, "%w", err)
+	}
+
+	if jwksURI.Scheme != "https" || jwksURI.Host == "" {
+		return "", newDiscoveryError(issuer, DiscoveryInvalidJWKSURI, "jwks_uri %q is not an https URL", wkoc.JURIS)
+	}
+
+	return wkoc.JURIS, nil
+}
+
+// isJSONContentType reports if the content type is application/json or a structured +json media type.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,324 @@
+package extensions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWKSHost = "https://keys.example.com"
+
+// discoveryServer serves the well known OpenID configuration while healthy, and counts the requests.
+type discoveryServer struct {
+	*httptest.Server
+
+	requests atomic.Int32
+	healthy  atomic.Bool
+	jwksPath atomic.Value
+	// blocked holds the requests until it is closed, when set.
+	blocked atomic.Pointer[chan struct{}]
+}
+
+func newDiscoveryServer(t *testing.T) *discoveryServer {
+	t.Helper()
+
+	ds := &discoveryServer{}
+	ds.healthy.Store(true)
+	ds.jwksPath.Store("/keys")
+
+	ds.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ds.requests.Add(1)
+
+		if blocked := ds.blocked.Load(); blocked != nil {
+			<-*blocked
+		}
+
+		if !ds.healthy.Load() || r.URL.Path != "/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"issuer":"` + ds.URL + `","jwks_uri":"` + testJWKSHost + ds.jwksPath.Load().(string) + `"}`))
+	}))
+	t.Cleanup(ds.Close)
+
+	return ds
+}
+
+// fakeClock is a settable clock for the discovery cache.
+type fakeClock struct {
+	now atomic.Pointer[time.Time]
+}
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{}
+	c.Set(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	return c
+}
+
+func (c *fakeClock) Now() time.Time { return *c.now.Load() }
+
+func (c *fakeClock) Set(t time.Time) { c.now.Store(&t) }
+
+func (c *fakeClock) Advance(d time.Duration) { c.Set(c.Now().Add(d)) }
+
+func TestOIDCDiscovery_JWKSURI_Cached(t *testing.T) {
+	server := newDiscoveryServer(t)
+	clock := newFakeClock()
+
+	d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+	d.now = clock.Now
+
+	jwksURI, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/keys", jwksURI)
+
+	clock.Advance(30 * time.Second)
+
+	jwksURI, err = d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/keys", jwksURI)
+	assert.Equal(t, int32(1), server.requests.Load())
+}
+
+func TestOIDCDiscovery_JWKSURI_BackgroundRefresh(t *testing.T) {
+	server := newDiscoveryServer(t)
+	clock := newFakeClock()
+
+	d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+	d.now = clock.Now
+
+	_, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+
+	server.jwksPath.Store("/rotated")
+	clock.Advance(50 * time.Second)
+
+	_, err = d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+
+	clock.Advance(20 * time.Second)
+
+	// The stale JWKS URI is served while it is refreshed
+	jwksURI, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/keys", jwksURI)
+
+	d.refreshes.Wait()
+
+	jwksURI, err = d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/rotated", jwksURI)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+func TestOIDCDiscovery_JWKSURI_ConcurrentDiscovery(t *testing.T) {
+	server := newDiscoveryServer(t)
+
+	blocked := make(chan struct{})
+	server.blocked.Store(&blocked)
+
+	d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+
+	var wg sync.WaitGroup
+
+	jwksURIs := make([]string, 8)
+	for i := range jwksURIs {
+		wg.Go(func() {
+			jwksURI, err := d.JWKSURI(t.Context(), server.URL)
+			assert.NoError(t, err)
+
+			jwksURIs[i] = jwksURI
+		})
+	}
+
+	// The callers joining the pending discovery do not send requests
+	assert.Eventually(t, func() bool { return server.requests.Load() > 0 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(blocked)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	for _, jwksURI := range jwksURIs {
+		assert.Equal(t, testJWKSHost+"/keys", jwksURI)
+	}
+}
+
+func TestOIDCDiscovery_JWKSURI_Eviction(t *testing.T) {
+	server := newDiscoveryServer(t)
+	clock := newFakeClock()
+
+	d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+	d.now = clock.Now
+
+	_, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, d.Len())
+
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, 1, d.Len())
+
+	// The issuer not read within a TTL is evicted, and discovered again synchronously
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, 0, d.Len())
+
+	server.jwksPath.Store("/rotated")
+
+	jwksURI, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/rotated", jwksURI)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+func TestOIDCDiscovery_JWKSURI_LastKnownGood(t *testing.T) {
+	server := newDiscoveryServer(t)
+	clock := newFakeClock()
+
+	d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+	d.now = clock.Now
+
+	_, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+
+	server.healthy.Store(false)
+	clock.Advance(time.Minute)
+
+	_, err = d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+
+	d.refreshes.Wait()
+
+	// The failed refresh keeps the last known good JWKS URI until the failure expires
+	jwksURI, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/keys", jwksURI)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+func TestOIDCDiscovery_JWKSURI_Failure(t *testing.T) {
+	server := newDiscoveryServer(t)
+	server.healthy.Store(false)
+
+	clock := newFakeClock()
+
+	d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+	d.now = clock.Now
+
+	_, err := d.JWKSURI(t.Context(), server.URL)
+	assert.ErrorIs(t, err, ErrDiscoveryFailed)
+
+	// The failure is cached for the failure TTL
+	_, err = d.JWKSURI(t.Context(), server.URL)
+	assert.ErrorIs(t, err, ErrDiscoveryFailed)
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	server.healthy.Store(true)
+	clock.Advance(20 * time.Second)
+
+	jwksURI, err := d.JWKSURI(t.Context(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, testJWKSHost+"/keys", jwksURI)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+func TestOIDCDiscovery_fetch_Validation(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		// document is formatted with the issuer
+		document   string
+		wantReason DiscoveryFailureReason
+	}{
+		{
+			name:        "Valid document",
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			document:    `{"issuer":"%s","jwks_uri":"https://keys.example.com/keys"}`,
+		},
+		{
+			name:        "Error status",
+			status:      http.StatusNotFound,
+			contentType: "application/json",
+			document:    `{"issuer":"%s","jwks_uri":"https://keys.example.com/keys"}`,
+			wantReason:  DiscoveryUnexpectedStatus,
+		},
+		{
+			name:        "HTML error page",
+			status:      http.StatusOK,
+			contentType: "text/html",
+			document:    `<html>%s</html>`,
+			wantReason:  DiscoveryUnexpectedContentType,
+		},
+		{
+			name:        "Invalid JSON",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			document:    `{"issuer":"%s"`,
+			wantReason:  DiscoveryInvalidDocument,
+		},
+		{
+			name:        "Mismatched issuer",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			document:    `{"issuer":"%s/other","jwks_uri":"https://keys.example.com/keys"}`,
+			wantReason:  DiscoveryIssuerMismatch,
+		},
+		{
+			name:        "Missing JWKS URI",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			document:    `{"issuer":"%s"}`,
+			wantReason:  DiscoveryInvalidJWKSURI,
+		},
+		{
+			name:        "Plain HTTP JWKS URI",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			document:    `{"issuer":"%s","jwks_uri":"http://keys.example.com/keys"}`,
+			wantReason:  DiscoveryInvalidJWKSURI,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var issuer string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				_, _ = fmt.Fprintf(w, tt.document, issuer)
+			}))
+			t.Cleanup(server.Close)
+
+			issuer = server.URL
+
+			d := NewOIDCDiscovery(time.Second, time.Minute, 10*time.Second)
+
+			jwksURI, err := d.fetch(t.Context(), issuer)
+			if tt.wantReason == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, "https://keys.example.com/keys", jwksURI)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrDiscoveryFailed)
+
+			discoveryErr := &DiscoveryError{}
+			if assert.True(t, errors.As(err, &discoveryErr)) {
+				assert.Equal(t, issuer, discoveryErr.Issuer)
+				assert.Equal(t, tt.wantReason, discoveryErr.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package extensions
+
+import (
+	"net/url"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+)
+
+// Option configures a GatewayExtension.
+type Option func(*GatewayExtension)
+
+// WithKubernetesClient sets the client used to read the Kubernetes resources referenced
+// by the extension resources.
+func WithKubernetesClient(c client.Client) Option {
+	return func(s *GatewayExtension) {
+		s.kubeClient = c
+	}
+}
+
+// WithOIDCDiscovery sets the discovery used to get the JWKS URI of the JWT providers only
+// defining an issuer.
+func WithOIDCDiscovery(d *OIDCDiscovery) Option {
+	return func(s *GatewayExtension) {
+		s.discovery = d
+	}
+}
+
+// WithDNSLookupFamily sets the DNS lookup family of the JWKS clusters not defining their own.
+func WithDNSLookupFamily(family clusterv3.Cluster_DnsLookupFamily) Option {
+	return func(s *GatewayExtension) {
+		s.dnsLookupFamily = family
+	}
+}
+
+// WithJWKSProxy sets the HTTP proxy the JWKS of the JWT providers not defining their own are fetched
+// through. The URI is parsed with ParseProxyURI.
+func WithJWKSProxy(proxy *url.URL) Option {
+	return func(s *GatewayExtension) {
+		s.jwksProxy = proxy
+	}
+}
+
+// WithJWTCache sets the cache of the verified JWTs of the JWT providers not defining their own.
+// A size of zero uses Envoy's default size.
+func WithJWTCache(enabled bool, size uint32) Option {
+	return func(s *GatewayExtension) {
+		s.jwtCacheEnabled = enabled
+		s.jwtCacheSize = size
+	}
+}
+
+// WithResourceHandlers registers handlers of extension resources. The handlers of new kinds are applied
+// after the handlers of the resources served by the extension; the others replace them.
+func WithResourceHandlers(handlers ...ResourceHandler) Option {
+	return func(s *GatewayExtension) {
+		for _, h := range handlers {
+			s.resources.Register(h)
+		}
+	}
+}
+
+// WithMetrics sets the metrics the hooks are recorded in.
+func WithMetrics(m *Metrics) Option {
+	return func(s *GatewayExtension) {
+		s.metrics = m
+		s.resources.metrics = m
+	}
+}
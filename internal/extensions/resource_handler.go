@@ -0,0 +1,221 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pb "github.com/envoyproxy/gateway/proto/extension"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	slogctx "github.com/veqryn/slog-context"
+
+	"github.com/openkcm/gateway-extension/api"
+)
+
+var (
+	ErrUnknownResourceKind        = errors.New("unknown extension resource kind")
+	ErrUnsupportedResourceVersion = errors.New("unsupported extension resource version")
+)
+
+// Resources are the decoded extension resources of a hook, by kind.
+type Resources map[string][]any
+
+// ResourceHandler decodes and validates the extension resources of a kind. A handler applies the
+// resources by implementing ListenerResourceHandler, RouteResourceHandler or ClusterResourceHandler.
+type ResourceHandler interface {
+	// Kind returns the kind of the handled resources.
+	Kind() string
+	// Decode unmarshals a resource of the given API version. It returns ErrUnsupportedResourceVersion
+	// if the version is not served.
+	Decode(apiVersion string, data []byte) (any, error)
+	// Validate checks a decoded resource. Invalid resources are skipped.
+	Validate(resource any) error
+}
+
+// ListenerResourceHandler applies the extension resources to the listeners in PostHTTPListenerModify.
+type ListenerResourceHandler interface {
+	ResourceHandler
+	ModifyListener(ctx context.Context, listener *listenerv3.Listener, resources Resources) error
+}
+
+// RouteResourceHandler applies the extension resources referenced by the routes in PostRouteModify.
+type RouteResourceHandler interface {
+	ResourceHandler
+	ModifyRoute(ctx context.Context, route *routev3.Route, resources Resources) error
+}
+
+// ClusterResourceHandler applies the custom backend resources to their clusters in PostClusterModify.
+type ClusterResourceHandler interface {
+	ResourceHandler
+	ModifyCluster(ctx context.Context, cluster *clusterv3.Cluster, resources Resources) error
+}
+
+// ResourceRegistry holds the resource handlers. The handlers are applied in registration order.
+type ResourceRegistry struct {
+	handlers []ResourceHandler
+	metrics  *Metrics
+}
+
+// NewResourceRegistry returns a registry holding the given handlers.
+func NewResourceRegistry(handlers ...ResourceHandler) *ResourceRegistry {
+	r := &ResourceRegistry{}
+
+	for _, h := range handlers {
+		r.Register(h)
+	}
+
+	return r
+}
+
+// Register adds the handler to the registry. It replaces the handler of the same kind in place,
+// keeping its position in the application order.
+func (r *ResourceRegistry) Register(h ResourceHandler) {
+	for i, registered := range r.handlers {
+		if registered.Kind() == h.Kind() {
+			r.handlers[i] = h
+			return
+		}
+	}
+
+	r.handlers = append(r.handlers, h)
+}
+
+// Handler returns the handler of the kind.
+func (r *ResourceRegistry) Handler(kind string) (ResourceHandler, bool) {
+	for _, h := range r.handlers {
+		if h.Kind() == kind {
+			return h, true
+		}
+	}
+
+	return nil, false
+}
+
+// Decode unmarshals and validates the extension resources, and groups them by kind. The resources of
+// unknown kinds or versions and the invalid ones are reported and skipped.
+func (r *ResourceRegistry) Decode(ctx context.Context, exts []*pb.ExtensionResource) Resources {
+	resources := make(Resources)
+
+	for _, ext := range exts {
+		var generic api.Generic
+
+		err := json.Unmarshal(ext.GetUnstructuredBytes(), &generic)
+		if err != nil {
+			slogctx.Error(ctx, "Failed to unmarshal the extension", "error", err)
+			r.metrics.recordError(ctx, reasonMalformedResource)
+
+			continue
+		}
+
+		attrs := []any{
+			"kind", generic.Kind, "apiVersion", generic.APIVersion,
+			"name", generic.GetName(), "namespace", generic.GetNamespace(),
+		}
+
+		h, ok := r.Handler(generic.Kind)
+		if !ok {
+			slogctx.Error(ctx, "Skipping the extension resource", append(attrs, "error", ErrUnknownResourceKind)...)
+			r.metrics.recordError(ctx, reasonUnknownResourceKind)
+
+			continue
+		}
+
+		resource, err := h.Decode(generic.APIVersion, ext.GetUnstructuredBytes())
+		if err != nil {
+			slogctx.Error(ctx, "Skipping the extension resource", append(attrs, "error", err)...)
+
+			reason := reasonMalformedResource
+			if errors.Is(err, ErrUnsupportedResourceVersion) {
+				reason = reasonUnsupportedResourceVersion
+			}
+
+			r.metrics.recordError(ctx, reason)
+
+			continue
+		}
+
+		err = h.Validate(resource)
+		if err != nil {
+			slogctx.Error(ctx, "Skipping the invalid extension resource", append(attrs, "error", err)...)
+			r.metrics.recordError(ctx, reasonInvalidResource)
+
+			continue
+		}
+
+		slogctx.Info(ctx, "Found a resource", "yaml", generic)
+
+		resources[generic.Kind] = append(resources[generic.Kind], resource)
+	}
+
+	return resources
+}
+
+// ModifyListener applies the resources to the listener with the listener handlers.
+func (r *ResourceRegistry) ModifyListener(ctx context.Context, listener *listenerv3.Listener, resources Resources) error {
+	for _, h := range r.handlers {
+		lh, ok := h.(ListenerResourceHandler)
+		if !ok {
+			continue
+		}
+
+		err := lh.ModifyListener(ctx, listener, resources)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ModifyRoute applies the resources to the route with the route handlers.
+func (r *ResourceRegistry) ModifyRoute(ctx context.Context, route *routev3.Route, resources Resources) error {
+	for _, h := range r.handlers {
+		rh, ok := h.(RouteResourceHandler)
+		if !ok {
+			continue
+		}
+
+		err := rh.ModifyRoute(ctx, route, resources)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ModifyCluster applies the resources to the cluster with the cluster handlers.
+func (r *ResourceRegistry) ModifyCluster(ctx context.Context, cluster *clusterv3.Cluster, resources Resources) error {
+	for _, h := range r.handlers {
+		ch, ok := h.(ClusterResourceHandler)
+		if !ok {
+			continue
+		}
+
+		err := ch.ModifyCluster(ctx, cluster, resources)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeResource unmarshals a resource of a kind served in a single API version.
+func decodeResource[T any](kind, version, apiVersion string, data []byte) (*T, error) {
+	if apiVersion != version {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnsupportedResourceVersion, kind, apiVersion)
+	}
+
+	resource := new(T)
+
+	err := json.Unmarshal(data, resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the %s %s: %w", apiVersion, kind, err)
+	}
+
+	return resource, nil
+}
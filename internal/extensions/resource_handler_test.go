@@ -0,0 +1,195 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openkcm/gateway-extension/api"
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const testBackendKind = "TestBackend"
+
+// testBackend is a custom backend resource setting the connect timeout of its cluster.
+type testBackend struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	TimeoutSeconds int64 `json:"timeoutSeconds"`
+}
+
+type testBackendHandler struct {
+	listeners []string
+}
+
+func (h *testBackendHandler) Kind() string {
+	return testBackendKind
+}
+
+func (h *testBackendHandler) Decode(apiVersion string, data []byte) (any, error) {
+	return decodeResource[testBackend](testBackendKind, "test.openkcm.io/v1", apiVersion, data)
+}
+
+func (h *testBackendHandler) Validate(resource any) error {
+	backend, ok := resource.(*testBackend)
+	if !ok || backend.TimeoutSeconds <= 0 {
+		return assert.AnError
+	}
+
+	return nil
+}
+
+func (h *testBackendHandler) ModifyListener(_ context.Context, listener *listenerv3.Listener, _ Resources) error {
+	h.listeners = append(h.listeners, listener.GetName())
+	return nil
+}
+
+func (h *testBackendHandler) ModifyCluster(_ context.Context, cluster *clusterv3.Cluster, resources Resources) error {
+	for _, r := range resources[testBackendKind] {
+		cluster.ConnectTimeout = &durationpb.Duration{Seconds: r.(*testBackend).TimeoutSeconds}
+	}
+
+	return nil
+}
+
+func testBackendResource(apiVersion string, timeout int64) *extension.ExtensionResource {
+	return &extension.ExtensionResource{UnstructuredBytes: mustMarshalResource(&testBackend{
+		TypeMeta:       metav1.TypeMeta{Kind: testBackendKind, APIVersion: apiVersion},
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "kms", Name: "backend"},
+		TimeoutSeconds: timeout,
+	})}
+}
+
+func TestResourceRegistry_Decode(t *testing.T) {
+	requirement := func(apiVersion, name string) *extension.ExtensionResource {
+		return &extension.ExtensionResource{UnstructuredBytes: mustMarshalResource(&v1alpha1.JWTRequirement{
+			TypeMeta:   metav1.TypeMeta{Kind: api.JWTRequirementKind, APIVersion: apiVersion},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "requirement"},
+			Spec:       v1alpha1.JWTRequirementSpec{Name: name},
+		})}
+	}
+
+	tests := []struct {
+		name      string
+		resources []*extension.ExtensionResource
+		want      map[string]int
+	}{
+		{
+			name: "Known kinds",
+			resources: []*extension.ExtensionResource{
+				{UnstructuredBytes: mustMarshalResource(testJWTProvider("provider", v1alpha1.JWTProviderSpec{}))},
+				requirement(api.JWTRequirementV1Alpha1, "requirement"),
+			},
+			want: map[string]int{api.JWTProviderKind: 1, api.JWTRequirementKind: 1},
+		},
+		{
+			name:      "Unknown kind",
+			resources: []*extension.ExtensionResource{testBackendResource("test.openkcm.io/v1", 1)},
+			want:      map[string]int{},
+		},
+		{
+			name:      "Unsupported version",
+			resources: []*extension.ExtensionResource{requirement("gateway-extension.openkcm.io/v2", "requirement")},
+			want:      map[string]int{},
+		},
+		{
+			name:      "Invalid resource",
+			resources: []*extension.ExtensionResource{requirement(api.JWTRequirementV1Alpha1, "")},
+			want:      map[string]int{},
+		},
+		{
+			name:      "Malformed resource",
+			resources: []*extension.ExtensionResource{{UnstructuredBytes: []byte("{")}},
+			want:      map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+			got := make(map[string]int)
+			for kind, resources := range s.resources.Decode(t.Context(), tt.resources) {
+				got[kind] = len(resources)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeResource(t *testing.T) {
+	data, err := json.Marshal(map[string]any{"kind": testBackendKind, "apiVersion": "test.openkcm.io/v2"})
+	assert.NoError(t, err)
+
+	_, err = decodeResource[testBackend](testBackendKind, "test.openkcm.io/v1", "test.openkcm.io/v2", data)
+	assert.ErrorIs(t, err, ErrUnsupportedResourceVersion)
+
+	_, err = decodeResource[testBackend](testBackendKind, "test.openkcm.io/v1", "test.openkcm.io/v1", []byte("{"))
+	assert.Error(t, err)
+}
+
+func TestGatewayExtension_WithResourceHandlers(t *testing.T) {
+	h := &testBackendHandler{}
+	s := NewGatewayExtension(&commoncfg.FeatureGates{}, WithResourceHandlers(h))
+
+	_, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener: testHTTPListener("kms/gateway/https"),
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{testBackendResource("test.openkcm.io/v1", 5)},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kms/gateway/https"}, h.listeners)
+
+	tests := []struct {
+		name        string
+		resource    *extension.ExtensionResource
+		wantTimeout int64
+	}{
+		{name: "Valid backend", resource: testBackendResource("test.openkcm.io/v1", 5), wantTimeout: 5},
+		{name: "Invalid backend", resource: testBackendResource("test.openkcm.io/v1", 0)},
+		{name: "Unsupported version", resource: testBackendResource("test.openkcm.io/v2", 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.PostClusterModify(t.Context(), &extension.PostClusterModifyRequest{
+				Cluster: &clusterv3.Cluster{Name: "backend"},
+				PostClusterContext: &extension.PostClusterExtensionContext{
+					BackendExtensionResources: []*extension.ExtensionResource{tt.resource},
+				},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTimeout, resp.GetCluster().GetConnectTimeout().GetSeconds())
+		})
+	}
+}
+
+func TestResourceRegistry_Register(t *testing.T) {
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+	r := NewResourceRegistry(defaultResourceHandlers(s)...)
+
+	replacement := &jwtRequirementHandler{s: s}
+	r.Register(replacement)
+	r.Register(&testBackendHandler{})
+
+	kinds := make([]string, 0, len(r.handlers))
+	for _, h := range r.handlers {
+		kinds = append(kinds, h.Kind())
+	}
+
+	assert.Equal(t, []string{api.JWTProviderKind, api.JWTRequirementKind, api.ClaimAuthorizationPolicyKind, api.JWTExemptionKind, testBackendKind}, kinds)
+
+	got, ok := r.Handler(api.JWTRequirementKind)
+	assert.True(t, ok)
+	assert.Same(t, replacement, got)
+}
@@ -0,0 +1,150 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"github.com/openkcm/gateway-extension/api"
+	gev1a1 "github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+// defaultResourceHandlers returns the handlers of the resources served by the extension. The JWTRequirements
+// are applied to the routes after the JWTProviders, so they take precedence over the providers of the route.
+// The ClaimAuthorizationPolicies are applied to the listeners after the JWTProviders, their RBAC filters are
+// inserted after the JWT authentication filter.
+func defaultResourceHandlers(s *GatewayExtension) []ResourceHandler {
+	return []ResourceHandler{
+		&jwtProviderHandler{s: s},
+		&jwtRequirementHandler{s: s},
+		&claimAuthorizationPolicyHandler{s: s},
+		&jwtExemptionHandler{s: s},
+	}
+}
+
+// jwtProviderHandler configures the JWTProviders on the listeners and the routes.
+type jwtProviderHandler struct {
+	s *GatewayExtension
+}
+
+func (h *jwtProviderHandler) Kind() string {
+	return api.JWTProviderKind
+}
+
+func (h *jwtProviderHandler) Decode(apiVersion string, data []byte) (any, error) {
+	return decodeResource[gev1a1.JWTProvider](api.JWTProviderKind, api.JWTProviderV1Alpha1, apiVersion, data)
+}
+
+// Validate accepts all the JWTProviders, they are validated when processed to report it in their status.
+func (h *jwtProviderHandler) Validate(any) error {
+	return nil
+}
+
+// ModifyListener configures the JWTProviders and the JWTRequirements on the listener. The JWT providers
+// referenced by the routes served by the listener must be registered on the listener as well.
+func (h *jwtProviderHandler) ModifyListener(ctx context.Context, listener *listenerv3.Listener, resources Resources) error {
+	providers := resources[api.JWTProviderKind]
+	if len(providers) == 0 && !h.s.hasRouteJWTRequirements(listener) {
+		return nil
+	}
+
+	return h.s.ProcessJWTProviders(ctx, listener, providers, resources[api.JWTRequirementKind])
+}
+
+func (h *jwtProviderHandler) ModifyRoute(ctx context.Context, route *routev3.Route, resources Resources) error {
+	providers := resources[api.JWTProviderKind]
+	if len(providers) == 0 {
+		return nil
+	}
+
+	return h.s.RouteModifyJWTProviders(ctx, route, providers)
+}
+
+// jwtRequirementHandler configures the JWTRequirements referenced by the routes. The JWTRequirements
+// targeting the listeners are configured with the JWTProviders.
+type jwtRequirementHandler struct {
+	s *GatewayExtension
+}
+
+func (h *jwtRequirementHandler) Kind() string {
+	return api.JWTRequirementKind
+}
+
+func (h *jwtRequirementHandler) Decode(apiVersion string, data []byte) (any, error) {
+	return decodeResource[gev1a1.JWTRequirement](api.JWTRequirementKind, api.JWTRequirementV1Alpha1, apiVersion, data)
+}
+
+func (h *jwtRequirementHandler) Validate(resource any) error {
+	jwtr, ok := resource.(*gev1a1.JWTRequirement)
+	if !ok {
+		return fmt.Errorf("%w: unexpected type %T", ErrInvalidJWTRequirement, resource)
+	}
+
+	if jwtr.Spec.Name == "" {
+		return fmt.Errorf("%w: no name specified", ErrInvalidJWTRequirement)
+	}
+
+	return nil
+}
+
+func (h *jwtRequirementHandler) ModifyRoute(ctx context.Context, route *routev3.Route, resources Resources) error {
+	requirements := resources[api.JWTRequirementKind]
+	if len(requirements) == 0 {
+		return nil
+	}
+
+	return h.s.RouteModifyJWTRequirements(ctx, route, requirements)
+}
+
+// claimAuthorizationPolicyHandler configures the ClaimAuthorizationPolicies on the listeners.
+type claimAuthorizationPolicyHandler struct {
+	s *GatewayExtension
+}
+
+func (h *claimAuthorizationPolicyHandler) Kind() string {
+	return api.ClaimAuthorizationPolicyKind
+}
+
+func (h *claimAuthorizationPolicyHandler) Decode(apiVersion string, data []byte) (any, error) {
+	return decodeResource[gev1a1.ClaimAuthorizationPolicy](
+		api.ClaimAuthorizationPolicyKind, api.ClaimAuthorizationPolicyV1Alpha1, apiVersion, data)
+}
+
+// Validate accepts all the ClaimAuthorizationPolicies, their rules are checked when compiled.
+func (h *claimAuthorizationPolicyHandler) Validate(any) error {
+	return nil
+}
+
+func (h *claimAuthorizationPolicyHandler) ModifyListener(ctx context.Context, listener *listenerv3.Listener, resources Resources) error {
+	policies := resources[api.ClaimAuthorizationPolicyKind]
+	if len(policies) == 0 {
+		return nil
+	}
+
+	return h.s.ProcessClaimAuthorizationPolicies(ctx, listener, policies)
+}
+
+// jwtExemptionHandler validates the JWTExemptions handed over by Envoy Gateway. The JWTExemptions are applied
+// to the virtual hosts, which are not provided with extension resources, so they are read from the cluster.
+type jwtExemptionHandler struct {
+	s *GatewayExtension
+}
+
+func (h *jwtExemptionHandler) Kind() string {
+	return api.JWTExemptionKind
+}
+
+func (h *jwtExemptionHandler) Decode(apiVersion string, data []byte) (any, error) {
+	return decodeResource[gev1a1.JWTExemption](api.JWTExemptionKind, api.JWTExemptionV1Alpha1, apiVersion, data)
+}
+
+func (h *jwtExemptionHandler) Validate(resource any) error {
+	exemption, ok := resource.(*gev1a1.JWTExemption)
+	if !ok {
+		return fmt.Errorf("%w: unexpected type %T", ErrInvalidJWTExemption, resource)
+	}
+
+	return validateJWTExemption(exemption)
+}
@@ -0,0 +1,268 @@
+package extensions
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/types"
+
+	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	jwtauth3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	slogctx "github.com/veqryn/slog-context"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+	"github.com/openkcm/gateway-extension/internal/flags"
+)
+
+const (
+	JwtAuthRouteMappingPrefix = "jwt_auth_route_openkcm"
+)
+
+// RouteModifyJWTProviders is called for the routes generated from an HTTPRoute referencing
+// JWTProviders as extensionRef filters. The route gets its own JWT requirement, satisfied by
+// any of the referenced providers, which is registered on the listeners of the Gateway serving
+// the route once they are processed.
+func (s *GatewayExtension) RouteModifyJWTProviders(ctx context.Context, route *routev3.Route, resources []any) error {
+	// Do nothing if the feature gate is set making empty the jwt providers
+	if s.features.IsFeatureEnabled(flags.DisableJWTProviderComputation) {
+		slogctx.Warn(ctx, "Skipping JWTProvider as is disabled through flags", "name", route.GetName())
+		return nil
+	}
+
+	providers := make(map[types.NamespacedName]*v1alpha1.JWTProvider)
+
+	for _, resource := range resources {
+		jwtp, ok := resource.(*v1alpha1.JWTProvider)
+		if !ok {
+			continue
+		}
+
+		providers[jwtProviderKey(jwtp)] = jwtp
+	}
+
+	if len(providers) == 0 {
+		slogctx.Info(ctx, "No JWTProvider referenced by the route", "name", route.GetName())
+		return nil
+	}
+
+	keys := slices.SortedFunc(maps.Keys(providers), compareNamespacedNames)
+	requirementName := routeRequirementName(keys)
+
+	routeCfgAny, err := newAny(&jwtauth3.PerRouteConfig{
+		RequirementSpecifier: &jwtauth3.PerRouteConfig_RequirementName{RequirementName: requirementName},
+	})
+	if err != nil {
+		return err
+	}
+
+	requirement := make([]*v1alpha1.JWTProvider, 0, len(keys))
+	for _, key := range keys {
+		requirement = append(requirement, providers[key])
+	}
+
+	s.routeJWTAuthnMu.Lock()
+	defer s.routeJWTAuthnMu.Unlock()
+
+	if s.routeJWTRequirements == nil {
+		s.routeJWTRequirements = make(map[string][]*v1alpha1.JWTProvider)
+	}
+
+	s.routeJWTRequirements[requirementName] = requirement
+
+	if route.GetTypedPerFilterConfig() == nil {
+		route.TypedPerFilterConfig = make(map[string]*anypb.Any)
+	}
+
+	route.TypedPerFilterConfig[egv1a1.EnvoyFilterJWTAuthn.String()] = routeCfgAny
+
+	slogctx.Info(ctx, "Processed route JWT requirement", "name", route.GetName(), "requirement", requirementName)
+
+	return nil
+}
+
+// recordRouteJWTRequirements records the route requirements selected by the routes of the virtual host
+// for the Gateway owning it. The virtual hosts not named after a Gateway listener record them for all
+// the Gateways.
+func (s *GatewayExtension) recordRouteJWTRequirements(ctx context.Context, virtualHost *routev3.VirtualHost) {
+	var names []string
+
+	for _, r := range virtualHost.GetRoutes() {
+		name := routeRequirementNameOf(r)
+		if strings.HasPrefix(name, JwtAuthRouteMappingPrefix+":") && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	gateway, ok := virtualHostGateway(virtualHost)
+	if !ok {
+		slogctx.Warn(ctx, "Registering the route JWT requirements on all the listeners as the virtual host is not named after a Gateway listener",
+			"name", virtualHost.GetName())
+	}
+
+	s.routeJWTAuthnMu.Lock()
+	defer s.routeJWTAuthnMu.Unlock()
+
+	if s.gatewayRouteJWTRequirements == nil {
+		s.gatewayRouteJWTRequirements = make(map[types.NamespacedName][]string)
+	}
+
+	for _, name := range names {
+		if !slices.Contains(s.gatewayRouteJWTRequirements[gateway], name) {
+			s.gatewayRouteJWTRequirements[gateway] = append(s.gatewayRouteJWTRequirements[gateway], name)
+		}
+	}
+}
+
+// gatewayRouteJWTRequirementsOf returns the route requirements selected by the routes of the Gateways of
+// the listener targets, along with the ones of the routes of unknown Gateways.
+func (s *GatewayExtension) gatewayRouteJWTRequirementsOf(targets []listenerTarget) map[string][]*v1alpha1.JWTProvider {
+	s.routeJWTAuthnMu.RLock()
+	defer s.routeJWTAuthnMu.RUnlock()
+
+	requirements := make(map[string][]*v1alpha1.JWTProvider)
+
+	for _, gateway := range append(targetGateways(targets), types.NamespacedName{}) {
+		for _, name := range s.gatewayRouteJWTRequirements[gateway] {
+			if providers, ok := s.routeJWTRequirements[name]; ok {
+				requirements[name] = providers
+			}
+		}
+	}
+
+	return requirements
+}
+
+// hasRouteJWTRequirements reports if the routes served by the listener selected route requirements
+// since the last translation.
+func (s *GatewayExtension) hasRouteJWTRequirements(listener *listenerv3.Listener) bool {
+	return len(s.gatewayRouteJWTRequirementsOf(listenerTargets(listener))) > 0
+}
+
+// resetRouteJWTRequirements forgets the JWT requirements collected from the routes.
+func (s *GatewayExtension) resetRouteJWTRequirements() {
+	s.routeJWTAuthnMu.Lock()
+	defer s.routeJWTAuthnMu.Unlock()
+
+	clear(s.routeJWTRequirements)
+	clear(s.gatewayRouteJWTRequirements)
+}
+
+// routeRequirementName returns the name of the requirement satisfied by any of the given providers.
+func routeRequirementName(providers []types.NamespacedName) string {
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.String())
+	}
+
+	return JwtAuthRouteMappingPrefix + ":" + strings.Join(names, ",")
+}
+
+// routeRequirementNameOf returns the name of the requirement selected by the route, if any.
+func routeRequirementNameOf(route *routev3.Route) string {
+	cfg := route.GetTypedPerFilterConfig()[egv1a1.EnvoyFilterJWTAuthn.String()]
+	if cfg == nil {
+		return ""
+	}
+
+	perRouteConfig := new(jwtauth3.PerRouteConfig)
+
+	err := cfg.UnmarshalTo(perRouteConfig)
+	if err != nil {
+		return ""
+	}
+
+	return perRouteConfig.GetRequirementName()
+}
+
+// jwtProviderKey returns the namespaced name of the JWTProvider.
+func jwtProviderKey(jwtp *v1alpha1.JWTProvider) types.NamespacedName {
+	return types.NamespacedName{Namespace: jwtp.GetNamespace(), Name: jwtp.GetName()}
+}
+
+func compareNamespacedNames(a, b types.NamespacedName) int {
+	return strings.Compare(a.String(), b.String())
+}
+
+// buildAnyProviderRequirement returns a requirement satisfied by any of the given providers.
+func buildAnyProviderRequirement(providerNames []string) *jwtauth3.JwtRequirement {
+	reqs := make([]*jwtauth3.JwtRequirement, 0, len(providerNames))
+
+	for _, name := range providerNames {
+		reqs = append(reqs, &jwtauth3.JwtRequirement{
+			RequiresType: &jwtauth3.JwtRequirement_ProviderName{
+				ProviderName: name,
+			},
+		})
+	}
+
+	if len(reqs) == 1 {
+		return reqs[0]
+	}
+
+	return &jwtauth3.JwtRequirement{
+		RequiresType: &jwtauth3.JwtRequirement_RequiresAny{
+			RequiresAny: &jwtauth3.JwtRequirementOrList{
+				Requirements: reqs,
+			},
+		},
+	}
+}
+
+// RouteModifyJWTRequirements is called for the routes generated from an HTTPRoute referencing a
+// JWTRequirement as extensionRef filter. The route selects the named requirement, which has to be
+// registered on the listener by targeting its Gateway.
+func (s *GatewayExtension) RouteModifyJWTRequirements(ctx context.Context, route *routev3.Route, resources []any) error {
+	// Do nothing if the feature gate is set making empty the jwt providers
+	if s.features.IsFeatureEnabled(flags.DisableJWTProviderComputation) {
+		slogctx.Warn(ctx, "Skipping JWTRequirement as is disabled through flags", "name", route.GetName())
+		return nil
+	}
+
+	var jwtr *v1alpha1.JWTRequirement
+
+	for _, resource := range resources {
+		r, ok := resource.(*v1alpha1.JWTRequirement)
+		if !ok {
+			continue
+		}
+
+		if jwtr != nil {
+			slogctx.Warn(ctx, "Route references multiple JWTRequirements; Using the first one",
+				"name", route.GetName(), "requirement", jwtr.Spec.Name)
+
+			break
+		}
+
+		jwtr = r
+	}
+
+	if jwtr == nil {
+		return nil
+	}
+
+	routeCfgAny, err := newAny(&jwtauth3.PerRouteConfig{
+		RequirementSpecifier: &jwtauth3.PerRouteConfig_RequirementName{RequirementName: jwtr.Spec.Name},
+	})
+	if err != nil {
+		return err
+	}
+
+	if route.GetTypedPerFilterConfig() == nil {
+		route.TypedPerFilterConfig = make(map[string]*anypb.Any)
+	}
+
+	route.TypedPerFilterConfig[egv1a1.EnvoyFilterJWTAuthn.String()] = routeCfgAny
+
+	slogctx.Info(ctx, "Processed route JWT requirement", "name", route.GetName(), "requirement", jwtr.Spec.Name)
+
+	return nil
+}
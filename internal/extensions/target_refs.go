@@ -0,0 +1,172 @@
+package extensions
+
+import (
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	gatewayKind = "Gateway"
+)
+
+// listenerTarget identifies the Gateway listener an xDS listener or filter chain was generated from.
+type listenerTarget struct {
+	namespace   string
+	gateway     string
+	sectionName string
+}
+
+// parseListenerTarget extracts the Gateway identity from an xDS resource name generated by Envoy Gateway.
+// The supported forms are `<namespace>/<gateway>/<section>` and, for listeners merged from a ListenerSet,
+// `<namespace>/<gateway>/<listenerset namespace>/<listenerset name>/<section>`.
+func parseListenerTarget(name string) (listenerTarget, bool) {
+	parts := strings.Split(name, "/")
+
+	switch len(parts) {
+	case 3, 5:
+		return listenerTarget{
+			namespace:   parts[0],
+			gateway:     parts[1],
+			sectionName: parts[len(parts)-1],
+		}, true
+	default:
+		return listenerTarget{}, false
+	}
+}
+
+// gatewayName returns the namespaced name of the Gateway of the listener target.
+func (t listenerTarget) gatewayName() types.NamespacedName {
+	return types.NamespacedName{Namespace: t.namespace, Name: t.gateway}
+}
+
+// virtualHostTarget returns the Gateway listener a virtual host was generated from, identified by the
+// name of the virtual host, `<listener name>/<hostname>`.
+func virtualHostTarget(vh *routev3.VirtualHost) (listenerTarget, bool) {
+	i := strings.LastIndex(vh.GetName(), "/")
+	if i < 0 {
+		return listenerTarget{}, false
+	}
+
+	return parseListenerTarget(vh.GetName()[:i])
+}
+
+// virtualHostGateway returns the Gateway a virtual host was generated for.
+func virtualHostGateway(vh *routev3.VirtualHost) (types.NamespacedName, bool) {
+	target, ok := virtualHostTarget(vh)
+	if !ok {
+		return types.NamespacedName{}, false
+	}
+
+	return target.gatewayName(), true
+}
+
+// targetGateways returns the Gateways of the listener targets.
+func targetGateways(targets []listenerTarget) []types.NamespacedName {
+	gateways := make([]types.NamespacedName, 0, len(targets))
+
+	for _, target := range targets {
+		if !slices.Contains(gateways, target.gatewayName()) {
+			gateways = append(gateways, target.gatewayName())
+		}
+	}
+
+	return gateways
+}
+
+// listenerFilterChains returns the filter chains of the listener, the default one last.
+func listenerFilterChains(listener *listenerv3.Listener) []*listenerv3.FilterChain {
+	filterChains := slices.Clone(listener.GetFilterChains())
+
+	if listener.GetDefaultFilterChain() != nil {
+		filterChains = append(filterChains, listener.GetDefaultFilterChain())
+	}
+
+	return filterChains
+}
+
+// filterChainTargets returns the Gateway listener a filter chain was generated from, identified by the
+// name of the filter chain or, for the chains not named after a Gateway listener, by the name of the
+// xDS listener.
+func filterChainTargets(listener *listenerv3.Listener, filterChain *listenerv3.FilterChain) []listenerTarget {
+	for _, name := range []string{filterChain.GetName(), listener.GetName()} {
+		target, ok := parseListenerTarget(name)
+		if ok {
+			return []listenerTarget{target}
+		}
+	}
+
+	return nil
+}
+
+// listenerTargets returns all the Gateway listeners served by the filter chains of the xDS listener.
+func listenerTargets(listener *listenerv3.Listener) []listenerTarget {
+	targets := make([]listenerTarget, 0)
+
+	for _, filterChain := range listenerFilterChains(listener) {
+		for _, target := range filterChainTargets(listener, filterChain) {
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	return targets
+}
+
+// hasTargetRefs reports if at least one of the target references names a resource.
+func hasTargetRefs(refs []gwapiv1.LocalPolicyTargetReferenceWithSectionName) bool {
+	for _, ref := range refs {
+		if ref.Name != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesTargetRefs reports if any of the target references, declared by a policy living in the
+// given namespace, selects one of the listener targets. Policies without named target references
+// match every listener.
+func matchesTargetRefs(namespace string, refs []gwapiv1.LocalPolicyTargetReferenceWithSectionName, targets []listenerTarget) bool {
+	if !hasTargetRefs(refs) {
+		return true
+	}
+
+	for _, ref := range refs {
+		if ref.Name == "" {
+			continue
+		}
+
+		if ref.Group != "" && ref.Group != gwapiv1.GroupName {
+			continue
+		}
+
+		if ref.Kind != "" && ref.Kind != gatewayKind {
+			continue
+		}
+
+		for _, target := range targets {
+			if namespace != "" && namespace != target.namespace {
+				continue
+			}
+
+			if string(ref.Name) != target.gateway {
+				continue
+			}
+
+			if ref.SectionName != nil && string(*ref.SectionName) != target.sectionName {
+				continue
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
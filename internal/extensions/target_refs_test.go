@@ -0,0 +1,344 @@
+package extensions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api"
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+func gatewayRef(name string, sectionName *string) gwapiv1.LocalPolicyTargetReferenceWithSectionName {
+	ref := gwapiv1.LocalPolicyTargetReferenceWithSectionName{
+		LocalPolicyTargetReference: gwapiv1.LocalPolicyTargetReference{
+			Group: gwapiv1.GroupName,
+			Kind:  gatewayKind,
+			Name:  gwapiv1.ObjectName(name),
+		},
+	}
+
+	if sectionName != nil {
+		ref.SectionName = ptr.To(gwapiv1.SectionName(*sectionName))
+	}
+
+	return ref
+}
+
+func TestParseListenerTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		want     listenerTarget
+		wantOk   bool
+	}{
+		{
+			name:     "Gateway listener",
+			resource: "kms/kms-public/https",
+			want:     listenerTarget{namespace: "kms", gateway: "kms-public", sectionName: "https"},
+			wantOk:   true,
+		},
+		{
+			name:     "ListenerSet listener",
+			resource: "kms/kms-public/apps/listeners/https",
+			want:     listenerTarget{namespace: "kms", gateway: "kms-public", sectionName: "https"},
+			wantOk:   true,
+		},
+		{
+			name:     "Port based name",
+			resource: "tcp-443",
+			wantOk:   false,
+		},
+		{
+			name:     "Empty name",
+			resource: "",
+			wantOk:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseListenerTarget(tt.resource)
+			assert.Equalf(t, tt.wantOk, ok, "parseListenerTarget(%v)", tt.resource)
+			assert.Equalf(t, tt.want, got, "parseListenerTarget(%v)", tt.resource)
+		})
+	}
+}
+
+func TestListenerTargets(t *testing.T) {
+	listener := &listenerv3.Listener{
+		Name: "tcp-443",
+		FilterChains: []*listenerv3.FilterChain{
+			{Name: "kms/kms-public/https"},
+			{Name: "kms/kms-admin/https"},
+		},
+		DefaultFilterChain: &listenerv3.FilterChain{Name: "http-443"},
+	}
+
+	want := []listenerTarget{
+		{namespace: "kms", gateway: "kms-public", sectionName: "https"},
+		{namespace: "kms", gateway: "kms-admin", sectionName: "https"},
+	}
+
+	assert.Equal(t, want, listenerTargets(listener))
+}
+
+func TestFilterChainTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		listener    string
+		filterChain string
+		want        []listenerTarget
+	}{
+		{
+			name:        "Filter chain named after a Gateway listener",
+			listener:    "kms/kms-public/https",
+			filterChain: "kms/kms-admin/https",
+			want:        []listenerTarget{{namespace: "kms", gateway: "kms-admin", sectionName: "https"}},
+		},
+		{
+			name:        "Listener named after a Gateway listener",
+			listener:    "kms/kms-public/https",
+			filterChain: "",
+			want:        []listenerTarget{{namespace: "kms", gateway: "kms-public", sectionName: "https"}},
+		},
+		{
+			name:        "Unidentified filter chain",
+			listener:    "tcp-443",
+			filterChain: "http-443",
+			want:        nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterChainTargets(&listenerv3.Listener{Name: tt.listener}, &listenerv3.FilterChain{Name: tt.filterChain})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchesTargetRefs(t *testing.T) {
+	targets := []listenerTarget{
+		{namespace: "kms", gateway: "kms-public", sectionName: "https"},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		refs      []gwapiv1.LocalPolicyTargetReferenceWithSectionName
+		targets   []listenerTarget
+		want      bool
+	}{
+		{
+			name:      "No target refs",
+			namespace: "kms",
+			refs:      nil,
+			targets:   targets,
+			want:      true,
+		},
+		{
+			name:      "Only unnamed target refs",
+			namespace: "kms",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{{}},
+			targets:   targets,
+			want:      true,
+		},
+		{
+			name:      "Matching gateway",
+			namespace: "kms",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", nil)},
+			targets:   targets,
+			want:      true,
+		},
+		{
+			name:      "Matching gateway and section name",
+			namespace: "kms",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", ptr.To("https"))},
+			targets:   targets,
+			want:      true,
+		},
+		{
+			name:      "Matching gateway with other section name",
+			namespace: "kms",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", ptr.To("http"))},
+			targets:   targets,
+			want:      false,
+		},
+		{
+			name:      "Other gateway",
+			namespace: "kms",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-admin", nil)},
+			targets:   targets,
+			want:      false,
+		},
+		{
+			name:      "One of many target refs matching",
+			namespace: "kms",
+			refs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{
+				gatewayRef("kms-admin", nil),
+				gatewayRef("kms-public", nil),
+			},
+			targets: targets,
+			want:    true,
+		},
+		{
+			name:      "Gateway in another namespace",
+			namespace: "other",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", nil)},
+			targets:   targets,
+			want:      false,
+		},
+		{
+			name:      "Policy without namespace",
+			namespace: "",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", nil)},
+			targets:   targets,
+			want:      true,
+		},
+		{
+			name:      "Non gateway kind",
+			namespace: "kms",
+			refs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{{
+				LocalPolicyTargetReference: gwapiv1.LocalPolicyTargetReference{
+					Group: gwapiv1.GroupName,
+					Kind:  "HTTPRoute",
+					Name:  "kms-public",
+				},
+			}},
+			targets: targets,
+			want:    false,
+		},
+		{
+			name:      "Non gateway group",
+			namespace: "kms",
+			refs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{{
+				LocalPolicyTargetReference: gwapiv1.LocalPolicyTargetReference{
+					Group: "example.com",
+					Kind:  gatewayKind,
+					Name:  "kms-public",
+				},
+			}},
+			targets: targets,
+			want:    false,
+		},
+		{
+			name:      "Unidentified listener",
+			namespace: "kms",
+			refs:      []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", nil)},
+			targets:   nil,
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, matchesTargetRefs(tt.namespace, tt.refs, tt.targets),
+				fmt.Sprintf("matchesTargetRefs(%v, %v, %v)", tt.namespace, tt.refs, tt.targets))
+		})
+	}
+}
+
+func TestGatewayExtension_PostHTTPListenerModify_SharedListener(t *testing.T) {
+	filterChain := func(name string) *listenerv3.FilterChain {
+		return &listenerv3.FilterChain{
+			Name: name,
+			Filters: []*listenerv3.Filter{{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &listenerv3.Filter_TypedConfig{
+					TypedConfig: mustNewAny(&hcm.HttpConnectionManager{
+						HttpFilters: []*hcm.HttpFilter{{Name: wellknown.Router}},
+					}),
+				},
+			}},
+		}
+	}
+
+	policy := &v1alpha1.ClaimAuthorizationPolicy{
+		TypeMeta:   metav1.TypeMeta{Kind: api.ClaimAuthorizationPolicyKind, APIVersion: api.ClaimAuthorizationPolicyV1Alpha1},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "public"},
+		Spec: v1alpha1.ClaimAuthorizationPolicySpec{
+			TargetRefs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", nil)},
+			Action:     v1alpha1.ClaimAuthorizationActionAllow,
+			Rules: []v1alpha1.ClaimAuthorizationRule{{
+				Name:   "scope",
+				Claims: []v1alpha1.ClaimMatch{{Claim: "scope", Operator: v1alpha1.ClaimMatchOperatorExists}},
+			}},
+		},
+	}
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	// Both Gateway listeners share the xDS listener of the port, each one with its own filter chain
+	resp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+		Listener: &listenerv3.Listener{
+			Name:         "kms/kms-public/https",
+			FilterChains: []*listenerv3.FilterChain{filterChain("kms/kms-public/https"), filterChain("kms/kms-admin/https")},
+		},
+		PostListenerContext: &extension.PostHTTPListenerExtensionContext{
+			ExtensionResources: []*extension.ExtensionResource{
+				{UnstructuredBytes: mustMarshalResource(testJWTProvider("public", v1alpha1.JWTProviderSpec{
+					Name: "Public", Issuer: "https://public.example.com",
+					RemoteJwks: &v1alpha1.RemoteJWKS{URI: "https://public.example.com/jwks"},
+					TargetRefs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-public", nil)},
+				}))},
+				{UnstructuredBytes: mustMarshalResource(testJWTProvider("admin", v1alpha1.JWTProviderSpec{
+					Name: "Admin", Issuer: "https://admin.example.com",
+					RemoteJwks: &v1alpha1.RemoteJWKS{URI: "https://admin.example.com/jwks"},
+					TargetRefs: []gwapiv1.LocalPolicyTargetReferenceWithSectionName{gatewayRef("kms-admin", nil)},
+				}))},
+				{UnstructuredBytes: mustMarshalResource(policy)},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		provider    string
+		wantFilters []string
+	}{
+		{
+			name:        "kms/kms-public/https",
+			provider:    "Public",
+			wantFilters: []string{"envoy.filters.http.jwt_authn", ClaimAuthzAllowFilterName, wellknown.Router},
+		},
+		{
+			name:        "kms/kms-admin/https",
+			provider:    "Admin",
+			wantFilters: []string{"envoy.filters.http.jwt_authn", wellknown.Router},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpConManager, _, err := findHCM(resp.GetListener().GetFilterChains()[i])
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			names := make([]string, 0, len(httpConManager.GetHttpFilters()))
+			for _, filter := range httpConManager.GetHttpFilters() {
+				names = append(names, filter.GetName())
+			}
+
+			assert.Equal(t, tt.wantFilters, names)
+
+			jwtAuthn, _, err := findJwtAuthenticationFilter(httpConManager.GetHttpFilters())
+			if !assert.NoError(t, err) || !assert.NotNil(t, jwtAuthn) {
+				return
+			}
+
+			// Only the provider targeting the Gateway listener of the filter chain is enforced
+			assert.Len(t, jwtAuthn.GetProviders(), 1)
+			assert.Contains(t, jwtAuthn.GetProviders(), tt.provider)
+			assert.Equal(t, tt.provider, jwtAuthn.GetRequirementMap()[JwtAuthSecureMappingName].GetProviderName())
+		})
+	}
+}
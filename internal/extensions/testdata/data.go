@@ -10,3 +10,9 @@ var WellKnownJSON []byte
 
 //go:embed openid-configuration.json
 var OpenIDConfigurationJSON []byte
+
+//go:embed jwt-requirement.json
+var JWTRequirementJSON []byte
+
+//go:embed claim-authorization-policy.json
+var ClaimAuthorizationPolicyJSON []byte
@@ -0,0 +1,69 @@
+package extensions
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	pb "github.com/envoyproxy/gateway/proto/extension"
+)
+
+const tracerName = "github.com/openkcm/gateway-extension/internal/extensions"
+
+// tracer returns the tracer of the extension. It uses the global provider set by the OpenTelemetry
+// initialisation, spans are dropped if tracing is disabled.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startSpan starts a child span of the span in the context.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the error, if any, on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// hookAttributes returns the span attributes identifying the xDS resource of the hook request.
+func hookAttributes(req any) []attribute.KeyValue {
+	switch r := req.(type) {
+	case *pb.PostRouteModifyRequest:
+		return []attribute.KeyValue{
+			attribute.String("xds.route", r.GetRoute().GetName()),
+			attribute.Int("extension.resources", len(r.GetPostRouteContext().GetExtensionResources())),
+		}
+	case *pb.PostVirtualHostModifyRequest:
+		return []attribute.KeyValue{
+			attribute.String("xds.virtual_host", r.GetVirtualHost().GetName()),
+			attribute.Int("xds.routes", len(r.GetVirtualHost().GetRoutes())),
+		}
+	case *pb.PostHTTPListenerModifyRequest:
+		return []attribute.KeyValue{
+			attribute.String("xds.listener", r.GetListener().GetName()),
+			attribute.Int("extension.resources", len(r.GetPostListenerContext().GetExtensionResources())),
+		}
+	case *pb.PostClusterModifyRequest:
+		return []attribute.KeyValue{
+			attribute.String("xds.cluster", r.GetCluster().GetName()),
+			attribute.Int("extension.resources", len(r.GetPostClusterContext().GetBackendExtensionResources())),
+		}
+	case *pb.PostTranslateModifyRequest:
+		return []attribute.KeyValue{
+			attribute.Int("xds.clusters", len(r.GetClusters())),
+			attribute.Int("xds.listeners", len(r.GetListeners())),
+			attribute.Int("xds.secrets", len(r.GetSecrets())),
+		}
+	}
+
+	return nil
+}
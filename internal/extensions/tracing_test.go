@@ -0,0 +1,123 @@
+package extensions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+// newTestTracing records the spans in memory until the end of the test.
+func newTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		_ = tp.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+// spansByName returns the ended spans by name.
+func spansByName(exporter *tracetest.InMemoryExporter) map[string][]tracetest.SpanStub {
+	spans := make(map[string][]tracetest.SpanStub)
+
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = append(spans[span.Name], span)
+	}
+
+	return spans
+}
+
+// serverSpanName is the name of the span started by the otelgrpc stats handler for a call of the hook.
+func serverSpanName(hook string) string {
+	return "envoygateway.extension.EnvoyGatewayExtension/" + hook
+}
+
+func TestGatewayExtension_HookSpans(t *testing.T) {
+	exporter := newTestTracing(t)
+	server := newDiscoveryServer(t)
+
+	s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+	// call runs the hook under a server span, as the gRPC server does
+	call := func(t *testing.T, hook string, fn func(ctx context.Context) error) {
+		t.Helper()
+
+		ctx, span := startSpan(t.Context(), serverSpanName(hook))
+		assert.NoError(t, fn(ctx))
+		span.End()
+	}
+
+	call(t, hookPostHTTPListenerModify, func(ctx context.Context) error {
+		_, err := s.PostHTTPListenerModify(ctx, &extension.PostHTTPListenerModifyRequest{
+			Listener: testHTTPListener("kms/gateway/https"),
+			PostListenerContext: &extension.PostHTTPListenerExtensionContext{
+				ExtensionResources: []*extension.ExtensionResource{{
+					UnstructuredBytes: mustMarshalResource(testJWTProvider("discovered", v1alpha1.JWTProviderSpec{
+						Name: "Discovered", Issuer: server.URL,
+					})),
+				}},
+			},
+		})
+
+		return err
+	})
+
+	call(t, hookPostTranslateModify, func(ctx context.Context) error {
+		_, err := s.PostTranslateModify(ctx, &extension.PostTranslateModifyRequest{
+			Clusters: []*clusterv3.Cluster{{Name: "httproute/kms/api/rule/0"}},
+		})
+
+		return err
+	})
+
+	spans := spansByName(exporter)
+
+	// The extension processing is traced under the server span of the hook
+	tests := []struct {
+		name   string
+		parent string
+	}{
+		{name: "GatewayExtension.ProcessJWTProviders", parent: serverSpanName(hookPostHTTPListenerModify)},
+		{name: "GatewayExtension.buildJWTProvider", parent: "GatewayExtension.ProcessJWTProviders"},
+		{name: "OIDCDiscovery.JWKSURI", parent: "GatewayExtension.buildJWTProvider"},
+		{name: "OIDCDiscovery.fetch", parent: "OIDCDiscovery.JWKSURI"},
+		{name: "GatewayExtension.TranslateModifyClusters", parent: serverSpanName(hookPostTranslateModify)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if assert.Len(t, spans[tt.name], 1) && assert.Len(t, spans[tt.parent], 1) {
+				assert.Equal(t, spans[tt.parent][0].SpanContext.SpanID(), spans[tt.name][0].Parent.SpanID())
+				assert.Equal(t, spans[tt.parent][0].SpanContext.TraceID(), spans[tt.name][0].SpanContext.TraceID())
+			}
+		})
+	}
+
+	// The hooks add the xDS resource of the request to the server span, without starting their own
+	assert.Empty(t, spans[hookPostHTTPListenerModify])
+
+	if assert.Len(t, spans[serverSpanName(hookPostHTTPListenerModify)], 1) {
+		assert.Contains(t, spans[serverSpanName(hookPostHTTPListenerModify)][0].Attributes, attribute.String("xds.listener", "kms/gateway/https"))
+	}
+
+	if assert.Len(t, spans[serverSpanName(hookPostTranslateModify)], 1) {
+		assert.Contains(t, spans[serverSpanName(hookPostTranslateModify)][0].Attributes, attribute.Int("xds.clusters", 1))
+	}
+}
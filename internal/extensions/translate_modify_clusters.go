@@ -2,15 +2,17 @@ package extensions
 
 import (
 	"context"
+	"maps"
+	"slices"
 
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
-	"google.golang.org/protobuf/types/known/anypb"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/utils/ptr"
 
 	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
-	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	slogctx "github.com/veqryn/slog-context"
 
 	"github.com/openkcm/gateway-extension/internal/flags"
@@ -31,7 +33,23 @@ func cleanUpClusters(cls []*clusterv3.Cluster) []*clusterv3.Cluster {
 	return clusters
 }
 
+// countCustomClusters returns the number of clusters added by the extension.
+func countCustomClusters(cls []*clusterv3.Cluster) int {
+	n := 0
+
+	for _, c := range cls {
+		if IsCustomName(c.GetName()) {
+			n++
+		}
+	}
+
+	return n
+}
+
 func (s *GatewayExtension) TranslateModifyClusters(ctx context.Context, cls []*clusterv3.Cluster) ([]*clusterv3.Cluster, error) {
+	ctx, span := startSpan(ctx, "GatewayExtension.TranslateModifyClusters")
+	defer span.End()
+
 	s.jwtAuthClustersMu.RLock()
 	defer s.jwtAuthClustersMu.RUnlock()
 
@@ -41,7 +59,8 @@ func (s *GatewayExtension) TranslateModifyClusters(ctx context.Context, cls []*c
 		return cleanUpClusters(cls), nil
 	}
 
-	if len(s.jwtAuthClusters) == 0 {
+	jwtAuthClusters := s.referencedJWTAuthClusters()
+	if len(jwtAuthClusters) == 0 {
 		slogctx.Info(ctx, "No updates on the cached clusters; Continue skip updates of clusters configuration.")
 		return cls, nil
 	}
@@ -49,74 +68,108 @@ func (s *GatewayExtension) TranslateModifyClusters(ctx context.Context, cls []*c
 	// remove clusters that has as suffix name `openkcm`,
 	clusters := cleanUpClusters(cls)
 
-	// will be added new list of the clusters with the suffix name `openkcm`
-	for _, v := range s.jwtAuthClusters {
-		clusterName := v.CustomName()
+	// The proxies shared by several clusters get a single cluster
+	proxies := make(map[string]*urlCluster)
 
-		slogctx.Info(ctx, "Processing cached cluster", "name", clusterName)
+	// will be added new list of the clusters with the suffix name `openkcm`, sorted by name so the
+	// output does not change between calls
+	for _, name := range slices.Sorted(maps.Keys(jwtAuthClusters)) {
+		v := jwtAuthClusters[name]
 
-		trCtx, err := anypb.New(buildXdsUpstreamTLSSocket(v.hostname))
+		slogctx.Info(ctx, "Processing cached cluster", "name", v.CustomName())
+
+		cluster, err := buildURLCluster(v, s.dnsLookupFamily)
 		if err != nil {
 			return nil, err
 		}
 
-		cluster := &clusterv3.Cluster{
-			Name:                 clusterName,
-			ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STRICT_DNS},
-			ConnectTimeout:       &durationpb.Duration{Seconds: 2},
-			DnsLookupFamily:      clusterv3.Cluster_V4_ONLY,
-			LoadAssignment: &endpointv3.ClusterLoadAssignment{
-				ClusterName: clusterName,
-				Endpoints: []*endpointv3.LocalityLbEndpoints{{
-					LbEndpoints: []*endpointv3.LbEndpoint{{
-						HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
-							Endpoint: &endpointv3.Endpoint{
-								Address: &corev3.Address{
-									Address: &corev3.Address_SocketAddress{
-										SocketAddress: &corev3.SocketAddress{
-											Address: v.hostname,
-											PortSpecifier: &corev3.SocketAddress_PortValue{
-												PortValue: uint32(443),
-											},
-											Protocol: corev3.SocketAddress_TCP,
-										},
-									},
-								},
-							},
-						},
-					}},
-				}},
-			},
-			TransportSocket: &corev3.TransportSocket{
-				Name: wellknown.TransportSocketTls,
-				ConfigType: &corev3.TransportSocket_TypedConfig{
-					TypedConfig: trCtx,
-				},
-			},
+		clusters = append(clusters, cluster)
+
+		if v.proxy != nil {
+			proxies[v.proxy.name] = v.proxy
 		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(proxies)) {
+		v := proxies[name]
+
+		slogctx.Info(ctx, "Processing proxy cluster", "name", v.CustomName())
+
+		cluster, err := buildURLCluster(v, s.dnsLookupFamily)
+		if err != nil {
+			return nil, err
+		}
+
 		clusters = append(clusters, cluster)
 	}
 
+	span.SetAttributes(attribute.Int("jwks_clusters", len(jwtAuthClusters)), attribute.Int("proxy_clusters", len(proxies)))
+
 	return clusters, nil
 }
 
-const (
-	envoyTrustBundle = "/etc/ssl/certs/ca-certificates.crt"
-)
+// buildURLCluster returns the cluster reaching the upstream of the URL; a STATIC cluster for IP literals,
+// a STRICT_DNS one otherwise, with a TLS transport socket only for https. The hostname is resolved with
+// the DNS lookup family of the cluster, if any, or the given default one. The clusters reached through a
+// proxy connect to their tunnel internal listener instead.
+func buildURLCluster(c *urlCluster, defaultDNSLookupFamily clusterv3.Cluster_DnsLookupFamily) (*clusterv3.Cluster, error) {
+	clusterName := c.CustomName()
+
+	address := &corev3.Address{
+		Address: &corev3.Address_SocketAddress{
+			SocketAddress: &corev3.SocketAddress{
+				Address: c.hostname,
+				PortSpecifier: &corev3.SocketAddress_PortValue{
+					PortValue: c.port,
+				},
+				Protocol: corev3.SocketAddress_TCP,
+			},
+		},
+	}
+	if c.proxy != nil {
+		address = buildInternalAddress(tunnelListenerName(c))
+	}
 
-func buildXdsUpstreamTLSSocket(sni string) *tlsv3.UpstreamTlsContext {
-	return &tlsv3.UpstreamTlsContext{
-		Sni: sni,
-		CommonTlsContext: &tlsv3.CommonTlsContext{
-			ValidationContextType: &tlsv3.CommonTlsContext_ValidationContext{
-				ValidationContext: &tlsv3.CertificateValidationContext{
-					TrustedCa: &corev3.DataSource{
-						Specifier: &corev3.DataSource_Filename{
-							Filename: envoyTrustBundle,
+	cluster := &clusterv3.Cluster{
+		Name:           clusterName,
+		ConnectTimeout: &durationpb.Duration{Seconds: 2},
+		LoadAssignment: &endpointv3.ClusterLoadAssignment{
+			ClusterName: clusterName,
+			Endpoints: []*endpointv3.LocalityLbEndpoints{{
+				LbEndpoints: []*endpointv3.LbEndpoint{{
+					HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
+						Endpoint: &endpointv3.Endpoint{
+							Address: address,
 						},
 					},
-				},
-			},
+				}},
+			}},
 		},
 	}
+
+	switch {
+	case c.endpointType == EndpointTypeStatic || c.proxy != nil:
+		cluster.ClusterDiscoveryType = &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STATIC}
+	default:
+		cluster.ClusterDiscoveryType = &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STRICT_DNS}
+		cluster.DnsLookupFamily = ptr.Deref(c.dnsLookupFamily, defaultDNSLookupFamily)
+	}
+
+	if !c.tls {
+		return cluster, nil
+	}
+
+	trCtx, err := newAny(buildXdsUpstreamTLSSocket(c))
+	if err != nil {
+		return nil, err
+	}
+
+	cluster.TransportSocket = &corev3.TransportSocket{
+		Name: wellknown.TransportSocketTls,
+		ConfigType: &corev3.TransportSocket_TypedConfig{
+			TypedConfig: trCtx,
+		},
+	}
+
+	return cluster, nil
 }
@@ -0,0 +1,113 @@
+package extensions
+
+import (
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/utils/ptr"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+)
+
+func testLoadAssignment(clusterName string, address string, port uint32) *endpointv3.ClusterLoadAssignment {
+	return &endpointv3.ClusterLoadAssignment{
+		ClusterName: clusterName,
+		Endpoints: []*endpointv3.LocalityLbEndpoints{{
+			LbEndpoints: []*endpointv3.LbEndpoint{{
+				HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
+					Endpoint: &endpointv3.Endpoint{
+						Address: &corev3.Address{
+							Address: &corev3.Address_SocketAddress{
+								SocketAddress: &corev3.SocketAddress{
+									Address:       address,
+									PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: port},
+									Protocol:      corev3.SocketAddress_TCP,
+								},
+							},
+						},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func TestBuildURLCluster(t *testing.T) {
+	tests := []struct {
+		name   string
+		strURL string
+		family *clusterv3.Cluster_DnsLookupFamily
+		want   *clusterv3.Cluster
+	}{
+		{
+			name:   "Plain HTTP in-cluster IdP",
+			strURL: "http://keycloak.idp:8080/realms/kms/protocol/openid-connect/certs",
+			want: &clusterv3.Cluster{
+				Name:                 "keycloak_idp_8080_http|openkcm",
+				ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STRICT_DNS},
+				ConnectTimeout:       &durationpb.Duration{Seconds: 2},
+				DnsLookupFamily:      clusterv3.Cluster_V4_ONLY,
+				LoadAssignment:       testLoadAssignment("keycloak_idp_8080_http|openkcm", "keycloak.idp", 8080),
+			},
+		},
+		{
+			name:   "Dual-stack hostname",
+			strURL: "http://keycloak.idp:8080/certs",
+			family: ptr.To(clusterv3.Cluster_ALL),
+			want: &clusterv3.Cluster{
+				Name:                 "keycloak_idp_8080_http|openkcm",
+				ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STRICT_DNS},
+				ConnectTimeout:       &durationpb.Duration{Seconds: 2},
+				DnsLookupFamily:      clusterv3.Cluster_ALL,
+				LoadAssignment:       testLoadAssignment("keycloak_idp_8080_http|openkcm", "keycloak.idp", 8080),
+			},
+		},
+		{
+			name:   "Plain HTTP IPv6 literal",
+			strURL: "http://[fd00::10]:8080/certs",
+			want: &clusterv3.Cluster{
+				Name:                 "fd00__10_8080_http|openkcm",
+				ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STATIC},
+				ConnectTimeout:       &durationpb.Duration{Seconds: 2},
+				LoadAssignment:       testLoadAssignment("fd00__10_8080_http|openkcm", "fd00::10", 8080),
+			},
+		},
+		{
+			name:   "HTTPS IP literal",
+			strURL: "https://10.0.0.1:8443/jwks",
+			want: &clusterv3.Cluster{
+				Name:                 "10_0_0_1_8443|openkcm",
+				ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STATIC},
+				ConnectTimeout:       &durationpb.Duration{Seconds: 2},
+				LoadAssignment:       testLoadAssignment("10_0_0_1_8443|openkcm", "10.0.0.1", 8443),
+				TransportSocket: &corev3.TransportSocket{
+					Name: wellknown.TransportSocketTls,
+					ConfigType: &corev3.TransportSocket_TypedConfig{
+						TypedConfig: mustNewAny(buildXdsUpstreamTLSSocket(&urlCluster{hostname: "10.0.0.1"})),
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := url2Cluster(tt.strURL)
+			assert.NoError(t, err)
+
+			// The cluster keeps its name, so the expectations do not depend on the hash of the lookup family.
+			c.dnsLookupFamily = tt.family
+
+			got, err := buildURLCluster(c, clusterv3.Cluster_V4_ONLY)
+			assert.NoError(t, err)
+
+			diff := cmp.Diff(tt.want, got, protocmp.Transform())
+			assert.Empty(t, diff)
+		})
+	}
+}
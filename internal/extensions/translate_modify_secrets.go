@@ -0,0 +1,66 @@
+package extensions
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	slogctx "github.com/veqryn/slog-context"
+
+	"github.com/openkcm/gateway-extension/internal/flags"
+)
+
+func cleanUpSecrets(scs []*tlsv3.Secret) []*tlsv3.Secret {
+	secrets := make([]*tlsv3.Secret, 0, len(scs))
+
+	// remove secrets that has as suffix name `openkcm`,
+	for _, sc := range scs {
+		if IsCustomName(sc.GetName()) {
+			continue
+		}
+
+		secrets = append(secrets, sc)
+	}
+
+	return secrets
+}
+
+// TranslateModifySecrets returns the secrets with the client certificates of the JWKS clusters.
+func (s *GatewayExtension) TranslateModifySecrets(ctx context.Context, scs []*tlsv3.Secret) []*tlsv3.Secret {
+	s.jwtAuthClustersMu.RLock()
+	defer s.jwtAuthClustersMu.RUnlock()
+
+	if s.features.IsFeatureEnabled(flags.DisableJWTProviderComputation) {
+		return cleanUpSecrets(scs)
+	}
+
+	jwtAuthClusters := s.referencedJWTAuthClusters()
+	if len(jwtAuthClusters) == 0 {
+		return scs
+	}
+
+	secrets := cleanUpSecrets(scs)
+
+	// The certificates shared by several clusters get a single secret
+	added := make(map[string]struct{})
+
+	for _, name := range slices.Sorted(maps.Keys(jwtAuthClusters)) {
+		v := jwtAuthClusters[name]
+
+		if v.clientCertificate == nil {
+			continue
+		}
+
+		if _, ok := added[v.clientCertificate.secretName]; ok {
+			continue
+		}
+
+		slogctx.Info(ctx, "Processing client certificate secret", "name", v.clientCertificate.secretName)
+
+		added[v.clientCertificate.secretName] = struct{}{}
+		secrets = append(secrets, buildClientCertificateSecret(v.clientCertificate))
+	}
+
+	return secrets
+}
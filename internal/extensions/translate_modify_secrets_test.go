@@ -0,0 +1,89 @@
+package extensions
+
+import (
+	"testing"
+
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+)
+
+func TestGatewayExtension_TranslateModifySecrets(t *testing.T) {
+	cert := &clientCertificate{
+		secretName:       "jwks_client_kms_client|openkcm",
+		certificateChain: []byte("chain"),
+		privateKey:       []byte("key"),
+	}
+
+	idp, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+	idp.setClientCertificate(cert)
+
+	other, err := url2Cluster("https://other.example.com/jwks")
+	assert.NoError(t, err)
+	other.setClientCertificate(cert)
+
+	plain, err := url2Cluster("https://plain.example.com/jwks")
+	assert.NoError(t, err)
+
+	gatewaySecret := &tlsv3.Secret{Name: "kms/gateway-tls"}
+	staleSecret := &tlsv3.Secret{Name: "jwks_client_kms_stale|openkcm"}
+
+	tests := []struct {
+		name     string
+		clusters []*urlCluster
+		secrets  []*tlsv3.Secret
+		want     []string
+	}{
+		{
+			name:    "No clusters",
+			secrets: []*tlsv3.Secret{gatewaySecret},
+			want:    []string{"kms/gateway-tls"},
+		},
+		{
+			name:     "Clusters without client certificate",
+			clusters: []*urlCluster{plain},
+			secrets:  []*tlsv3.Secret{gatewaySecret, staleSecret},
+			want:     []string{"kms/gateway-tls"},
+		},
+		{
+			name:     "Shared client certificate",
+			clusters: []*urlCluster{idp, other, plain},
+			secrets:  []*tlsv3.Secret{gatewaySecret, staleSecret},
+			want:     []string{"kms/gateway-tls", "jwks_client_kms_client|openkcm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := make(map[string]*urlCluster)
+			for _, c := range tt.clusters {
+				clusters[c.name] = c
+			}
+
+			s := NewGatewayExtension(&commoncfg.FeatureGates{})
+			s.setListenerJWTAuthClusters("kms/gateway/https", clusters)
+
+			got := s.TranslateModifySecrets(t.Context(), tt.secrets)
+
+			names := make([]string, 0, len(got))
+			for _, sc := range got {
+				names = append(names, sc.GetName())
+			}
+
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestBuildClientCertificateSecret(t *testing.T) {
+	got := buildClientCertificateSecret(&clientCertificate{
+		secretName:       "jwks_client_kms_client|openkcm",
+		certificateChain: []byte("chain"),
+		privateKey:       []byte("key"),
+	})
+
+	assert.Equal(t, "jwks_client_kms_client|openkcm", got.GetName())
+	assert.Equal(t, []byte("chain"), got.GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+	assert.Equal(t, []byte("key"), got.GetTlsCertificate().GetPrivateKey().GetInlineBytes())
+}
@@ -0,0 +1,326 @@
+package extensions
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"k8s.io/apimachinery/pkg/types"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	corev1 "k8s.io/api/core/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const (
+	envoyTrustBundle = "/etc/ssl/certs/ca-certificates.crt"
+
+	// defaultCACertificateKey is the key of the CA bundle in the ConfigMaps and Secrets, as used by the BackendTLSPolicies.
+	defaultCACertificateKey = "ca.crt"
+
+	clientCertificateSecretPrefix = "jwks_client_"
+)
+
+var (
+	ErrInvalidCACertificate       = errors.New("invalid CA certificate")
+	ErrInvalidClientCertificate   = errors.New("invalid client certificate")
+	ErrPlainHTTPClientCertificate = errors.New("client certificate configured for a plain HTTP JWKS server")
+)
+
+// upstreamValidation is the validation of the certificate of a TLS upstream.
+type upstreamValidation struct {
+	// trustedCA is the PEM encoded CA bundle, empty to use Envoy's system trust bundle.
+	trustedCA string
+	// subjectAltNames are the names the certificate must match, the upstream hostname if empty.
+	subjectAltNames []subjectAltName
+}
+
+// subjectAltName is a subject alternative name of a given type.
+type subjectAltName struct {
+	sanType tlsv3.SubjectAltNameMatcher_SanType
+	value   string
+}
+
+// clientCertificate is the client certificate presented to a TLS upstream, sent to Envoy as an SDS secret.
+type clientCertificate struct {
+	// secretName is the name of the SDS secret.
+	secretName       string
+	certificateChain []byte
+	privateKey       []byte
+}
+
+// resolveUpstreamValidation returns the validation of the JWKS server certificate configured by the TLS
+// settings. Referenced resources are read from the given namespace. A nil validation is returned when
+// Envoy's system trust bundle has to be used for the JWKS hostname.
+func (s *GatewayExtension) resolveUpstreamValidation(ctx context.Context, namespace string, tls *v1alpha1.RemoteJWKSTLS) (*upstreamValidation, error) {
+	switch {
+	case tls == nil:
+		return nil, nil
+	case tls.CACertificateRef != nil:
+		key := tls.CACertificateRef.Key
+		if key == "" {
+			key = defaultCACertificateKey
+		}
+
+		ca, err := s.readValueRef(ctx, tls.CACertificateRef.Kind, types.NamespacedName{Namespace: namespace, Name: tls.CACertificateRef.Name}, key)
+		if err != nil {
+			return nil, err
+		}
+
+		err = validateCACertificate(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		return &upstreamValidation{trustedCA: ca}, nil
+	case tls.BackendTLSPolicyRef != nil:
+		return s.resolveBackendTLSPolicyValidation(ctx, types.NamespacedName{Namespace: namespace, Name: tls.BackendTLSPolicyRef.Name})
+	default:
+		return nil, nil
+	}
+}
+
+// resolveBackendTLSPolicyValidation returns the validation defined by a BackendTLSPolicy.
+func (s *GatewayExtension) resolveBackendTLSPolicyValidation(ctx context.Context, name types.NamespacedName) (*upstreamValidation, error) {
+	if s.kubeClient == nil {
+		return nil, ErrNoKubernetesClient
+	}
+
+	policy := &gwapiv1.BackendTLSPolicy{}
+
+	err := s.kubeClient.Get(ctx, name, policy)
+	if err != nil {
+		return nil, fmt.Errorf("could not get BackendTLSPolicy %s: %w", name, err)
+	}
+
+	validation := &upstreamValidation{}
+
+	for _, san := range policy.Spec.Validation.SubjectAltNames {
+		switch san.Type {
+		case gwapiv1.HostnameSubjectAltNameType:
+			validation.subjectAltNames = append(validation.subjectAltNames,
+				subjectAltName{sanType: tlsv3.SubjectAltNameMatcher_DNS, value: string(san.Hostname)})
+		case gwapiv1.URISubjectAltNameType:
+			validation.subjectAltNames = append(validation.subjectAltNames,
+				subjectAltName{sanType: tlsv3.SubjectAltNameMatcher_URI, value: string(san.URI)})
+		}
+	}
+
+	if policy.Spec.Validation.WellKnownCACertificates != nil {
+		return validation, nil
+	}
+
+	bundle := make([]string, 0, len(policy.Spec.Validation.CACertificateRefs))
+
+	for _, ref := range policy.Spec.Validation.CACertificateRefs {
+		if ref.Group != "" {
+			return nil, fmt.Errorf("unsupported CA certificate reference group %s in BackendTLSPolicy %s", ref.Group, name)
+		}
+
+		ca, err := s.readValueRef(ctx, string(ref.Kind), types.NamespacedName{Namespace: name.Namespace, Name: string(ref.Name)}, defaultCACertificateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		err = validateCACertificate(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		bundle = append(bundle, strings.TrimSpace(ca))
+	}
+
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("%w: no CA certificate in BackendTLSPolicy %s", ErrInvalidCACertificate, name)
+	}
+
+	validation.trustedCA = strings.Join(bundle, "\n") + "\n"
+
+	return validation, nil
+}
+
+// resolveClientCertificate returns the client certificate configured by the TLS settings, held by a
+// kubernetes.io/tls Secret of the given namespace. A nil certificate is returned when no client
+// certificate is configured.
+func (s *GatewayExtension) resolveClientCertificate(ctx context.Context, namespace string, settings *v1alpha1.RemoteJWKSTLS) (*clientCertificate, error) {
+	if settings == nil || settings.ClientCertificateRef == nil {
+		return nil, nil
+	}
+
+	if s.kubeClient == nil {
+		return nil, ErrNoKubernetesClient
+	}
+
+	name := types.NamespacedName{Namespace: namespace, Name: settings.ClientCertificateRef.Name}
+	secret := &corev1.Secret{}
+
+	err := s.kubeClient.Get(ctx, name, secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not get Secret %s: %w", name, err)
+	}
+
+	cert := &clientCertificate{
+		secretName:       clientCertificateSecretName(name),
+		certificateChain: secret.Data[corev1.TLSCertKey],
+		privateKey:       secret.Data[corev1.TLSPrivateKeyKey],
+	}
+
+	err = cert.validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w in Secret %s", err, name)
+	}
+
+	return cert, nil
+}
+
+// clientCertificateSecretName returns the name of the SDS secret of the client certificate held by a Secret.
+func clientCertificateSecretName(name types.NamespacedName) string {
+	return fmt.Sprintf("%s%s_%s|%s", clientCertificateSecretPrefix, name.Namespace, name.Name, customSuffixName)
+}
+
+// validate checks that the certificate chain and the private key are a matching PEM encoded pair.
+func (c *clientCertificate) validate() error {
+	_, err := tls.X509KeyPair(c.certificateChain, c.privateKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidClientCertificate, err)
+	}
+
+	return nil
+}
+
+// buildClientCertificateSecret returns the SDS secret of the client certificate.
+func buildClientCertificateSecret(c *clientCertificate) *tlsv3.Secret {
+	return &tlsv3.Secret{
+		Name: c.secretName,
+		Type: &tlsv3.Secret_TlsCertificate{
+			TlsCertificate: &tlsv3.TlsCertificate{
+				CertificateChain: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineBytes{InlineBytes: c.certificateChain},
+				},
+				PrivateKey: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineBytes{InlineBytes: c.privateKey},
+				},
+			},
+		},
+	}
+}
+
+// validateCACertificate checks that the bundle holds at least one PEM encoded certificate.
+func validateCACertificate(bundle string) error {
+	rest := []byte(bundle)
+
+	for {
+		block, remaining := pem.Decode(rest)
+		if block == nil {
+			return fmt.Errorf("%w: no PEM encoded certificate", ErrInvalidCACertificate)
+		}
+
+		if block.Type == "CERTIFICATE" {
+			return nil
+		}
+
+		rest = remaining
+	}
+}
+
+// writeTo writes the validation settings, to tell apart the clusters of a same upstream.
+func (v *upstreamValidation) writeTo(w io.Writer) {
+	_, _ = io.WriteString(w, v.trustedCA)
+
+	for _, san := range v.subjectAltNames {
+		_, _ = fmt.Fprintf(w, "|%d:%s", san.sanType, san.value)
+	}
+}
+
+// buildXdsUpstreamTLSSocket returns the TLS context of the cluster, validating the upstream certificate
+// against the cluster trust bundle and subject alternative names, and presenting the cluster client
+// certificate, if any.
+func buildXdsUpstreamTLSSocket(c *urlCluster) *tlsv3.UpstreamTlsContext {
+	trustedCA := &corev3.DataSource{
+		Specifier: &corev3.DataSource_Filename{
+			Filename: envoyTrustBundle,
+		},
+	}
+
+	var subjectAltNames []subjectAltName
+
+	if c.validation != nil {
+		if c.validation.trustedCA != "" {
+			trustedCA = &corev3.DataSource{
+				Specifier: &corev3.DataSource_InlineString{
+					InlineString: c.validation.trustedCA,
+				},
+			}
+		}
+
+		subjectAltNames = c.validation.subjectAltNames
+	}
+
+	if len(subjectAltNames) == 0 {
+		subjectAltNames = []subjectAltName{hostnameSubjectAltName(c.hostname)}
+	}
+
+	tlsContext := &tlsv3.UpstreamTlsContext{
+		Sni: c.hostname,
+		CommonTlsContext: &tlsv3.CommonTlsContext{
+			ValidationContextType: &tlsv3.CommonTlsContext_ValidationContext{
+				ValidationContext: &tlsv3.CertificateValidationContext{
+					TrustedCa:                 trustedCA,
+					MatchTypedSubjectAltNames: buildSubjectAltNameMatchers(subjectAltNames),
+				},
+			},
+		},
+	}
+
+	// The client certificate is fetched over ADS, with the secrets of the translation.
+	if c.clientCertificate != nil {
+		tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs = []*tlsv3.SdsSecretConfig{{
+			Name: c.clientCertificate.secretName,
+			SdsConfig: &corev3.ConfigSource{
+				ResourceApiVersion: resource.DefaultAPIVersion,
+				ConfigSourceSpecifier: &corev3.ConfigSource_Ads{
+					Ads: &corev3.AggregatedConfigSource{},
+				},
+			},
+		}}
+	}
+
+	return tlsContext
+}
+
+// hostnameSubjectAltName returns the subject alternative name matching the hostname, an IP address
+// for IP literals.
+func hostnameSubjectAltName(hostname string) subjectAltName {
+	_, err := netip.ParseAddr(hostname)
+	if err == nil {
+		return subjectAltName{sanType: tlsv3.SubjectAltNameMatcher_IP_ADDRESS, value: hostname}
+	}
+
+	return subjectAltName{sanType: tlsv3.SubjectAltNameMatcher_DNS, value: hostname}
+}
+
+// buildSubjectAltNameMatchers returns the exact matchers of the subject alternative names.
+func buildSubjectAltNameMatchers(sans []subjectAltName) []*tlsv3.SubjectAltNameMatcher {
+	matchers := make([]*tlsv3.SubjectAltNameMatcher, 0, len(sans))
+
+	for _, san := range sans {
+		matchers = append(matchers, &tlsv3.SubjectAltNameMatcher{
+			SanType: san.sanType,
+			Matcher: &matcherv3.StringMatcher{
+				MatchPattern: &matcherv3.StringMatcher_Exact{Exact: san.value},
+			},
+		})
+	}
+
+	return matchers
+}
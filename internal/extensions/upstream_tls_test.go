@@ -0,0 +1,374 @@
+package extensions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+const (
+	testCACertificate      = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	testOtherCACertificate = "-----BEGIN CERTIFICATE-----\nMIIC\n-----END CERTIFICATE-----\n"
+)
+
+// testClientCertificate returns a PEM encoded self-signed certificate and its private key.
+func testClientCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gateway"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestGatewayExtension_resolveUpstreamValidation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, gwapiv1.AddToScheme(scheme))
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "pki"},
+			Data:       map[string]string{defaultCACertificateKey: testCACertificate, "invalid": "not a certificate"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "pki"},
+			Data:       map[string][]byte{defaultCACertificateKey: []byte(testOtherCACertificate)},
+		},
+		&gwapiv1.BackendTLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "idp"},
+			Spec: gwapiv1.BackendTLSPolicySpec{
+				Validation: gwapiv1.BackendTLSPolicyValidation{
+					CACertificateRefs: []gwapiv1.LocalObjectReference{
+						{Kind: "ConfigMap", Name: "pki"},
+						{Kind: "Secret", Name: "pki"},
+					},
+					Hostname: "idp.example.com",
+					SubjectAltNames: []gwapiv1.SubjectAltName{
+						{Type: gwapiv1.HostnameSubjectAltNameType, Hostname: "idp.example.com"},
+						{Type: gwapiv1.URISubjectAltNameType, URI: "spiffe://example.com/idp"},
+					},
+				},
+			},
+		},
+		&gwapiv1.BackendTLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "system"},
+			Spec: gwapiv1.BackendTLSPolicySpec{
+				Validation: gwapiv1.BackendTLSPolicyValidation{
+					WellKnownCACertificates: ptr.To(gwapiv1.WellKnownCACertificatesSystem),
+					Hostname:                "idp.example.com",
+				},
+			},
+		},
+	).Build()
+
+	tests := []struct {
+		name    string
+		tls     *v1alpha1.RemoteJWKSTLS
+		want    *upstreamValidation
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "No TLS settings",
+			want:    nil,
+			wantErr: assert.NoError,
+		},
+		{
+			name: "CA certificate from a ConfigMap",
+			tls: &v1alpha1.RemoteJWKSTLS{
+				CACertificateRef: &v1alpha1.CACertificateRef{Kind: "ConfigMap", Name: "pki"},
+			},
+			want:    &upstreamValidation{trustedCA: testCACertificate},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Invalid CA certificate",
+			tls: &v1alpha1.RemoteJWKSTLS{
+				CACertificateRef: &v1alpha1.CACertificateRef{Kind: "ConfigMap", Name: "pki", Key: "invalid"},
+			},
+			wantErr: func(t assert.TestingT, err error, i ...any) bool {
+				return assert.ErrorIs(t, err, ErrInvalidCACertificate, i...)
+			},
+		},
+		{
+			name: "BackendTLSPolicy with CA certificates",
+			tls: &v1alpha1.RemoteJWKSTLS{
+				BackendTLSPolicyRef: &v1alpha1.BackendTLSPolicyRef{Name: "idp"},
+			},
+			want: &upstreamValidation{
+				trustedCA: testCACertificate + testOtherCACertificate,
+				subjectAltNames: []subjectAltName{
+					{sanType: tlsv3.SubjectAltNameMatcher_DNS, value: "idp.example.com"},
+					{sanType: tlsv3.SubjectAltNameMatcher_URI, value: "spiffe://example.com/idp"},
+				},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "BackendTLSPolicy with system CA certificates",
+			tls: &v1alpha1.RemoteJWKSTLS{
+				BackendTLSPolicyRef: &v1alpha1.BackendTLSPolicyRef{Name: "system"},
+			},
+			want:    &upstreamValidation{},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Missing BackendTLSPolicy",
+			tls: &v1alpha1.RemoteJWKSTLS{
+				BackendTLSPolicyRef: &v1alpha1.BackendTLSPolicyRef{Name: "missing"},
+			},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GatewayExtension{kubeClient: kubeClient}
+
+			got, err := s.resolveUpstreamValidation(t.Context(), "kms", tt.tls)
+			if !tt.wantErr(t, err) {
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuildXdsUpstreamTLSSocket(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster *urlCluster
+		want    *tlsv3.CertificateValidationContext
+	}{
+		{
+			name:    "System trust bundle",
+			cluster: &urlCluster{hostname: "idp.example.com"},
+			want: &tlsv3.CertificateValidationContext{
+				TrustedCa: &corev3.DataSource{Specifier: &corev3.DataSource_Filename{Filename: envoyTrustBundle}},
+				MatchTypedSubjectAltNames: []*tlsv3.SubjectAltNameMatcher{{
+					SanType: tlsv3.SubjectAltNameMatcher_DNS,
+					Matcher: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "idp.example.com"}},
+				}},
+			},
+		},
+		{
+			name: "Custom CA of an IP literal",
+			cluster: &urlCluster{
+				hostname:   "10.0.0.1",
+				validation: &upstreamValidation{trustedCA: testCACertificate},
+			},
+			want: &tlsv3.CertificateValidationContext{
+				TrustedCa: &corev3.DataSource{Specifier: &corev3.DataSource_InlineString{InlineString: testCACertificate}},
+				MatchTypedSubjectAltNames: []*tlsv3.SubjectAltNameMatcher{{
+					SanType: tlsv3.SubjectAltNameMatcher_IP_ADDRESS,
+					Matcher: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "10.0.0.1"}},
+				}},
+			},
+		},
+		{
+			name: "Custom subject alternative names",
+			cluster: &urlCluster{
+				hostname: "idp.example.com",
+				validation: &upstreamValidation{subjectAltNames: []subjectAltName{
+					{sanType: tlsv3.SubjectAltNameMatcher_URI, value: "spiffe://example.com/idp"},
+				}},
+			},
+			want: &tlsv3.CertificateValidationContext{
+				TrustedCa: &corev3.DataSource{Specifier: &corev3.DataSource_Filename{Filename: envoyTrustBundle}},
+				MatchTypedSubjectAltNames: []*tlsv3.SubjectAltNameMatcher{{
+					SanType: tlsv3.SubjectAltNameMatcher_URI,
+					Matcher: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "spiffe://example.com/idp"}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildXdsUpstreamTLSSocket(tt.cluster)
+
+			assert.Equal(t, tt.cluster.hostname, got.GetSni())
+
+			diff := cmp.Diff(tt.want, got.GetCommonTlsContext().GetValidationContext(), protocmp.Transform())
+			assert.Empty(t, diff)
+		})
+	}
+}
+
+func TestUrlCluster_setValidation(t *testing.T) {
+	cluster, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	cluster.setValidation(nil)
+	assert.Equal(t, "idp_example_com_443", cluster.name)
+
+	other, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	cluster.setValidation(&upstreamValidation{trustedCA: testCACertificate})
+	other.setValidation(&upstreamValidation{trustedCA: testOtherCACertificate})
+
+	assert.Regexp(t, `^idp_example_com_443_[0-9a-f]{8}$`, cluster.name)
+	assert.NotEqual(t, cluster.name, other.name)
+}
+
+func TestGatewayExtension_resolveClientCertificate(t *testing.T) {
+	cert, key := testClientCertificate(t)
+	_, otherKey := testClientCertificate(t)
+
+	kubeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "client"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: cert, corev1.TLSPrivateKeyKey: key},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "mismatch"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: cert, corev1.TLSPrivateKeyKey: otherKey},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kms", Name: "empty"},
+		},
+	).Build()
+
+	tests := []struct {
+		name    string
+		tls     *v1alpha1.RemoteJWKSTLS
+		want    *clientCertificate
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "No TLS settings",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "No client certificate",
+			tls:     &v1alpha1.RemoteJWKSTLS{CACertificateRef: &v1alpha1.CACertificateRef{Kind: "Secret", Name: "pki"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Client certificate",
+			tls:  &v1alpha1.RemoteJWKSTLS{ClientCertificateRef: &v1alpha1.ClientCertificateRef{Name: "client"}},
+			want: &clientCertificate{
+				secretName:       "jwks_client_kms_client|openkcm",
+				certificateChain: cert,
+				privateKey:       key,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Mismatching private key",
+			tls:  &v1alpha1.RemoteJWKSTLS{ClientCertificateRef: &v1alpha1.ClientCertificateRef{Name: "mismatch"}},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrInvalidClientCertificate)
+			},
+		},
+		{
+			name: "Empty Secret",
+			tls:  &v1alpha1.RemoteJWKSTLS{ClientCertificateRef: &v1alpha1.ClientCertificateRef{Name: "empty"}},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrInvalidClientCertificate)
+			},
+		},
+		{
+			name:    "Missing Secret",
+			tls:     &v1alpha1.RemoteJWKSTLS{ClientCertificateRef: &v1alpha1.ClientCertificateRef{Name: "missing"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GatewayExtension{kubeClient: kubeClient}
+
+			got, err := s.resolveClientCertificate(t.Context(), "kms", tt.tls)
+			if !tt.wantErr(t, err) {
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuildXdsUpstreamTLSSocket_ClientCertificate(t *testing.T) {
+	got := buildXdsUpstreamTLSSocket(&urlCluster{hostname: "idp.example.com"})
+	assert.Empty(t, got.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs())
+
+	got = buildXdsUpstreamTLSSocket(&urlCluster{
+		hostname:          "idp.example.com",
+		clientCertificate: &clientCertificate{secretName: "jwks_client_kms_client|openkcm"},
+	})
+
+	want := []*tlsv3.SdsSecretConfig{{
+		Name: "jwks_client_kms_client|openkcm",
+		SdsConfig: &corev3.ConfigSource{
+			ResourceApiVersion:    resource.DefaultAPIVersion,
+			ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+		},
+	}}
+
+	diff := cmp.Diff(want, got.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs(), protocmp.Transform())
+	assert.Empty(t, diff)
+}
+
+func TestUrlCluster_setClientCertificate(t *testing.T) {
+	cluster, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	cluster.setClientCertificate(nil)
+	assert.Equal(t, "idp_example_com_443", cluster.name)
+
+	other, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	cluster.setClientCertificate(&clientCertificate{secretName: "jwks_client_kms_client|openkcm"})
+	other.setClientCertificate(&clientCertificate{secretName: "jwks_client_kms_other|openkcm"})
+
+	assert.Regexp(t, `^idp_example_com_443_[0-9a-f]{8}$`, cluster.name)
+	assert.NotEqual(t, cluster.name, other.name)
+}
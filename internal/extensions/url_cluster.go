@@ -1,11 +1,17 @@
 package extensions
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/netip"
 	"net/url"
 	"strconv"
 	"strings"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
 )
 
 const (
@@ -24,6 +30,32 @@ const (
 	customSuffixName = "openkcm"
 )
 
+var (
+	ErrInvalidDNSLookupFamily = errors.New("invalid DNS lookup family")
+
+	dnsLookupFamilies = map[v1alpha1.DNSLookupFamily]clusterv3.Cluster_DnsLookupFamily{
+		v1alpha1.DNSLookupFamilyV4Only:      clusterv3.Cluster_V4_ONLY,
+		v1alpha1.DNSLookupFamilyV4Preferred: clusterv3.Cluster_V4_PREFERRED,
+		v1alpha1.DNSLookupFamilyV6Only:      clusterv3.Cluster_V6_ONLY,
+		v1alpha1.DNSLookupFamilyAll:         clusterv3.Cluster_ALL,
+	}
+)
+
+// ParseDNSLookupFamily returns the cluster DNS lookup family; one of V4_ONLY, V4_PREFERRED, V6_ONLY or ALL.
+// An empty family defaults to V4_ONLY.
+func ParseDNSLookupFamily(family string) (clusterv3.Cluster_DnsLookupFamily, error) {
+	if family == "" {
+		return clusterv3.Cluster_V4_ONLY, nil
+	}
+
+	f, ok := dnsLookupFamilies[v1alpha1.DNSLookupFamily(family)]
+	if !ok {
+		return clusterv3.Cluster_V4_ONLY, fmt.Errorf("%w: %q", ErrInvalidDNSLookupFamily, family)
+	}
+
+	return f, nil
+}
+
 // urlCluster is a cluster that is created from a URL.
 type urlCluster struct {
 	name         string
@@ -31,6 +63,14 @@ type urlCluster struct {
 	port         uint32
 	endpointType EndpointType
 	tls          bool
+	// validation is the custom validation of the upstream certificate, nil for the defaults.
+	validation *upstreamValidation
+	// dnsLookupFamily is the DNS lookup family of the hostname, nil for the extension default.
+	dnsLookupFamily *clusterv3.Cluster_DnsLookupFamily
+	// proxy is the cluster of the HTTP proxy the upstream is reached through, nil for direct connections.
+	proxy *urlCluster
+	// clientCertificate is the certificate presented to the upstream, nil for no client authentication.
+	clientCertificate *clientCertificate
 }
 
 // url2Cluster returns a urlCluster from the provided url.
@@ -57,13 +97,12 @@ func url2Cluster(strURL string) (*urlCluster, error) {
 		}
 	}
 
-	name := clusterName(u.Hostname(), uint32(port))
+	name := clusterName(u.Hostname(), uint32(port), u.Scheme == "https")
 
-	ip, err := netip.ParseAddr(u.Hostname())
+	// IPv6 literals are bracketed in the URL, but not in its hostname.
+	_, err = netip.ParseAddr(u.Hostname())
 	if err == nil {
-		if ip.Unmap().Is4() {
-			epType = EndpointTypeStatic
-		}
+		epType = EndpointTypeStatic
 	}
 
 	return &urlCluster{
@@ -75,8 +114,69 @@ func url2Cluster(strURL string) (*urlCluster, error) {
 	}, nil
 }
 
-func clusterName(host string, port uint32) string {
-	return fmt.Sprintf("%s_%d", strings.ReplaceAll(host, ".", "_"), port)
+// setValidation sets the custom validation of the upstream certificate.
+func (c *urlCluster) setValidation(validation *upstreamValidation) {
+	if validation == nil {
+		return
+	}
+
+	c.validation = validation
+	c.rename()
+}
+
+// setDNSLookupFamily sets the DNS lookup family of the hostname, instead of the extension default.
+func (c *urlCluster) setDNSLookupFamily(family *clusterv3.Cluster_DnsLookupFamily) {
+	if family == nil {
+		return
+	}
+
+	c.dnsLookupFamily = family
+	c.rename()
+}
+
+// setClientCertificate sets the client certificate presented to the upstream.
+func (c *urlCluster) setClientCertificate(cert *clientCertificate) {
+	if cert == nil {
+		return
+	}
+
+	c.clientCertificate = cert
+	c.rename()
+}
+
+// rename suffixes the cluster name with a short hash of its custom settings, so the upstreams reached
+// differently get their own clusters.
+func (c *urlCluster) rename() {
+	h := fnv.New32a()
+
+	if c.validation != nil {
+		c.validation.writeTo(h)
+	}
+
+	if c.dnsLookupFamily != nil {
+		_, _ = fmt.Fprintf(h, "|family:%s", c.dnsLookupFamily)
+	}
+
+	if c.proxy != nil {
+		_, _ = fmt.Fprintf(h, "|proxy:%s", c.proxy.name)
+	}
+
+	if c.clientCertificate != nil {
+		_, _ = fmt.Fprintf(h, "|client:%s", c.clientCertificate.secretName)
+	}
+
+	c.name = fmt.Sprintf("%s_%08x", clusterName(c.hostname, c.port, c.tls), h.Sum32())
+}
+
+// clusterName returns the name of the cluster of an upstream. The plain HTTP upstreams are suffixed, so
+// they do not share the cluster of a TLS upstream listening on the same port.
+func clusterName(host string, port uint32, tls bool) string {
+	name := fmt.Sprintf("%s_%d", strings.NewReplacer(".", "_", ":", "_").Replace(host), port)
+	if !tls {
+		name += "_http"
+	}
+
+	return name
 }
 
 func (c *urlCluster) CustomName() string {
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 )
 
 func TestIsCustomName(t *testing.T) {
@@ -62,11 +65,23 @@ func Test_url2Cluster(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name:   "Bracketed IPv6",
+			strURL: "https://[2001:db8::1]:8443/jwks",
+			want: &urlCluster{
+				name:         "2001_db8__1_8443",
+				hostname:     "2001:db8::1",
+				port:         8443,
+				endpointType: EndpointTypeStatic,
+				tls:          true,
+			},
+			wantErr: assert.NoError,
+		},
 		{
 			name:   "No TLS",
 			strURL: "http://example.com/jwks",
 			want: &urlCluster{
-				name:         "example_com_80",
+				name:         "example_com_80_http",
 				hostname:     "example.com",
 				port:         80,
 				endpointType: EndpointTypeDNS,
@@ -85,3 +100,38 @@ func Test_url2Cluster(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDNSLookupFamily(t *testing.T) {
+	family, err := ParseDNSLookupFamily("V6_ONLY")
+	assert.NoError(t, err)
+	assert.Equal(t, clusterv3.Cluster_V6_ONLY, family)
+
+	_, err = ParseDNSLookupFamily("AUTO")
+	assert.ErrorIs(t, err, ErrInvalidDNSLookupFamily)
+}
+
+func TestUrlCluster_setDNSLookupFamily(t *testing.T) {
+	cluster, err := url2Cluster("https://idp.example.com/jwks")
+	assert.NoError(t, err)
+
+	cluster.setDNSLookupFamily(nil)
+	assert.Equal(t, "idp_example_com_443", cluster.name)
+
+	cluster.setDNSLookupFamily(ptr.To(clusterv3.Cluster_ALL))
+	assert.Regexp(t, `^idp_example_com_443_[0-9a-f]{8}$`, cluster.name)
+}
+
+func TestUrlCluster_SchemeName(t *testing.T) {
+	plain, err := url2Cluster("http://idp.example.com:8443/jwks")
+	assert.NoError(t, err)
+
+	secure, err := url2Cluster("https://idp.example.com:8443/jwks")
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, plain.name, secure.name)
+
+	// The clusters renamed for their custom settings are still distinct
+	plain.setDNSLookupFamily(ptr.To(clusterv3.Cluster_ALL))
+	secure.setDNSLookupFamily(ptr.To(clusterv3.Cluster_ALL))
+	assert.NotEqual(t, plain.name, secure.name)
+}
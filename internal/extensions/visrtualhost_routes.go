@@ -13,6 +13,8 @@ import (
 	"github.com/openkcm/gateway-extension/internal/flags"
 )
 
+// VirtualHostModifyRoutes sets the JWT requirement built from the JWT providers of the listener on the
+// routes without JWT authentication configuration.
 func (s *GatewayExtension) VirtualHostModifyRoutes(ctx context.Context, routes []*routev3.Route) error {
 	for _, r := range routes {
 		slogctx.Info(ctx, "Updated VirtualHost Route", "name", r.GetName())
@@ -34,7 +36,7 @@ func (s *GatewayExtension) VirtualHostModifyRoutes(ctx context.Context, routes [
 				RequirementSpecifier: &jwtauthnv3.PerRouteConfig_RequirementName{RequirementName: JwtAuthSecureMappingName},
 			}
 
-			routeCfgAny, err := anypb.New(routeCfgProto)
+			routeCfgAny, err := newAny(routeCfgProto)
 			if err != nil {
 				return err
 			}
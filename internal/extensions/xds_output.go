@@ -0,0 +1,212 @@
+package extensions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"hash"
+	"maps"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/types"
+
+	pb "github.com/envoyproxy/gateway/proto/extension"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	slogctx "github.com/veqryn/slog-context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deterministic marshals the maps of the messages sorted by key, so identical configurations give
+// identical bytes and Envoy Gateway does not push unchanged resources.
+var deterministic = proto.MarshalOptions{Deterministic: true}
+
+// newAny marshals the message into an Any with the deterministic options.
+func newAny(m proto.Message) (*anypb.Any, error) {
+	a := &anypb.Any{}
+
+	err := anypb.MarshalFrom(a, m, deterministic)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// listenerOutput is the listener returned by PostHTTPListenerModify for an input, along with the JWKS
+// clusters and the JWTProvider statuses recorded while processing it, replayed when the input repeats.
+type listenerOutput struct {
+	// translation is the translation the listener was last returned in.
+	translation uint64
+	hash        [sha256.Size]byte
+	// expiresAt bounds the reuse of the output, as it also depends on the OIDC discovery and on the
+	// resources read from the cluster.
+	expiresAt time.Time
+	listener  *listenerv3.Listener
+	clusters  map[string]*urlCluster
+	statuses  map[types.NamespacedName]*jwtProviderStatus
+}
+
+// modifyListener applies the extension resources to the listener. The output of an input identical to the
+// previous one of the listener is returned as is, without processing it again. It reports if the listener
+// is unchanged.
+func (s *GatewayExtension) modifyListener(
+	ctx context.Context,
+	listener *listenerv3.Listener,
+	extensionResources []*pb.ExtensionResource,
+) (*listenerv3.Listener, bool, error) {
+	hash, err := s.listenerInputHash(listener, extensionResources)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The listeners are processed one at a time, so the statuses they record can be told apart.
+	s.listenerOutputsMu.Lock()
+	defer s.listenerOutputsMu.Unlock()
+
+	if cached, ok := s.reuseListenerOutput(ctx, listener.GetName(), hash); ok {
+		return cached, true, nil
+	}
+
+	resources := s.resources.Decode(ctx, extensionResources)
+
+	previous := s.takeJWTProviderStatuses()
+	err = s.resources.ModifyListener(ctx, listener, resources)
+	statuses := s.takeJWTProviderStatuses()
+
+	s.mergeJWTProviderStatuses(previous)
+	s.mergeJWTProviderStatuses(statuses)
+
+	if err != nil {
+		delete(s.listenerOutputs, listener.GetName())
+		return nil, false, err
+	}
+
+	s.storeListenerOutput(listener, hash, statuses)
+
+	return listener, false, nil
+}
+
+// listenerInputHash returns the digest of what the output of the listener is computed from: the listener
+// generated by Envoy Gateway, the extension resources, and the route requirements of its Gateways.
+func (s *GatewayExtension) listenerInputHash(
+	listener *listenerv3.Listener,
+	extensionResources []*pb.ExtensionResource,
+) ([sha256.Size]byte, error) {
+	h := sha256.New()
+
+	b, err := deterministic.Marshal(listener)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	writeHashChunk(h, b)
+
+	for _, r := range extensionResources {
+		writeHashChunk(h, r.GetUnstructuredBytes())
+	}
+
+	routeRequirements := s.gatewayRouteJWTRequirementsOf(listenerTargets(listener))
+	for _, name := range slices.Sorted(maps.Keys(routeRequirements)) {
+		writeHashChunk(h, []byte(name))
+
+		for _, jwtp := range routeRequirements[name] {
+			b, err := json.Marshal(jwtp)
+			if err != nil {
+				return [sha256.Size]byte{}, err
+			}
+
+			writeHashChunk(h, b)
+		}
+	}
+
+	return [sha256.Size]byte(h.Sum(nil)), nil
+}
+
+// writeHashChunk writes the length prefixed bytes, so consecutive chunks cannot be confused.
+func writeHashChunk(h hash.Hash, b []byte) {
+	_ = binary.Write(h, binary.BigEndian, uint64(len(b)))
+	_, _ = h.Write(b)
+}
+
+// reuseListenerOutput returns the listener previously returned for the same input, and replays the JWKS
+// clusters and the JWTProvider statuses recorded when it was processed. It must be called with the
+// outputs lock held.
+func (s *GatewayExtension) reuseListenerOutput(ctx context.Context, name string, hash [sha256.Size]byte) (*listenerv3.Listener, bool) {
+	output, ok := s.listenerOutputs[name]
+	if !ok || output.hash != hash || time.Now().After(output.expiresAt) {
+		return nil, false
+	}
+
+	slogctx.Debug(ctx, "Unchanged listener", "name", name)
+
+	s.jwtAuthClustersMu.Lock()
+	if output.clusters != nil {
+		s.setListenerJWTAuthClusters(name, output.clusters)
+	}
+
+	output.translation = s.translation
+	s.jwtAuthClustersMu.Unlock()
+
+	s.mergeJWTProviderStatuses(output.statuses)
+
+	return output.listener, true
+}
+
+// storeListenerOutput records the listener returned for the input. The outputs of the listeners with a
+// rejected JWTProvider are not kept, so a transient failure is retried on the next translation. It must
+// be called with the outputs lock held.
+func (s *GatewayExtension) storeListenerOutput(
+	listener *listenerv3.Listener,
+	hash [sha256.Size]byte,
+	statuses map[types.NamespacedName]*jwtProviderStatus,
+) {
+	for _, status := range statuses {
+		for _, condition := range status.conditions {
+			if condition.Status == metav1.ConditionFalse {
+				delete(s.listenerOutputs, listener.GetName())
+				return
+			}
+		}
+	}
+
+	s.jwtAuthClustersMu.Lock()
+	defer s.jwtAuthClustersMu.Unlock()
+
+	output := &listenerOutput{
+		translation: s.translation,
+		hash:        hash,
+		expiresAt:   time.Now().Add(s.discovery.ttl),
+		listener:    listener,
+		statuses:    statuses,
+	}
+
+	if clusters, ok := s.jwtAuthClusters[listener.GetName()]; ok && clusters.translation == s.translation {
+		output.clusters = clusters.clusters
+	}
+
+	if s.listenerOutputs == nil {
+		s.listenerOutputs = make(map[string]*listenerOutput)
+	}
+
+	s.listenerOutputs[listener.GetName()] = output
+}
+
+// pruneListenerOutputs forgets the outputs of the listeners not returned in the current translation, as
+// they vanished.
+func (s *GatewayExtension) pruneListenerOutputs() {
+	s.listenerOutputsMu.Lock()
+	defer s.listenerOutputsMu.Unlock()
+
+	s.jwtAuthClustersMu.RLock()
+	defer s.jwtAuthClustersMu.RUnlock()
+
+	for name, output := range s.listenerOutputs {
+		if output.translation < s.translation {
+			delete(s.listenerOutputs, name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package extensions
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/envoyproxy/gateway/proto/extension"
+	"github.com/openkcm/common-sdk/pkg/commoncfg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+
+	"github.com/openkcm/gateway-extension/api/v1alpha1"
+)
+
+// testJWTProviderResources returns JWTProviders with distinct remote JWKS, so the listener and the
+// translation output have maps and several clusters.
+func testJWTProviderResources(n int) []*extension.ExtensionResource {
+	resources := make([]*extension.ExtensionResource, 0, n)
+
+	for i := range n {
+		resources = append(resources, &extension.ExtensionResource{
+			UnstructuredBytes: mustMarshalResource(testJWTProvider(fmt.Sprintf("provider-%d", i), v1alpha1.JWTProviderSpec{
+				Name:       fmt.Sprintf("Provider%d", i),
+				Issuer:     fmt.Sprintf("https://idp%d.example.com", i),
+				RemoteJwks: &v1alpha1.RemoteJWKS{URI: fmt.Sprintf("https://idp%d.example.com/jwks", i)},
+			})),
+		})
+	}
+
+	return resources
+}
+
+func TestGatewayExtension_DeterministicOutput(t *testing.T) {
+	resources := testJWTProviderResources(8)
+
+	translate := func(t *testing.T) ([]byte, []string) {
+		t.Helper()
+
+		// A new extension per call, as a replica would
+		s := NewGatewayExtension(&commoncfg.FeatureGates{})
+
+		listenerResp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+			Listener:            testHTTPListener("kms/gateway/https"),
+			PostListenerContext: &extension.PostHTTPListenerExtensionContext{ExtensionResources: resources},
+		})
+		assert.NoError(t, err)
+
+		translateResp, err := s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{
+			Clusters: []*clusterv3.Cluster{{Name: "httproute/kms/api/rule/0"}},
+		})
+		assert.NoError(t, err)
+
+		b, err := proto.Marshal(listenerResp.GetListener())
+		assert.NoError(t, err)
+
+		clusters := make([]string, 0, len(translateResp.GetClusters()))
+		for _, c := range translateResp.GetClusters() {
+			clusters = append(clusters, c.GetName())
+		}
+
+		return b, clusters
+	}
+
+	wantListener, wantClusters := translate(t)
+	assert.IsIncreasing(t, wantClusters[1:])
+
+	for range 10 {
+		gotListener, gotClusters := translate(t)
+		assert.Equal(t, wantListener, gotListener)
+		assert.Equal(t, wantClusters, gotClusters)
+	}
+}
+
+func TestGatewayExtension_ListenerOutputCache(t *testing.T) {
+	handler := &testBackendHandler{}
+	s := NewGatewayExtension(&commoncfg.FeatureGates{}, WithResourceHandlers(handler))
+
+	modify := func(t *testing.T, name string, resources []*extension.ExtensionResource) *listenerv3.Listener {
+		t.Helper()
+
+		resp, err := s.PostHTTPListenerModify(t.Context(), &extension.PostHTTPListenerModifyRequest{
+			Listener:            testHTTPListener(name),
+			PostListenerContext: &extension.PostHTTPListenerExtensionContext{ExtensionResources: resources},
+		})
+		assert.NoError(t, err)
+
+		return resp.GetListener()
+	}
+
+	translate := func(t *testing.T) []string {
+		t.Helper()
+
+		resp, err := s.PostTranslateModify(t.Context(), &extension.PostTranslateModifyRequest{})
+		assert.NoError(t, err)
+
+		clusters := make([]string, 0, len(resp.GetClusters()))
+		for _, c := range resp.GetClusters() {
+			clusters = append(clusters, c.GetName())
+		}
+
+		return clusters
+	}
+
+	first := modify(t, "kms/gateway/https", testJWTProviderResources(2))
+	wantClusters := translate(t)
+	assert.Len(t, wantClusters, 2)
+
+	// Unchanged inputs return the same listener without processing it
+	assert.Same(t, first, modify(t, "kms/gateway/https", testJWTProviderResources(2)))
+	assert.Equal(t, []string{"kms/gateway/https"}, handler.listeners)
+
+	// The clusters and the statuses of the listener are still part of the translation
+	assert.Equal(t, 2, s.configuredJWTProviders())
+	assert.Equal(t, wantClusters, translate(t))
+
+	// Changed inputs are processed
+	changed := modify(t, "kms/gateway/https", testJWTProviderResources(3))
+	assert.NotSame(t, first, changed)
+	assert.False(t, proto.Equal(first, changed))
+
+	// The other listeners are tracked on their own
+	assert.NotSame(t, changed, modify(t, "kms/gateway/http", testJWTProviderResources(3)))
+	assert.Len(t, s.listenerOutputs, 2)
+	translate(t)
+
+	// The expired outputs are processed again
+	s.listenerOutputs["kms/gateway/https"].expiresAt = time.Now().Add(-time.Second)
+	assert.NotSame(t, changed, modify(t, "kms/gateway/https", testJWTProviderResources(3)))
+	assert.Len(t, handler.listeners, 4)
+
+	// The outputs of the listeners not returned in a translation are forgotten
+	translate(t)
+	assert.Len(t, s.listenerOutputs, 1)
+	assert.Contains(t, s.listenerOutputs, "kms/gateway/https")
+}